@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// default read cap when body assertions are set without max_body_bytes
+const assertBodyCap = 1 << 20
+
+func needsBody(a *Assertions) bool {
+	return hasBodyChecks(a) || a.MaxBodyBytes > 0
+}
+
+// hasBodyChecks reports whether a looks inside the body, which needs all
+// of it.
+func hasBodyChecks(a *Assertions) bool {
+	return len(a.BodyContains) > 0 || len(a.BodyNotContains) > 0 ||
+		len(a.BodyRegex) > 0 || len(a.JSONPath) > 0
+}
+
+// bodyLimit is how many bytes to read; one past the max so oversize bodies are detectable.
+func bodyLimit(a *Assertions) int64 {
+	if a.MaxBodyBytes > 0 {
+		return a.MaxBodyBytes + 1
+	}
+	return assertBodyCap + 1
+}
+
+// regexes holds every compiled body_regex and extract regex. Targets are
+// validated by the server and rarely change, so each pattern is compiled
+// once rather than on every probe.
+var regexes sync.Map // string → *regexp.Regexp
+
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexes.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexes.Store(expr, re)
+	return re, nil
+}
+
+// checkAssertions returns one human readable line per failed assertion.
+func checkAssertions(a *Assertions, resp *http.Response, body []byte, latencyMs int) []string {
+	var failed []string
+	fail := func(format string, args ...any) { failed = append(failed, fmt.Sprintf(format, args...)) }
+
+	if len(a.StatusCodes) > 0 {
+		match := false
+		for _, sc := range a.StatusCodes {
+			if statusMatches(sc, resp.StatusCode) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			fail("status_codes: got %d, want %s", resp.StatusCode, strings.Join(a.StatusCodes, ","))
+		}
+	}
+	if a.MaxLatencyMs > 0 && latencyMs > a.MaxLatencyMs {
+		fail("max_latency_ms: %dms > %dms", latencyMs, a.MaxLatencyMs)
+	}
+	for _, h := range a.Headers {
+		v, ok := resp.Header[http.CanonicalHeaderKey(h.Name)]
+		switch {
+		case !ok:
+			fail("header %s: missing", h.Name)
+		case h.Value != "" && strings.Join(v, ", ") != h.Value:
+			fail("header %s: got %q, want %q", h.Name, strings.Join(v, ", "), h.Value)
+		}
+	}
+	// a body cut off at the read limit is never checked: text past the
+	// cut would pass body_not_contains and fail body_contains
+	switch {
+	case a.MaxBodyBytes > 0 && int64(len(body)) > a.MaxBodyBytes:
+		fail("max_body_bytes: body exceeds %d bytes", a.MaxBodyBytes)
+		return failed
+	case a.MaxBodyBytes == 0 && len(body) > assertBodyCap && hasBodyChecks(a):
+		fail("body exceeds %d bytes; body assertions not evaluated", assertBodyCap)
+		return failed
+	}
+	for _, s := range a.BodyContains {
+		if !bytes.Contains(body, []byte(s)) {
+			fail("body_contains: %q not found", s)
+		}
+	}
+	for _, s := range a.BodyNotContains {
+		if bytes.Contains(body, []byte(s)) {
+			fail("body_not_contains: %q found", s)
+		}
+	}
+	for _, expr := range a.BodyRegex {
+		re, err := compileRegex(expr)
+		if err != nil {
+			fail("body_regex: invalid %q", expr)
+			continue
+		}
+		if !re.Match(body) {
+			fail("body_regex: %q did not match", expr)
+		}
+	}
+	if len(a.JSONPath) > 0 {
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			fail("json_path: body is not JSON")
+		} else {
+			for _, jp := range a.JSONPath {
+				got, err := jsonPath(doc, jp.Path)
+				if err != nil {
+					fail("json_path %s: %v", jp.Path, err)
+					continue
+				}
+				var want any
+				if err := json.Unmarshal(jp.Equals, &want); err != nil {
+					fail("json_path %s: invalid expected value", jp.Path)
+					continue
+				}
+				if !reflect.DeepEqual(got, want) {
+					g, _ := json.Marshal(got)
+					fail("json_path %s: got %s, want %s", jp.Path, g, jp.Equals)
+				}
+			}
+		}
+	}
+	return failed
+}
+
+// statusMatches understands "200", "2xx" and "200-299".
+func statusMatches(spec string, code int) bool {
+	if len(spec) == 3 && strings.HasSuffix(spec, "xx") {
+		return strconv.Itoa(code)[0] == spec[0]
+	}
+	if lo, hi, ok := strings.Cut(spec, "-"); ok {
+		l, err1 := strconv.Atoi(lo)
+		h, err2 := strconv.Atoi(hi)
+		return err1 == nil && err2 == nil && code >= l && code <= h
+	}
+	n, err := strconv.Atoi(spec)
+	return err == nil && n == code
+}
+
+// jsonPath resolves a dotted path with array indexes, e.g. $.data.items[0].status.
+func jsonPath(doc any, path string) (any, error) {
+	p := strings.TrimPrefix(path, "$")
+	cur := doc
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			key := p[:end]
+			p = p[end:]
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%q: not an object", key)
+			}
+			if cur, ok = m[key]; !ok {
+				return nil, fmt.Errorf("%q: not found", key)
+			}
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			idx, err := strconv.Atoi(p[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("bad index %q", p[1:end])
+			}
+			p = p[end+1:]
+			arr, ok := cur.([]any)
+			if !ok {
+				return nil, fmt.Errorf("[%d]: not an array", idx)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, fmt.Errorf("[%d]: out of range", idx)
+			}
+			cur = arr[idx]
+		default:
+			return nil, fmt.Errorf("unexpected %q", p[0])
+		}
+	}
+	return cur, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckAssertions(t *testing.T) {
+	body := `{"status":"ok","checks":{"db":{"status":"up"}},"items":[{"id":7}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name   string
+		a      Assertions
+		failed []string
+	}{
+		{"status exact", Assertions{StatusCodes: []string{"201"}}, nil},
+		{"status class", Assertions{StatusCodes: []string{"2xx"}}, nil},
+		{"status range", Assertions{StatusCodes: []string{"200-204"}}, nil},
+		{"status mismatch", Assertions{StatusCodes: []string{"200", "5xx"}}, []string{"status_codes: got 201, want 200,5xx"}},
+		{"body contains", Assertions{BodyContains: []string{`"ok"`, "missing"}}, []string{`body_contains: "missing" not found`}},
+		{"body not contains", Assertions{BodyNotContains: []string{"up"}}, []string{`body_not_contains: "up" found`}},
+		{"body regex", Assertions{BodyRegex: []string{`"id":\d+`, `^x`}}, []string{`body_regex: "^x" did not match`}},
+		{"json path", Assertions{JSONPath: []JSONPathAssert{
+			{Path: "$.checks.db.status", Equals: json.RawMessage(`"up"`)},
+			{Path: "$.items[0].id", Equals: json.RawMessage(`8`)},
+			{Path: "$.items[3].id", Equals: json.RawMessage(`1`)},
+		}}, []string{"json_path $.items[0].id: got 7, want 8", `json_path $.items[3].id: [3]: out of range`}},
+		{"headers", Assertions{Headers: []HeaderAssertion{
+			{Name: "x-request-id"},
+			{Name: "Content-Type", Value: "text/plain"},
+			{Name: "X-Missing"},
+		}}, []string{`header Content-Type: got "application/json", want "text/plain"`, "header X-Missing: missing"}},
+		{"max body bytes", Assertions{MaxBodyBytes: 10, BodyContains: []string{"ok"}}, []string{"max_body_bytes: body exceeds 10 bytes"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := doHTTP(context.Background(), http.DefaultClient, Target{URL: srv.URL, Assertions: &tc.a}, nil, false, &checkLogger{})
+			got := checkAssertions(&tc.a, &http.Response{StatusCode: r.StatusCode, Header: r.Header}, r.Body, r.LatencyMs)
+			if strings.Join(got, "\n") != strings.Join(tc.failed, "\n") {
+				t.Errorf("failed = %q, want %q", got, tc.failed)
+			}
+			if r.OK != (len(tc.failed) == 0) {
+				t.Errorf("OK = %v, reason %q", r.OK, r.Reason)
+			}
+		})
+	}
+}
+
+// Text past the read cap can't be seen, so body assertions aren't run on
+// the part that was read.
+func TestBodyAssertionsOverCap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", assertBodyCap) + "maintenance"))
+	}))
+	defer srv.Close()
+
+	a := &Assertions{BodyNotContains: []string{"maintenance"}}
+	lg := &checkLogger{}
+	r := doHTTP(context.Background(), http.DefaultClient, Target{URL: srv.URL, Assertions: a}, nil, false, lg)
+	if r.OK || r.Reason != "assertion_failed" {
+		t.Fatalf("OK = %v, reason %q; want assertion_failed", r.OK, r.Reason)
+	}
+	want := "assertion failed: body exceeds 1048576 bytes; body assertions not evaluated"
+	for _, l := range lg.logs {
+		if l.Line == want {
+			return
+		}
+	}
+	t.Errorf("no %q in logs %+v", want, lg.logs)
+}
+
+func TestStatusMatches(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		code int
+		want bool
+	}{
+		{"200", 200, true},
+		{"200", 201, false},
+		{"2xx", 299, true},
+		{"2xx", 300, false},
+		{"200-204", 204, true},
+		{"200-204", 205, false},
+		{"20x", 200, false},
+	} {
+		if got := statusMatches(tc.spec, tc.code); got != tc.want {
+			t.Errorf("statusMatches(%q, %d) = %v, want %v", tc.spec, tc.code, got, tc.want)
+		}
+	}
+}
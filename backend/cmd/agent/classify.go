@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// Failure reasons sent as Check.Error. The server groups and categorises
+// them (see store.ReasonCategory), so keep the two lists in step.
+const (
+	reasonTimeout          = "timeout"
+	reasonCanceled         = "canceled"
+	reasonDNSNotFound      = "dns_not_found"
+	reasonDNSTimeout       = "dns_timeout"
+	reasonDNSError         = "dns_error"
+	reasonConnRefused      = "connection_refused"
+	reasonConnReset        = "connection_reset"
+	reasonConnClosed       = "connection_closed"
+	reasonNetUnreachable   = "network_unreachable"
+	reasonHostUnreachable  = "host_unreachable"
+	reasonTLSExpired       = "tls_expired"
+	reasonTLSHostname      = "tls_hostname_mismatch"
+	reasonTLSUnknownAuth   = "tls_unknown_authority"
+	reasonTLSInvalidCert   = "tls_invalid_cert"
+	reasonTLSError         = "tls_error"
+	reasonTooManyRedirects = "too_many_redirects"
+	reasonNetworkError     = "network_error"
+	reasonUnknown          = "unknown_error"
+)
+
+const maxRedirects = 10
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+// checkRedirect mirrors the net/http default limit but returns a sentinel
+// error so classify can recognise it.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errTooManyRedirects
+	}
+	return nil
+}
+
+// classify maps a transport error to a failure reason by error type rather
+// than by message text.
+func classify(err error) string {
+	var (
+		dnsErr      *net.DNSError
+		certInvalid x509.CertificateInvalidError
+		hostErr     x509.HostnameError
+		unknownAuth x509.UnknownAuthorityError
+		certVerify  *tls.CertificateVerificationError
+		recordErr   tls.RecordHeaderError
+		alertErr    tls.AlertError
+		opErr       *net.OpError
+		netErr      net.Error
+	)
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		return reasonTooManyRedirects
+
+	case errors.As(err, &dnsErr):
+		switch {
+		case dnsErr.IsNotFound:
+			return reasonDNSNotFound
+		case dnsErr.IsTimeout:
+			return reasonDNSTimeout
+		}
+		return reasonDNSError
+
+	case errors.As(err, &certInvalid):
+		if certInvalid.Reason == x509.Expired {
+			return reasonTLSExpired
+		}
+		return reasonTLSInvalidCert
+	case errors.As(err, &hostErr):
+		return reasonTLSHostname
+	case errors.As(err, &unknownAuth):
+		return reasonTLSUnknownAuth
+	case errors.As(err, &certVerify), errors.As(err, &recordErr), errors.As(err, &alertErr):
+		return reasonTLSError
+
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return reasonTimeout
+	case errors.Is(err, context.Canceled):
+		return reasonCanceled
+
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return reasonConnRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return reasonConnReset
+	case errors.Is(err, syscall.ENETUNREACH):
+		return reasonNetUnreachable
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return reasonHostUnreachable
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return reasonConnClosed
+
+	case errors.As(err, &opErr):
+		return reasonNetworkError
+	}
+	return reasonUnknown
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	// errors as net/http returns them: wrapped in *url.Error and *net.OpError
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	for _, tc := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"redirects", &url.Error{Op: "Get", Err: errTooManyRedirects}, reasonTooManyRedirects},
+		{"dns not found", wrap(&net.DNSError{Err: "no such host", IsNotFound: true}), reasonDNSNotFound},
+		{"dns timeout", wrap(&net.DNSError{Err: "i/o timeout", IsTimeout: true}), reasonDNSTimeout},
+		{"dns other", wrap(&net.DNSError{Err: "server misbehaving"}), reasonDNSError},
+		{"cert expired", wrap(x509.CertificateInvalidError{Reason: x509.Expired}), reasonTLSExpired},
+		{"cert invalid", wrap(x509.CertificateInvalidError{Reason: x509.NotAuthorizedToSign}), reasonTLSInvalidCert},
+		{"hostname", wrap(x509.HostnameError{Host: "example.org"}), reasonTLSHostname},
+		{"unknown authority", wrap(x509.UnknownAuthorityError{}), reasonTLSUnknownAuth},
+		{"tls alert", wrap(tls.AlertError(40)), reasonTLSError},
+		{"deadline", wrap(context.DeadlineExceeded), reasonTimeout},
+		{"net timeout", wrap(os.ErrDeadlineExceeded), reasonTimeout},
+		{"canceled", &url.Error{Op: "Get", Err: context.Canceled}, reasonCanceled},
+		{"refused", wrap(os.NewSyscallError("connect", syscall.ECONNREFUSED)), reasonConnRefused},
+		{"reset", wrap(os.NewSyscallError("read", syscall.ECONNRESET)), reasonConnReset},
+		{"broken pipe", wrap(os.NewSyscallError("write", syscall.EPIPE)), reasonConnReset},
+		{"net unreachable", wrap(os.NewSyscallError("connect", syscall.ENETUNREACH)), reasonNetUnreachable},
+		{"host unreachable", wrap(os.NewSyscallError("connect", syscall.EHOSTUNREACH)), reasonHostUnreachable},
+		{"eof", &url.Error{Op: "Get", Err: io.EOF}, reasonConnClosed},
+		{"unexpected eof", io.ErrUnexpectedEOF, reasonConnClosed},
+		{"other net error", wrap(errors.New("something")), reasonNetworkError},
+		{"unknown", fmt.Errorf("odd: %w", errors.New("something")), reasonUnknown},
+	} {
+		if got := classify(tc.err); got != tc.want {
+			t.Errorf("%s: classify(%v) = %s, want %s", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+// The same errors, produced by real requests.
+func TestClassifyRequests(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusFound)
+	}))
+	defer loop.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+	hangup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer hangup.Close()
+	// a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + l.Addr().String()
+	l.Close()
+
+	hc := &http.Client{CheckRedirect: checkRedirect}
+	for _, tc := range []struct {
+		name, url string
+		timeoutMs int
+		want      string
+	}{
+		{"timeout", slow.URL, 50, reasonTimeout},
+		{"redirect loop", loop.URL + "/a", 2000, reasonTooManyRedirects},
+		{"self-signed", tlsSrv.URL, 2000, reasonTLSUnknownAuth},
+		{"hangup", hangup.URL, 2000, reasonConnClosed},
+		{"refused", closed, 2000, reasonConnRefused},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := doHTTP(context.Background(), hc, Target{URL: tc.url, TimeoutMs: tc.timeoutMs}, nil, false, &checkLogger{})
+			if r.Reason != tc.want {
+				t.Errorf("reason = %s, want %s", r.Reason, tc.want)
+			}
+		})
+	}
+}
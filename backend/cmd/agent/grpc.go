@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// probeGRPC calls grpc.health.v1.Health/Check on t.URL (host:port).
+// StatusCode carries the gRPC status code of the call (0 = OK).
+func probeGRPC(ctx context.Context, t Target) Check {
+	lg := &checkLogger{name: t.Name}
+	log := lg.log
+
+	addr := strings.TrimPrefix(t.URL, "grpc://")
+	creds := insecure.NewCredentials()
+	mode := "plaintext"
+	if t.GRPCTLS {
+		creds = credentials.NewTLS(&tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: t.GRPCTLSSkipVerify,
+		})
+		mode = "tls"
+	}
+
+	start := time.Now()
+	log("trace", fmt.Sprintf("grpc probe start → %s (%s, service=%q)", addr, mode, t.GRPCService))
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout(t.TimeoutMs))
+	defer cancel()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds), grpc.WithUserAgent("status-agent/"+agentVersion))
+	if err != nil {
+		log("error", "grpc dial error: "+err.Error())
+		return Check{Error: "grpc_error", LatencyMs: int(time.Since(start).Milliseconds()), Logs: lg.logs}
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: t.GRPCService})
+	latency := int(time.Since(start).Milliseconds())
+
+	if err != nil {
+		st, _ := status.FromError(err)
+		r := classifyGRPC(err)
+		log("error", fmt.Sprintf("rpc error %s: %s → %s", st.Code(), st.Message(), r))
+		return Check{StatusCode: int(st.Code()), Error: r, LatencyMs: latency, Logs: lg.logs}
+	}
+
+	log("info", fmt.Sprintf("health %s in %dms", resp.GetStatus(), latency))
+	switch resp.GetStatus() {
+	case healthpb.HealthCheckResponse_SERVING:
+		return Check{StatusCode: int(codes.OK), OK: true, LatencyMs: latency, Logs: lg.logs}
+	case healthpb.HealthCheckResponse_NOT_SERVING:
+		return Check{StatusCode: int(codes.OK), Error: "grpc_not_serving", LatencyMs: latency, Logs: lg.logs}
+	case healthpb.HealthCheckResponse_SERVICE_UNKNOWN:
+		return Check{StatusCode: int(codes.OK), Error: "grpc_service_unknown", LatencyMs: latency, Logs: lg.logs}
+	default:
+		return Check{StatusCode: int(codes.OK), Error: "grpc_unknown", LatencyMs: latency, Logs: lg.logs}
+	}
+}
+
+func classifyGRPC(err error) string {
+	st, _ := status.FromError(err)
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		return reasonTimeout
+	case codes.Canceled:
+		return reasonCanceled
+	case codes.NotFound:
+		// health servers answer NotFound for services they don't know
+		return "grpc_service_unknown"
+	case codes.Unimplemented:
+		return "grpc_unimplemented"
+	case codes.Unavailable:
+		return grpcTransportReason(st.Message())
+	default:
+		return "grpc_error"
+	}
+}
+
+// grpcTransportReason maps an Unavailable status to the shared taxonomy.
+// grpc-go flattens the underlying dial error into the status message, so
+// unlike classify this has to go by the text.
+func grpcTransportReason(m string) string {
+	for _, p := range []struct{ text, reason string }{
+		{"connection refused", reasonConnRefused},
+		{"connection reset", reasonConnReset},
+		{"no such host", reasonDNSNotFound},
+		{"network is unreachable", reasonNetUnreachable},
+		{"no route to host", reasonHostUnreachable},
+		{"certificate has expired", reasonTLSExpired},
+		{"certificate is valid for", reasonTLSHostname},
+		{"certificate signed by unknown authority", reasonTLSUnknownAuth},
+		{"x509:", reasonTLSError},
+		{"tls:", reasonTLSError},
+		{"i/o timeout", reasonTimeout},
+	} {
+		if strings.Contains(m, p.text) {
+			return p.reason
+		}
+	}
+	return "grpc_unavailable"
+}
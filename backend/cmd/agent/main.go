@@ -1,129 +1,414 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
 )
 
-type Target struct {
-	ID        int64  `json:"id"`
-	Name      string `json:"name"`
-	URL       string `json:"url"`
-	TimeoutMs int    `json:"timeout_ms"`
-}
+const agentVersion = "0.1"
+
+// defaultTimeoutMs applies to targets without a timeout, which only
+// TARGETS_FILE can produce; the server fills it in for its own.
+const defaultTimeoutMs = 4000
 
-type CheckLog struct {
-	TS    string `json:"ts"`    // RFC3339
-	Level string `json:"level"` // trace|info|warn|error
-	Line  string `json:"line"`
+// probeTimeout is the deadline for a probe with the given timeout_ms.
+func probeTimeout(ms int) time.Duration {
+	if ms <= 0 {
+		ms = defaultTimeoutMs
+	}
+	return time.Duration(ms) * time.Millisecond
 }
-type Check struct {
-	TargetID   int64      `json:"target_id"`
-	TS         string     `json:"ts"`
-	StatusCode int        `json:"status_code"`
-	OK         bool       `json:"ok"`
-	LatencyMs  int        `json:"latency_ms"`
-	Error      string     `json:"error"`
-	Logs       []CheckLog `json:"logs,omitempty"`
+
+// targets and results use the API's wire types so agent and server can't
+// disagree
+type (
+	Target          = client.Target
+	Auth            = client.Auth
+	Step            = client.Step
+	Extract         = client.Extract
+	Assertions      = client.Assertions
+	JSONPathAssert  = client.JSONPathAssert
+	HeaderAssertion = client.HeaderAssertion
+	Check           = client.Check
+	CheckLog        = client.CheckLog
+)
+
+// activeTargets drops paused targets; the server ignores their checks.
+func activeTargets(ts []Target) []Target {
+	out := ts[:0]
+	for _, t := range ts {
+		if !t.Paused {
+			out = append(out, t)
+		}
+	}
+	return out
 }
 
 func main() {
 	base := mustEnv("CENTRAL_BASE_URL")
 	apiKey := mustEnv("API_KEY")
 	poll := getenvInt("POLL_INTERVAL_SEC", 15)
-	var targets []Target
-	mustLoadJSON(getenv("TARGETS_FILE", "./targets.json"), &targets)
 
-	client := &http.Client{
+	// pushing to the central server has its own client so probe settings
+	// (redirect policy, keep-alive) never affect ingest
+	pushClient := &http.Client{
 		Timeout:   10 * time.Second,
 		Transport: &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}},
 	}
+	ingest := client.NewIngestClient(base, apiKey, client.WithHTTPClient(pushClient),
+		client.WithRetry(client.Retry{})) // failed pushes stay spooled instead
+	clients := newProbeClients(getenv("FRESH_CONNECTIONS", "false") == "true")
 
-	ticker := time.NewTicker(time.Duration(poll) * time.Second)
-	defer ticker.Stop()
-	ctx := context.Background()
+	// SIGTERM stops scheduling; in-flight probes run to their own deadline,
+	// then the remaining results are flushed before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		fmt.Println("[agent] shutting down, finishing in-flight probes...")
+	}()
+
+	// targets come from the server unless the deployment still uses a
+	// targets.json file
+	var targets []Target
+	updates := make(chan []Target)
+	source := getenv("TARGETS_SOURCE", "")
+	if source == "" && os.Getenv("TARGETS_FILE") != "" {
+		source = "file"
+	}
+	switch source {
+	case "", "server":
+		ts := &targetSync{ic: ingest}
+		fetched, _, err := ts.fetch(ctx)
+		if err != nil {
+			fmt.Printf("[agent] initial target sync failed, retrying: %v\n", err)
+		}
+		targets = activeTargets(fetched)
+		go ts.run(ctx, time.Duration(getenvInt("TARGETS_SYNC_INTERVAL_SEC", 60))*time.Second, updates)
+	case "file":
+		path := getenv("TARGETS_FILE", "./targets.json")
+		fmt.Printf("[agent] reading targets from %s; unset TARGETS_FILE to sync them from the server instead\n", path)
+		mustLoadJSON(path, &targets)
+		targets = activeTargets(targets)
+	default:
+		panic("TARGETS_SOURCE must be server or file")
+	}
+	fmt.Printf("[agent] %d active targets\n", len(targets))
+
+	sched := newScheduler(clients, time.Duration(poll)*time.Second, getenvInt("WORKERS", 8))
+	sched.active = len(targets) // so the first heartbeat doesn't race run
+	go sched.run(ctx, targets, updates)
+
+	// results are pushed in batches: every FLUSH_INTERVAL_SEC or once
+	// MAX_BATCH checks have piled up, whichever comes first. Results that
+	// fail to push stay spooled in memory (up to MAX_SPOOL, oldest dropped
+	// first) and are retried on the next flush.
+	flush := time.NewTicker(time.Duration(getenvInt("FLUSH_INTERVAL_SEC", 5)) * time.Second)
+	defer flush.Stop()
+	maxBatch := getenvInt("MAX_BATCH", 100)
+	maxSpool := getenvInt("MAX_SPOOL", 10000)
+	var spool []Check
+	backoff := false // last push failed; wait for the flush tick
+	push := func() {
+		for len(spool) > 0 {
+			n := min(len(spool), maxBatch)
+			// not ctx: the last push runs after shutdown has begun
+			_, err := ingest.Push(context.Background(), spool[:n])
+			var ae *client.APIError
+			if errors.As(err, &ae) && ae.StatusCode == http.StatusBadRequest {
+				// retrying a payload the server rejects would block the spool forever
+				fmt.Printf("[agent] server rejected %d checks, dropping: %v\n", n, err)
+				err = nil
+			}
+			if err != nil {
+				fmt.Printf("[agent] push failed, %d checks spooled: %v\n", len(spool), err)
+				backoff = true
+				return
+			}
+			spool = spool[n:]
+		}
+		backoff = false
+	}
+
+	started := time.Now()
+	hostname, _ := os.Hostname()
+	heartbeat := time.NewTicker(time.Duration(getenvInt("HEARTBEAT_INTERVAL_SEC", 30)) * time.Second)
+	defer heartbeat.Stop()
+	beat := func() {
+		hb := client.Heartbeat{
+			Version:     agentVersion,
+			Hostname:    hostname,
+			UptimeSec:   int64(time.Since(started).Seconds()),
+			TargetCount: sched.Count(),
+			SpoolDepth:  len(spool),
+		}
+		if err := ingest.Heartbeat(context.Background(), hb); err != nil {
+			fmt.Printf("[agent] heartbeat failed: %v\n", err)
+		}
+	}
+	beat()
 
 	for {
-		<-ticker.C
-		var batch []Check
-		for _, t := range targets {
-			status, ok, reason, latency, logs := probe(ctx, client, t.URL, t.TimeoutMs, t.Name)
-			batch = append(batch, Check{
-				TargetID:   t.ID,
-				TS:         time.Now().UTC().Format(time.RFC3339),
-				StatusCode: status,
-				OK:         ok,
-				LatencyMs:  latency,
-				Error:      reason,
-				Logs:       logs,
-			})
+		select {
+		case c, ok := <-sched.Results():
+			if !ok {
+				push()
+				if len(spool) > 0 {
+					fmt.Printf("[agent] exiting with %d unsent checks\n", len(spool))
+				}
+				fmt.Println("[agent] stopped")
+				return
+			}
+			spool = append(spool, c)
+			if over := len(spool) - maxSpool; over > 0 {
+				fmt.Printf("[agent] spool full, dropping %d oldest checks\n", over)
+				spool = spool[over:]
+			}
+			if len(spool) >= maxBatch && !backoff {
+				push()
+			}
+		case <-flush.C:
+			push()
+		case <-heartbeat.C:
+			beat()
 		}
-		postJSON(client, base+"/api/ingest/checks", apiKey, map[string]any{"checks": batch})
 	}
 }
 
-func probe(ctx context.Context, hc *http.Client, url string, timeoutMs int, name string) (int, bool, string, int, []CheckLog) {
-	var logs []CheckLog
-	log := func(level, line string) {
-		l := CheckLog{TS: time.Now().UTC().Format(time.RFC3339), Level: level, Line: line}
-		logs = append(logs, l)
-		fmt.Printf("[%s] %s: %s\n", l.Level, name, l.Line)
+// runProbe dispatches on the target type. The returned Check carries the
+// result and logs; the caller stamps TargetID and TS.
+func runProbe(ctx context.Context, pc *probeClients, t Target) Check {
+	switch t.Type {
+	case "grpc":
+		return probeGRPC(ctx, t)
+	case "multistep":
+		return probeSteps(ctx, pc.forTarget(t), t)
+	default:
+		return probe(ctx, pc.forTarget(t), t)
 	}
+}
 
-	start := time.Now()
-	log("trace", "probe start → "+url)
+// probeClients holds the HTTP clients used for probing. Neither sets
+// Client.Timeout: each request carries its own context deadline, so
+// concurrent probes with different timeouts can share them safely.
+type probeClients struct {
+	pooled       *http.Client // keep-alive; later probes skip connection setup
+	fresh        *http.Client // one connection per request
+	freshDefault bool
+}
+
+func newProbeClients(freshDefault bool) *probeClients {
+	tr := func(keepAlive bool) *http.Transport {
+		return &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			TLSClientConfig:   &tls.Config{MinVersion: tls.VersionTLS12},
+			DisableKeepAlives: !keepAlive,
+			ForceAttemptHTTP2: true,
+		}
+	}
+	return &probeClients{
+		pooled:       &http.Client{Transport: tr(true), CheckRedirect: checkRedirect},
+		fresh:        &http.Client{Transport: tr(false), CheckRedirect: checkRedirect},
+		freshDefault: freshDefault,
+	}
+}
+
+func (pc *probeClients) forTarget(t Target) *http.Client {
+	fresh := pc.freshDefault
+	if t.FreshConnection != nil {
+		fresh = *t.FreshConnection
+	}
+	if fresh {
+		return pc.fresh
+	}
+	return pc.pooled
+}
+
+// checkLogger collects per-check log lines and echoes them to stdout.
+type checkLogger struct {
+	name string
+	logs []CheckLog
+}
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	req.Header.Set("User-Agent", "status-agent/0.1")
-	hc.Timeout = time.Duration(timeoutMs) * time.Millisecond
+func (l *checkLogger) log(level, line string) {
+	cl := CheckLog{TS: time.Now().UTC().Format(time.RFC3339), Level: level, Line: line}
+	l.logs = append(l.logs, cl)
+	fmt.Printf("[%s] %s: %s\n", cl.Level, l.name, cl.Line)
+}
+
+func probe(ctx context.Context, hc *http.Client, t Target) Check {
+	lg := &checkLogger{name: t.Name}
+	lg.log("trace", "probe start → "+t.URL)
+	r := doHTTP(ctx, hc, t, nil, false, lg)
+	return Check{StatusCode: r.StatusCode, OK: r.OK, LatencyMs: r.LatencyMs, Error: r.Reason, Timings: r.Timings, Logs: lg.logs}
+}
+
+type httpResult struct {
+	StatusCode int
+	OK         bool
+	Reason     string
+	LatencyMs  int
+	Timings    *Timings
+	Header     http.Header
+	Body       []byte // only kept when assertions or the caller need it
+}
+
+// doHTTP sends one request described by t and evaluates its assertions.
+// vars fill {{name}} placeholders (multi-step checks); keepBody forces the
+// body to be read so the caller can extract values from it.
+func doHTTP(ctx context.Context, hc *http.Client, t Target, vars map[string]string, keepBody bool, lg *checkLogger) httpResult {
+	// the deadline covers the whole exchange including reading the body
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout(t.TimeoutMs))
+	defer cancel()
+	var tr phaseTracer
+	ctx = httptrace.WithClientTrace(ctx, tr.trace())
+
+	start := time.Now()
+	req, err := newRequest(ctx, t, vars)
+	if err != nil {
+		lg.log("error", "request config error: "+err.Error())
+		return httpResult{Reason: "config_error"}
+	}
 
 	resp, err := hc.Do(req)
 	latency := int(time.Since(start).Milliseconds())
 
 	if err != nil {
 		r := classify(err)
-		log("error", "transport error: "+err.Error()+" → "+r)
-		return 0, false, r, latency, logs
+		msg := err.Error()
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			// the sent URL may hold resolved secrets; log the configured one
+			msg = ue.Op + " " + t.URL + ": " + ue.Err.Error()
+		}
+		lg.log("error", "transport error: "+msg+" → "+r)
+		return httpResult{Reason: r, LatencyMs: latency, Timings: tr.timings()}
 	}
 	defer resp.Body.Close()
 
-	log("info", fmt.Sprintf("resp %d in %dms", resp.StatusCode, latency))
-	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-		return resp.StatusCode, true, "", latency, logs
+	lg.log("info", fmt.Sprintf("%s resp %d in %dms", req.Method, resp.StatusCode, latency))
+	res := httpResult{StatusCode: resp.StatusCode, LatencyMs: latency, Header: resp.Header}
+
+	// the body is always read (up to a cap) so transfer time is measured
+	a := t.Assertions
+	limit := int64(assertBodyCap + 1)
+	if a != nil {
+		limit = bodyLimit(a)
+	}
+	if keepBody || (a != nil && needsBody(a)) {
+		res.Body, err = io.ReadAll(io.LimitReader(resp.Body, limit))
+	} else {
+		_, err = io.Copy(io.Discard, io.LimitReader(resp.Body, limit))
+	}
+	tr.done()
+	res.Timings = tr.timings()
+	if res.Timings != nil {
+		lg.log("trace", "timings ("+tr.connection()+" connection): "+res.Timings.String())
+	}
+	if err != nil {
+		res.Reason = classify(err)
+		lg.log("error", "body read error: "+err.Error()+" → "+res.Reason)
+		return res
 	}
-	return resp.StatusCode, false, "non_2xx", latency, logs
-}
 
-func classify(err error) string {
-	e := err.Error()
-	switch {
-	case strings.Contains(e, "timeout") || strings.Contains(e, "DeadlineExceeded") || strings.Contains(e, "Client.Timeout"):
-		return "timeout"
-	case strings.Contains(e, "no such host") || strings.Contains(e, "lookup "):
-		return "dns_error"
-	case strings.Contains(e, "x509") || strings.Contains(e, "tls:"):
-		return "tls_error"
-	default:
-		return "dns_error"
+	if a != nil {
+		failed := checkAssertions(a, resp, res.Body, latency)
+		for _, f := range failed {
+			lg.log("warn", "assertion failed: "+f)
+		}
+		if len(failed) > 0 {
+			res.Reason = "assertion_failed"
+			return res
+		}
+		if len(a.StatusCodes) > 0 {
+			res.OK = true
+			return res
+		}
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		res.OK = true
+		return res
 	}
+	res.Reason = "non_2xx"
+	return res
 }
 
-func postJSON(hc *http.Client, url, apiKey string, body any) {
-	b, _ := json.Marshal(body)
-	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Api-Key", apiKey)
-	resp, err := hc.Do(req)
-	if err == nil && resp != nil {
-		_ = resp.Body.Close()
+// newRequest builds the probe request, resolving secret refs in the URL,
+// headers, body and auth, then {{name}} vars. Errors never include
+// resolved values.
+func newRequest(ctx context.Context, t Target, vars map[string]string) (*http.Request, error) {
+	method := t.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := resolveRefs(t.URL)
+	if err == nil {
+		u, err = expandVars(u, vars)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	var body io.Reader
+	if t.Body != "" {
+		b, err := resolveRefs(t.Body)
+		if err == nil {
+			b, err = expandVars(b, vars)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("body: %w", err)
+		}
+		body = strings.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		// its message would quote the resolved URL
+		return nil, errors.New("url: invalid after resolving refs")
+	}
+	req.Header.Set("User-Agent", "status-agent/"+agentVersion)
+	for k, v := range t.Headers {
+		rv, err := resolveRefs(v)
+		if err == nil {
+			rv, err = expandVars(rv, vars)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", k, err)
+		}
+		req.Header.Set(k, rv)
+	}
+	if a := t.Auth; a != nil {
+		switch a.Type {
+		case "basic":
+			pw, err := resolveRefs(a.Password)
+			if err != nil {
+				return nil, fmt.Errorf("basic auth: %w", err)
+			}
+			req.SetBasicAuth(a.Username, pw)
+		case "bearer":
+			tok, err := resolveRefs(a.Token)
+			if err != nil {
+				return nil, fmt.Errorf("bearer auth: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tok)
+		default:
+			return nil, fmt.Errorf("unknown auth type %q", a.Type)
+		}
 	}
+	return req, nil
 }
 
 func mustEnv(k string) string {
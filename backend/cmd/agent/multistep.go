@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// probeSteps runs t.Steps in order and reports them as a single check:
+// OK only if every step passed, with the reason of the first failing step.
+func probeSteps(ctx context.Context, hc *http.Client, t Target) Check {
+	lg := &checkLogger{name: t.Name}
+	lg.log("trace", fmt.Sprintf("multistep start (%d steps)", len(t.Steps)))
+
+	start := time.Now()
+	vars := map[string]string{}
+	status, reason, failedAt := 0, "", -1
+	for i, st := range t.Steps {
+		label := fmt.Sprintf("step %d/%d %s", i+1, len(t.Steps), st.Name)
+		if failedAt >= 0 && !st.Always {
+			lg.log("trace", label+": skipped")
+			continue
+		}
+		sl := &checkLogger{name: t.Name + " " + label}
+		timeout := st.TimeoutMs
+		if timeout <= 0 {
+			timeout = t.TimeoutMs
+		}
+		r := doHTTP(ctx, hc, Target{
+			Name:       t.Name,
+			URL:        st.URL,
+			TimeoutMs:  timeout,
+			Assertions: st.Assertions,
+			Method:     st.Method,
+			Headers:    st.Headers,
+			Body:       st.Body,
+			Auth:       st.Auth,
+		}, vars, len(st.Extract) > 0, sl)
+		if r.OK {
+			if err := extractVars(st.Extract, r, vars); err != nil {
+				sl.log("warn", "extract failed: "+err.Error())
+				r.OK, r.Reason = false, "extract_failed"
+			}
+		}
+		for _, l := range sl.logs {
+			l.Line = label + ": " + l.Line
+			lg.logs = append(lg.logs, l)
+		}
+		if !r.OK && failedAt < 0 {
+			status, reason, failedAt = r.StatusCode, r.Reason, i
+		}
+		if failedAt < 0 {
+			status = r.StatusCode
+		}
+	}
+
+	latency := int(time.Since(start).Milliseconds())
+	if failedAt >= 0 {
+		lg.log("error", fmt.Sprintf("multistep failed at step %d in %dms → %s", failedAt+1, latency, reason))
+		return Check{StatusCode: status, Error: reason, LatencyMs: latency, Logs: lg.logs}
+	}
+	lg.log("info", fmt.Sprintf("multistep ok in %dms", latency))
+	return Check{StatusCode: status, OK: true, LatencyMs: latency, Logs: lg.logs}
+}
+
+func extractVars(ex []Extract, r httpResult, vars map[string]string) error {
+	var doc any
+	parsed := false
+	for _, e := range ex {
+		switch {
+		case e.JSONPath != "":
+			if !parsed {
+				if err := json.Unmarshal(r.Body, &doc); err != nil {
+					return fmt.Errorf("%s: body is not JSON", e.Var)
+				}
+				parsed = true
+			}
+			v, err := jsonPath(doc, e.JSONPath)
+			if err != nil {
+				return fmt.Errorf("%s: %v", e.Var, err)
+			}
+			if s, ok := v.(string); ok {
+				vars[e.Var] = s
+			} else {
+				b, _ := json.Marshal(v)
+				vars[e.Var] = string(b)
+			}
+		case e.Header != "":
+			v := r.Header.Get(e.Header)
+			if v == "" {
+				return fmt.Errorf("%s: header %s missing", e.Var, e.Header)
+			}
+			vars[e.Var] = v
+		case e.Regex != "":
+			re, err := compileRegex(e.Regex)
+			if err != nil {
+				return fmt.Errorf("%s: invalid regex", e.Var)
+			}
+			m := re.FindSubmatch(r.Body)
+			if m == nil {
+				return fmt.Errorf("%s: regex did not match", e.Var)
+			}
+			if len(m) > 1 {
+				vars[e.Var] = string(m[1])
+			} else {
+				vars[e.Var] = string(m[0])
+			}
+		default:
+			return fmt.Errorf("%s: no source", e.Var)
+		}
+	}
+	return nil
+}
+
+var varRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// expandVars replaces {{name}} with values extracted by earlier steps.
+func expandVars(s string, vars map[string]string) (string, error) {
+	if len(vars) == 0 && !varRe.MatchString(s) {
+		return s, nil
+	}
+	var missing string
+	out := varRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := varRe.FindStringSubmatch(m)[1]
+		v, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined var {{%s}}", missing)
+	}
+	return out, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"token": "abc", "id": "7"}
+	for _, tc := range []struct {
+		in, want, err string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "Bearer {{token}}", want: "Bearer abc"},
+		{in: "/items/{{ id }}?t={{token}}", want: "/items/7?t=abc"},
+		{in: "{{missing}}", err: "undefined var {{missing}}"},
+		{in: "{{ not a var }}", want: "{{ not a var }}"},
+	} {
+		got, err := expandVars(tc.in, vars)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("expandVars(%q) error = %v, want %s", tc.in, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("expandVars(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+	if got, err := expandVars("{{token}}", nil); err == nil {
+		t.Errorf("expandVars without vars = %q, want an error", got)
+	}
+}
+
+func TestExtractVars(t *testing.T) {
+	r := httpResult{
+		Header: http.Header{"X-Session": {"s-1"}},
+		Body:   []byte(`{"data":{"token":"abc","ids":[4,5]},"n":3}`),
+	}
+	for _, tc := range []struct {
+		name string
+		ex   Extract
+		want string
+		err  string
+	}{
+		{"json string", Extract{Var: "v", JSONPath: "$.data.token"}, "abc", ""},
+		{"json number", Extract{Var: "v", JSONPath: "$.data.ids[1]"}, "5", ""},
+		{"json object", Extract{Var: "v", JSONPath: "$.data.ids"}, "[4,5]", ""},
+		{"json missing", Extract{Var: "v", JSONPath: "$.data.nope"}, "", `v: "nope": not found`},
+		{"header", Extract{Var: "v", Header: "x-session"}, "s-1", ""},
+		{"header missing", Extract{Var: "v", Header: "X-Other"}, "", "v: header X-Other missing"},
+		{"regex group", Extract{Var: "v", Regex: `"token":"(\w+)"`}, "abc", ""},
+		{"regex whole match", Extract{Var: "v", Regex: `"n":\d`}, `"n":3`, ""},
+		{"regex no match", Extract{Var: "v", Regex: `nothing`}, "", "v: regex did not match"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vars := map[string]string{}
+			err := extractVars([]Extract{tc.ex}, r, vars)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("error = %v, want %s", err, tc.err)
+				}
+				return
+			}
+			if err != nil || vars["v"] != tc.want {
+				t.Errorf("v = %q, %v; want %q", vars["v"], err, tc.want)
+			}
+		})
+	}
+}
+
+// A login step's token is extracted and sent by the next step; a failed
+// step skips the rest except those marked always.
+func TestProbeSteps(t *testing.T) {
+	var cleanedUp bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "tok-1"})
+	})
+	mux.HandleFunc("GET /orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) { cleanedUp = true })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	login := Step{Name: "login", Method: "POST", URL: srv.URL + "/login",
+		Extract: []Extract{{Var: "token", JSONPath: "$.token"}}}
+	orders := Step{Name: "orders", URL: srv.URL + "/orders",
+		Headers: map[string]string{"Authorization": "Bearer {{token}}"}}
+	logout := Step{Name: "logout", Method: "POST", URL: srv.URL + "/logout", Always: true}
+
+	c := probeSteps(context.Background(), srv.Client(), Target{Name: "shop", Steps: []Step{login, orders, logout}})
+	if !c.OK || c.StatusCode != http.StatusOK {
+		t.Fatalf("check = %+v, want OK", c)
+	}
+
+	cleanedUp = false
+	login.Extract[0].JSONPath = "$.missing"
+	c = probeSteps(context.Background(), srv.Client(), Target{Name: "shop", Steps: []Step{login, orders, logout}})
+	if c.OK || c.Error != "extract_failed" {
+		t.Errorf("check = %+v, want extract_failed", c)
+	}
+	if !cleanedUp {
+		t.Error("always step didn't run after a failure")
+	}
+}
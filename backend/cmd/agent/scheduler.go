@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// scheduler runs each target on its own interval through a bounded pool of
+// workers. Every target starts at a random offset within its interval so a
+// restart doesn't fire all probes at once, and a target whose previous run
+// is still in flight is skipped rather than queued twice. The target list
+// can be replaced while running; only added, changed and removed targets
+// are restarted or stopped.
+type scheduler struct {
+	clients         *probeClients
+	defaultInterval time.Duration
+	workers         int
+
+	jobs    chan Target
+	results chan Check
+
+	mu      sync.Mutex
+	running map[int64]bool
+	active  int // targets currently scheduled
+}
+
+func newScheduler(clients *probeClients, defaultInterval time.Duration, workers int) *scheduler {
+	return &scheduler{
+		clients:         clients,
+		defaultInterval: defaultInterval,
+		workers:         workers,
+		jobs:            make(chan Target),
+		results:         make(chan Check, 256),
+		running:         map[int64]bool{},
+	}
+}
+
+// Results delivers finished checks; it is closed once run returns.
+func (s *scheduler) Results() <-chan Check { return s.results }
+
+// Count is the number of targets currently scheduled.
+func (s *scheduler) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+type loopHandle struct {
+	target Target
+	stop   context.CancelFunc
+}
+
+// run blocks until ctx is done and all in-flight probes have finished.
+// Each list received on updates replaces the current targets. Cancelling
+// ctx stops new probes only; running ones keep their own timeout so their
+// results are still delivered.
+func (s *scheduler) run(ctx context.Context, targets []Target, updates <-chan []Target) {
+	probeCtx := context.WithoutCancel(ctx)
+	var workers sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for t := range s.jobs {
+				s.exec(probeCtx, t)
+			}
+		}()
+	}
+
+	var loops sync.WaitGroup
+	current := map[int64]loopHandle{}
+	apply := func(next []Target) {
+		want := make(map[int64]Target, len(next))
+		for _, t := range next {
+			want[t.ID] = t
+		}
+		for id, lh := range current {
+			if t, ok := want[id]; !ok || !reflect.DeepEqual(t, lh.target) {
+				lh.stop()
+				delete(current, id)
+			}
+		}
+		for id, t := range want {
+			if _, ok := current[id]; ok {
+				continue
+			}
+			lctx, stop := context.WithCancel(ctx)
+			current[id] = loopHandle{target: t, stop: stop}
+			loops.Add(1)
+			go func(t Target) {
+				defer loops.Done()
+				s.loop(lctx, t)
+			}(t)
+		}
+		s.mu.Lock()
+		s.active = len(current)
+		s.mu.Unlock()
+	}
+
+	apply(targets)
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case next := <-updates:
+			apply(next)
+		}
+	}
+
+	loops.Wait()
+	close(s.jobs)
+	workers.Wait()
+	close(s.results)
+}
+
+func (s *scheduler) interval(t Target) time.Duration {
+	if t.IntervalSec > 0 {
+		return time.Duration(t.IntervalSec) * time.Second
+	}
+	return s.defaultInterval
+}
+
+func (s *scheduler) loop(ctx context.Context, t Target) {
+	every := s.interval(t)
+	jitter := time.Duration(rand.Int64N(int64(every)))
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(jitter):
+	}
+
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		s.submit(ctx, t)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *scheduler) submit(ctx context.Context, t Target) {
+	s.mu.Lock()
+	if s.running[t.ID] {
+		s.mu.Unlock()
+		fmt.Printf("[warn] %s: previous probe still running, skipping this interval\n", t.Name)
+		return
+	}
+	s.running[t.ID] = true
+	s.mu.Unlock()
+
+	select {
+	case s.jobs <- t:
+	case <-ctx.Done():
+		s.done(t)
+	}
+}
+
+func (s *scheduler) exec(ctx context.Context, t Target) {
+	defer s.done(t)
+	ts := time.Now().UTC()
+	c := runProbe(ctx, s.clients, t)
+	c.TargetID = t.ID
+	c.TS = ts.Format(time.RFC3339)
+	s.results <- c
+}
+
+func (s *scheduler) done(t Target) {
+	s.mu.Lock()
+	delete(s.running, t.ID)
+	s.mu.Unlock()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// probeServer answers /t/<id> after delay and records, per target, how
+// many probes it got and how many were ever in flight at once.
+type probeServer struct {
+	*httptest.Server
+	delay time.Duration
+
+	mu                 sync.Mutex
+	hits               map[string]int
+	inFlight, maxTotal int
+	perTarget, maxPer  map[string]int
+}
+
+func newProbeServer(delay time.Duration) *probeServer {
+	ps := &probeServer{delay: delay, hits: map[string]int{}, perTarget: map[string]int{}, maxPer: map[string]int{}}
+	ps.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		ps.mu.Lock()
+		ps.hits[p]++
+		ps.inFlight++
+		ps.perTarget[p]++
+		ps.maxTotal = max(ps.maxTotal, ps.inFlight)
+		ps.maxPer[p] = max(ps.maxPer[p], ps.perTarget[p])
+		ps.mu.Unlock()
+		time.Sleep(ps.delay)
+		ps.mu.Lock()
+		ps.inFlight--
+		ps.perTarget[p]--
+		ps.mu.Unlock()
+	}))
+	return ps
+}
+
+func (ps *probeServer) target(id int64) Target {
+	return Target{ID: id, Name: fmt.Sprintf("t%d", id), URL: fmt.Sprintf("%s/t/%d", ps.URL, id), TimeoutMs: 2000}
+}
+
+func (ps *probeServer) hitsFor(id int64) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.hits[fmt.Sprintf("/t/%d", id)]
+}
+
+// runScheduler starts s and drains its results into a map of check counts
+// per target. stop cancels the scheduler and waits for run to return.
+func runScheduler(s *scheduler, targets []Target, updates chan []Target) (stop func() map[int64]int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.run(ctx, targets, updates)
+		close(done)
+	}()
+	counts := map[int64]int{}
+	drained := make(chan struct{})
+	go func() {
+		for c := range s.Results() {
+			counts[c.TargetID]++
+		}
+		close(drained)
+	}()
+	return func() map[int64]int {
+		cancel()
+		<-done
+		<-drained
+		return counts
+	}
+}
+
+func TestSchedulerProbesEveryTarget(t *testing.T) {
+	ps := newProbeServer(0)
+	defer ps.Close()
+	s := newScheduler(newProbeClients(false), 40*time.Millisecond, 4)
+	stop := runScheduler(s, []Target{ps.target(1), ps.target(2), ps.target(3)}, nil)
+
+	time.Sleep(300 * time.Millisecond)
+	if n := s.Count(); n != 3 {
+		t.Errorf("Count = %d, want 3", n)
+	}
+	counts := stop()
+	for id := int64(1); id <= 3; id++ {
+		// the first run lands within one interval, then one per interval
+		if counts[id] < 3 {
+			t.Errorf("target %d: %d checks in 300ms at a 40ms interval", id, counts[id])
+		}
+	}
+}
+
+// A target whose probe outlasts its interval is skipped rather than run
+// twice at once, and no more probes run than there are workers.
+func TestSchedulerBoundsConcurrency(t *testing.T) {
+	ps := newProbeServer(100 * time.Millisecond)
+	defer ps.Close()
+	s := newScheduler(newProbeClients(false), 20*time.Millisecond, 2)
+	var targets []Target
+	for id := int64(1); id <= 5; id++ {
+		targets = append(targets, ps.target(id))
+	}
+	stop := runScheduler(s, targets, nil)
+	time.Sleep(400 * time.Millisecond)
+	stop()
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.maxTotal > 2 {
+		t.Errorf("%d probes in flight at once with 2 workers", ps.maxTotal)
+	}
+	for p, n := range ps.maxPer {
+		if n > 1 {
+			t.Errorf("%s: %d probes in flight at once", p, n)
+		}
+	}
+}
+
+// Replacing the target list starts new targets and stops removed ones;
+// cancelling the context closes Results once in-flight probes are done.
+func TestSchedulerUpdatesAndStops(t *testing.T) {
+	ps := newProbeServer(0)
+	defer ps.Close()
+	s := newScheduler(newProbeClients(false), 30*time.Millisecond, 2)
+	updates := make(chan []Target)
+	stop := runScheduler(s, []Target{ps.target(1)}, updates)
+
+	time.Sleep(100 * time.Millisecond)
+	updates <- []Target{ps.target(2)}
+	time.Sleep(100 * time.Millisecond)
+	before := ps.hitsFor(1)
+	time.Sleep(100 * time.Millisecond)
+	if after := ps.hitsFor(1); after != before {
+		t.Errorf("removed target probed %d more times", after-before)
+	}
+	if ps.hitsFor(2) == 0 {
+		t.Error("added target never probed")
+	}
+	if n := s.Count(); n != 1 {
+		t.Errorf("Count = %d, want 1", n)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("scheduler didn't stop")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Targets come from the server, which only ever holds refs like ${env:NAME}
+// or ${file:PATH}. They are resolved here, on the agent, right before a probe.
+//
+// To keep a compromised server from reading arbitrary agent state, env refs
+// must carry SECRETS_ENV_PREFIX (default PROBE_) and file refs must live
+// under SECRETS_DIR (default /run/secrets).
+var refRe = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+func resolveRefs(s string) (string, error) {
+	var firstErr error
+	out := refRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := refRe.FindStringSubmatch(m)
+		v, err := resolveRef(sub[1], sub[2])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return v
+	})
+	return out, firstErr
+}
+
+func resolveRef(kind, name string) (string, error) {
+	switch kind {
+	case "env":
+		prefix := getenv("SECRETS_ENV_PREFIX", "PROBE_")
+		if !strings.HasPrefix(name, prefix) {
+			return "", fmt.Errorf("env ref %s: name must start with %s", name, prefix)
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("env ref %s: not set", name)
+		}
+		return v, nil
+	case "file":
+		dir, err := filepath.Abs(getenv("SECRETS_DIR", "/run/secrets"))
+		if err != nil {
+			return "", err
+		}
+		p := name
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		p = filepath.Clean(p)
+		if !strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return "", fmt.Errorf("file ref %s: outside %s", name, dir)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return "", fmt.Errorf("file ref %s: %w", name, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	return "", fmt.Errorf("unknown ref kind %q", kind)
+}
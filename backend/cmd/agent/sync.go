@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+// targetSync pulls the agent's targets from the central server. The server
+// answers 304 while the list is unchanged, so polling is cheap.
+type targetSync struct {
+	ic   *client.IngestClient
+	etag string
+}
+
+// fetch returns the current targets, or changed=false if they are the same
+// as on the last successful fetch.
+func (ts *targetSync) fetch(ctx context.Context) (targets []Target, changed bool, err error) {
+	targets, ts.etag, changed, err = ts.ic.Targets(ctx, ts.etag)
+	return targets, changed, err
+}
+
+// run polls every interval and sends each changed list to out until ctx
+// is done. Failures keep the current targets and are retried next tick.
+func (ts *targetSync) run(ctx context.Context, every time.Duration, out chan<- []Target) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		targets, changed, err := ts.fetch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("[agent] target sync failed, keeping current targets: %v\n", err)
+			}
+			continue
+		}
+		if !changed {
+			continue
+		}
+		fmt.Printf("[agent] targets updated: %d active\n", len(targets))
+		select {
+		case out <- activeTargets(targets):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+type Timings = client.Timings
+
+// phaseTracer records phase boundaries via net/http/httptrace. Dual-stack
+// dials call ConnectStart/ConnectDone from their own goroutines, possibly
+// after the request has returned, so every field is guarded by mu. Each
+// redirect hop starts over, so the phases all come from the final hop.
+type phaseTracer struct {
+	mu                        sync.Mutex
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wrote, firstByte          time.Time
+	bodyDone                  time.Time
+	gotConn                   bool
+	reused                    bool
+}
+
+// mark sets *t to now under the lock.
+func (p *phaseTracer) mark(t *time.Time) {
+	p.mu.Lock()
+	*t = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *phaseTracer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			p.mu.Lock()
+			p.dnsStart, p.dnsDone = time.Time{}, time.Time{}
+			p.connectStart, p.connectDone = time.Time{}, time.Time{}
+			p.tlsStart, p.tlsDone = time.Time{}, time.Time{}
+			p.wrote, p.firstByte = time.Time{}, time.Time{}
+			p.gotConn, p.reused = false, false
+			p.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { p.mark(&p.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { p.mark(&p.dnsDone) },
+		ConnectStart: func(_, _ string) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			// dual-stack dialing may start several; keep the first
+			if p.connectStart.IsZero() {
+				p.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			// keep the first dial that succeeded, not a late background one
+			if err == nil && p.connectDone.IsZero() {
+				p.connectDone = time.Now()
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.mu.Lock()
+			p.gotConn, p.reused = true, info.Reused
+			p.mu.Unlock()
+		},
+		TLSHandshakeStart:    func() { p.mark(&p.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { p.mark(&p.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { p.mark(&p.wrote) },
+		GotFirstResponseByte: func() { p.mark(&p.firstByte) },
+	}
+}
+
+// done marks the end of the response body.
+func (p *phaseTracer) done() { p.mark(&p.bodyDone) }
+
+func (p *phaseTracer) timings() *Timings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	span := func(a, b time.Time) *int {
+		if a.IsZero() || b.IsZero() || b.Before(a) {
+			return nil
+		}
+		ms := int(b.Sub(a).Milliseconds())
+		return &ms
+	}
+	t := &Timings{
+		DNSMs:      span(p.dnsStart, p.dnsDone),
+		ConnectMs:  span(p.connectStart, p.connectDone),
+		TLSMs:      span(p.tlsStart, p.tlsDone),
+		TTFBMs:     span(p.wrote, p.firstByte),
+		TransferMs: span(p.firstByte, p.bodyDone),
+	}
+	if t.DNSMs == nil && t.ConnectMs == nil && t.TLSMs == nil && t.TTFBMs == nil && t.TransferMs == nil {
+		return nil
+	}
+	return t
+}
+
+// connection describes how the request got its connection, for log lines.
+func (p *phaseTracer) connection() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case !p.gotConn:
+		return "none"
+	case p.reused:
+		return "reused"
+	}
+	return "new"
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+// After a redirect every phase comes from the final hop, so connect can't
+// be the first hop's while TTFB is the last one's.
+func TestPhaseTracerReportsFinalHop(t *testing.T) {
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer final.Close()
+	var redirected time.Time
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		redirected = time.Now()
+		http.Redirect(w, r, final.URL, http.StatusFound)
+	}))
+	defer first.Close()
+
+	var tr phaseTracer
+	ctx := httptrace.WithClientTrace(context.Background(), tr.trace())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, first.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := &http.Client{Transport: &http.Transport{}}
+	resp, err := hc.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	tr.done()
+
+	if !tr.connectStart.After(redirected) {
+		t.Errorf("connect started %v before the redirect at %v", tr.connectStart, redirected)
+	}
+	if tr.connectDone.After(tr.wrote) || tr.wrote.After(tr.firstByte) {
+		t.Errorf("phases out of order: connected %v, wrote %v, first byte %v", tr.connectDone, tr.wrote, tr.firstByte)
+	}
+	tm := tr.timings()
+	if tm == nil || tm.ConnectMs == nil || tm.TTFBMs == nil || *tm.TTFBMs >= 20 {
+		t.Errorf("timings = %+v, want connect and a final-hop TTFB", tm)
+	}
+	if tr.connection() != "new" {
+		t.Errorf("connection = %s, want new", tr.connection())
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/config"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+)
+
+const usage = `usage: server [command]
+
+Without a command the server runs. Commands:
+  migrate status                  list schema migrations and which are applied
+  migrate up                      apply pending schema migrations
+  backup FILE                     snapshot the SQLite database while the server runs
+  restore FILE                    replace the SQLite database with a snapshot;
+                                  stop the server first
+  export [-format ndjson|json] [-o FILE]
+                                  export targets, agents and their history
+  import FILE                     import an export into a server without
+                                  targets or agents (- reads stdin)
+
+The database comes from DB_DRIVER, DB_PATH and DATABASE_URL, as for the
+server.
+`
+
+// runCommand runs a maintenance command instead of the server and returns
+// the exit code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "migrate":
+		return migrateCmd(args[1:])
+	case "backup", "restore", "export", "import":
+		return dataCmd(args[0], args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n%s", args[0], usage)
+	return 2
+}
+
+// dbDSN is the connection string for the configured driver.
+func dbDSN(cfg *config.Config) string {
+	if cfg.DBDriver == "postgres" {
+		return cfg.DatabaseURL
+	}
+	return cfg.DBPath
+}
+
+// migrateOnStart applies pending migrations, or with auto off, fails
+// while any are pending.
+func migrateOnStart(ctx context.Context, st *store.Store, auto bool) error {
+	if !auto {
+		states, err := st.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		if n := store.Pending(states); n > 0 {
+			return fmt.Errorf("%d pending schema migration(s) and AUTO_MIGRATE=false; run `server migrate up`", n)
+		}
+		return nil
+	}
+	applied, err := st.Migrate(ctx)
+	for _, m := range applied {
+		fmt.Printf("[store] applied migration %d %s\n", m.Version, m.Name)
+	}
+	return err
+}
+
+func migrateCmd(args []string) int {
+	if len(args) != 1 || (args[0] != "status" && args[0] != "up") {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	cfg := config.Load()
+	st, err := store.Connect(cfg.DBDriver, dbDSN(cfg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	if args[0] == "up" {
+		applied, err := st.Migrate(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Printf("up to date at version %d\n", store.SchemaVersion())
+		}
+		return 0
+	}
+
+	states, err := st.MigrationStatus(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, m := range states {
+		state := "pending"
+		if m.AppliedAt != nil {
+			state = "applied " + m.AppliedAt.Format(time.RFC3339)
+		}
+		if m.Unknown {
+			state += " (unknown to this binary)"
+		}
+		fmt.Printf("%4d  %-24s %s\n", m.Version, m.Name, state)
+	}
+	return 0
+}
+
+// dataCmd runs backup, restore, export and import against the configured
+// database. Restore works on the file and needs the server stopped; the
+// others can run next to it.
+func dataCmd(cmd string, args []string) int {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	format := fs.String("format", "ndjson", "export format: ndjson or json")
+	out := fs.String("o", "-", "export file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if (cmd == "export") != (fs.NArg() == 0) || fs.NArg() > 1 || (*format != "ndjson" && *format != "json") {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	cfg := config.Load()
+	ctx := context.Background()
+
+	if cmd == "restore" {
+		if cfg.DBDriver == "postgres" {
+			fmt.Fprintln(os.Stderr, "restore is only built in for SQLite; use pg_restore for PostgreSQL")
+			return 1
+		}
+		saved, err := store.RestoreSQLite(fs.Arg(0), cfg.DBPath)
+		if saved != "" {
+			fmt.Printf("previous database kept as %s\n", saved)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("restored %s to %s; start the server to apply any newer migrations\n", fs.Arg(0), cfg.DBPath)
+		return 0
+	}
+
+	st, err := store.OpenDriver(cfg.DBDriver, dbDSN(cfg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer st.Close()
+
+	switch cmd {
+	case "backup":
+		err = st.Backup(ctx, fs.Arg(0))
+		if err == nil {
+			fmt.Printf("wrote %s\n", fs.Arg(0))
+		}
+	case "export":
+		w := os.Stdout
+		if *out != "-" {
+			if w, err = os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600); err != nil {
+				break
+			}
+		}
+		err = st.Export(ctx, w, *format == "json")
+		if w != os.Stdout {
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+	case "import":
+		r := os.Stdin
+		if fs.Arg(0) != "-" {
+			if r, err = os.Open(fs.Arg(0)); err != nil {
+				break
+			}
+			defer r.Close()
+		}
+		var res store.ImportResult
+		if res, err = st.Import(ctx, r); err == nil {
+			for _, typ := range []string{"project", "target", "pause", "agent", "check", "outage", "log"} {
+				fmt.Printf("%-8s %d\n", typ+"s", res[typ])
+			}
+			if res["agent"] > 0 {
+				fmt.Println("imported agents have no key; rotate their keys before they report")
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
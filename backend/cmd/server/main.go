@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/api"
@@ -16,9 +17,12 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	cfg := config.Load()
 
-	st, err := store.Open(cfg.DBPath)
+	st, err := store.Connect(cfg.DBDriver, dbDSN(cfg))
 	if err != nil {
 		panic(err)
 	}
@@ -27,24 +31,52 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	if err := migrateOnStart(ctx, st, cfg.AutoMigrate); err != nil {
+		panic(err)
+	}
+
 	r := gin.Default()
 	r.SetTrustedProxies(nil)
 
+	// OpenAPI document; its middleware checks responses, if enabled, of
+	// every route registered after it. Request bodies are checked by each
+	// route group after auth.
+	spec := api.NewOpenAPIHandler(cfg.Version, cfg.OpenAPIValidateResponses)
+	r.Use(spec.Middleware())
+	spec.Register(r)
+
 	// base
 	r.GET("/healthz", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
 	r.GET("/version", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"version": cfg.Version}) })
 
+	// users, sessions and API tokens for the management routes
+	auth := api.NewAuthHandler(st, time.Duration(cfg.SessionTTLHours)*time.Hour)
+	if err := auth.Bootstrap(ctx, cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		panic(err)
+	}
+	auth.Register(r)
+	api.NewProjectsHandler(st).Register(r)
+	api.NewConfigHandler(st).Register(r)
+	api.NewBackupHandler(st).Register(r)
+
 	// core APIs
 	api.NewTargetsHandler(st).Register(r)
 	api.NewMetricsHandler(st).Register(r)
+	api.NewOutagesHandler(st).Register(r)
 
 	// logs: history + SSE
 	logs := api.NewLogsHandler(st)
 	logs.Register(r)
 
-	// agent registration & ingest
-	api.NewAgentsHandler(st).Register(r)       // POST /api/agents/register
-	api.NewIngestHandler(st, logs).Register(r) // POST /api/ingest/checks (X-Api-Key)
+	// agent registration & ingest; outages go to alert route webhooks
+	alerts := api.NewAlerter(st)
+	agents := api.NewAgentsHandler(st, alerts)
+	agents.Register(r)                                 // /api/agents (admin), /heartbeat (X-Api-Key)
+	api.NewIngestHandler(st, logs, alerts).Register(r) // POST /api/ingest/checks (X-Api-Key)
+
+	// alert routes hear about agents that stop heartbeating
+	offlineAfter := time.Duration(cfg.AgentOfflineAfterSec) * time.Second
+	go agents.WatchOffline(ctx, offlineAfter, min(15*time.Second, offlineAfter/3))
 
 	// static dashboard
 	r.Static("/dashboard", "./internal/web/static")
@@ -52,11 +84,36 @@ func main() {
 	// Demo toggler for simulating outages
 	demo.NewToggler("https://httpbin.org/status/200").Register(r)
 
+	for _, p := range spec.CheckRoutes(r.Routes()) {
+		fmt.Printf("[openapi] %s\n", p)
+	}
+
 	addr := ":" + cfg.Port
-	fmt.Printf("Central on %s (db=%s)\n", addr, cfg.DBPath)
-	if err := r.Run(addr); err != nil && err != http.ErrServerClosed {
-		panic(err)
+	srv := &http.Server{Addr: addr, Handler: r}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	db := cfg.DBPath
+	if cfg.DBDriver == "postgres" {
+		db = "postgres"
 	}
+	fmt.Printf("Central on %s (db=%s)\n", addr, db)
 
-	<-ctx.Done()
+	select {
+	case err := <-errc:
+		if err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	// SSE streams never go idle, so end them before draining the rest
+	fmt.Println("Shutting down...")
+	logs.Close()
+	shCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutSec)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shCtx); err != nil {
+		fmt.Printf("shutdown: %v\n", err)
+	}
+	fmt.Println("Stopped")
 }
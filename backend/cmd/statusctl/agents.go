@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func (c *cli) agents(args []string) error {
+	if len(args) == 0 {
+		return errors.New("agents: want list, register or revoke")
+	}
+	switch sub, args := args[0], args[1:]; sub {
+	case "list", "ls":
+		return c.listAgents(args)
+	case "register":
+		return c.registerAgent(args)
+	case "revoke":
+		return c.revokeAgent(args)
+	default:
+		return fmt.Errorf("agents: unknown command %q", sub)
+	}
+}
+
+func (c *cli) listAgents(args []string) error {
+	if _, err := c.parse(c.flags("agents list"), args); err != nil {
+		return err
+	}
+	as, err := c.api.ListAgents(c.ctx)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(as))
+	for _, a := range as {
+		labels := make([]string, 0, len(a.Labels))
+		for k, v := range a.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		rows = append(rows, []string{
+			itoa(a.ID), a.Name, a.KeyPrefix, strings.Join(labels, ","), a.LastSeenAt,
+			a.Version, itoa(int64(a.TargetCount)), yesNo(a.Revoked),
+		})
+	}
+	return c.print(as, []string{"id", "name", "key_prefix", "labels", "last_seen_at", "version", "targets", "revoked"}, rows)
+}
+
+func (c *cli) registerAgent(args []string) error {
+	pos, err := c.parse(c.flags("agents register"), args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return errors.New("agents register: want a name")
+	}
+	res, err := c.api.RegisterAgent(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if c.output == "table" {
+		fmt.Fprintln(os.Stderr, "The key is only shown once. Set it as the agent's API_KEY.")
+	}
+	return c.print(res, []string{"agent_id", "api_key"}, [][]string{{itoa(res.AgentID), res.APIKey}})
+}
+
+func (c *cli) revokeAgent(args []string) error {
+	fs := c.flags("agents revoke")
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	pos, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return errors.New("agents revoke: want one agent (id or name)")
+	}
+	id, err := c.resolveAgent(pos[0])
+	if err != nil {
+		return err
+	}
+	if !*yes && !confirm(fmt.Sprintf("Revoke agent #%d? Its keys stop working at once.", id)) {
+		return errors.New("cancelled")
+	}
+	if err := c.api.RevokeAgent(c.ctx, id); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked agent #%d.\n", id)
+	return nil
+}
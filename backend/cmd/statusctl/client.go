@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+type cli struct {
+	ctx    context.Context
+	api    *client.Client
+	output string // table, json or csv
+}
+
+// resolveTarget finds a target by id, key or name (ignoring case).
+func (c *cli) resolveTarget(ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil && id > 0 {
+		return id, nil
+	}
+	ts, err := c.api.ListTargets(c.ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range ts {
+		if t.Key != "" && t.Key == ref {
+			return t.ID, nil
+		}
+	}
+	for _, t := range ts {
+		if strings.EqualFold(t.Name, ref) {
+			return t.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no target with id, key or name %q", ref)
+}
+
+// resolveAgent finds an agent by id or name. Revoked agents are skipped
+// when matching by name, since a new agent may reuse it.
+func (c *cli) resolveAgent(ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil && id > 0 {
+		return id, nil
+	}
+	as, err := c.api.ListAgents(c.ctx)
+	if err != nil {
+		return 0, err
+	}
+	var found []int64
+	for _, a := range as {
+		if a.Name == ref && !a.Revoked {
+			found = append(found, a.ID)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("no active agent with id or name %q", ref)
+	case 1:
+		return found[0], nil
+	}
+	return 0, fmt.Errorf("%d agents are named %q, use the id", len(found), ref)
+}
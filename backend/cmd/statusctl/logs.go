@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+// logs prints a target's recent log lines oldest first and, with -f, keeps
+// printing new ones from the SSE stream until interrupted. json output is
+// one object per line so it can be piped while following.
+func (c *cli) logs(args []string) error {
+	fs := c.flags("logs")
+	n := fs.Int("n", 50, "number of recent lines to show first (max 1000, 0 for none)")
+	follow := fs.Bool("f", false, "follow the live stream")
+	id, err := c.oneTarget(fs, args)
+	if err != nil {
+		return err
+	}
+
+	w := newLogWriter(c.output)
+	if *n > 0 {
+		hist, err := c.api.Logs(c.ctx, id, *n)
+		if err != nil {
+			return err
+		}
+		for i := len(hist) - 1; i >= 0; i-- {
+			w.write(hist[i])
+		}
+	}
+	w.flush()
+	if !*follow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(c.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		err := c.api.StreamLogs(ctx, id, func(l client.CheckLog) {
+			w.write(l)
+			w.flush()
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		var ae *client.APIError
+		if errors.As(err, &ae) && ae.StatusCode < 500 {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "log stream ended (%v), reconnecting...\n", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// logWriter prints log lines in the chosen output format as they arrive.
+type logWriter struct {
+	format string
+	csv    *csv.Writer
+	enc    *json.Encoder
+}
+
+func newLogWriter(format string) *logWriter {
+	w := &logWriter{format: format}
+	switch format {
+	case "csv":
+		w.csv = csv.NewWriter(os.Stdout)
+		_ = w.csv.Write([]string{"ts", "level", "line"})
+	case "json":
+		w.enc = json.NewEncoder(os.Stdout)
+	}
+	return w
+}
+
+func (w *logWriter) write(l client.CheckLog) {
+	switch w.format {
+	case "csv":
+		_ = w.csv.Write([]string{l.TS, l.Level, l.Line})
+	case "json":
+		_ = w.enc.Encode(l)
+	default:
+		fmt.Printf("%s  %-5s  %s\n", l.TS, strings.ToUpper(l.Level), l.Line)
+	}
+}
+
+func (w *logWriter) flush() {
+	if w.csv != nil {
+		w.csv.Flush()
+	}
+}
@@ -0,0 +1,172 @@
+// statusctl manages a status-probe-lite server from the command line.
+//
+//	statusctl targets list               list, get, create, update, delete, pause and resume targets
+//	statusctl agents register edge-eu    list, register and revoke agents
+//	statusctl metrics api-health -since 24h
+//	statusctl logs -f api-health         recent logs, then follow the live stream
+//	statusctl outages -open
+//	statusctl plan -f config.yaml        show what apply would change
+//	statusctl apply -f config.yaml       make the server match the file
+//	statusctl export > config.yaml       write the current config as a file
+//
+// Settings come from flags, then STATUSCTL_SERVER, STATUSCTL_TOKEN and
+// STATUSCTL_OUTPUT, then the config file (-config, STATUSCTL_CONFIG or
+// statusctl/config.yaml in the user config directory).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+// settings is the config file format; flags and env override each field.
+type settings struct {
+	Server string `yaml:"server"`
+	Token  string `yaml:"token"`
+	Output string `yaml:"output"`
+}
+
+func main() {
+	fs := flag.NewFlagSet("statusctl", flag.ExitOnError)
+	server := fs.String("server", "", "server base URL (default http://localhost:8080)")
+	token := fs.String("token", "", "API token")
+	output := fs.String("o", "", "output format: table, json or csv (default table)")
+	cfgPath := fs.String("config", "", "config file (default "+defaultConfigPath()+")")
+	fs.Usage = usage
+	_ = fs.Parse(os.Args[1:])
+	if fs.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	s, err := loadSettings(*cfgPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	s.Server = pick(*server, os.Getenv("STATUSCTL_SERVER"), s.Server, "http://localhost:8080")
+	s.Token = pick(*token, os.Getenv("STATUSCTL_TOKEN"), s.Token)
+	s.Output = pick(*output, os.Getenv("STATUSCTL_OUTPUT"), s.Output, "table")
+	var opts []client.Option
+	if s.Token != "" {
+		opts = append(opts, client.WithToken(s.Token))
+	}
+	c := &cli{ctx: context.Background(), api: client.New(s.Server, opts...), output: s.Output}
+
+	if err := checkOutput(c.output); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	switch cmd, args := fs.Arg(0), fs.Args()[1:]; cmd {
+	case "targets", "target":
+		err = c.targets(args)
+	case "agents", "agent":
+		err = c.agents(args)
+	case "metrics":
+		err = c.metrics(args)
+	case "logs":
+		err = c.logs(args)
+	case "outages":
+		err = c.outages(args)
+	case "plan":
+		err = c.plan(args)
+	case "apply":
+		err = c.apply(args)
+	case "export":
+		err = c.export(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		var ex exitCode
+		if errors.As(err, &ex) {
+			os.Exit(int(ex))
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: statusctl [-server URL] [-token TOKEN] [-o table|json|csv] [-config FILE] <command> [flags]
+
+commands:
+  targets list [-tag TAG]                       list targets
+  targets get TARGET                            show one target
+  targets create [-f FILE] [-name N] [-url U]   create a target from a file and/or flags
+  targets update TARGET [-f FILE] [flags]       change the given fields of a target
+  targets delete TARGET [-yes]                  delete a target and its history
+  targets pause|resume TARGET                   stop or restart checks
+  agents list                                   list agents
+  agents register NAME                          register an agent and print its key
+  agents revoke AGENT [-yes]                    revoke an agent's keys
+  metrics TARGET [-since 1h | -from T -to T]    availability, latency and outages
+  logs TARGET [-n 50] [-f]                      recent logs; -f follows the live stream
+  outages [-target TARGET] [-open] [-since D]   list outages, newest first
+  plan   -f FILE [-detailed-exitcode]           show the changes apply would make
+  apply  -f FILE [-yes]                         apply a config file
+  export [-format yaml|json]                    print the current config
+
+TARGET is an id, key or name. AGENT is an id or name. -o can also follow the
+command.
+`)
+}
+
+// exitCode ends the program with a status but no error message.
+type exitCode int
+
+func (e exitCode) Error() string { return fmt.Sprintf("exit %d", int(e)) }
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "statusctl", "config.yaml")
+}
+
+// loadSettings reads the config file. A missing default file is not an
+// error; a missing file that was asked for is.
+func loadSettings(path string) (settings, error) {
+	var s settings
+	if path == "" {
+		path = os.Getenv("STATUSCTL_CONFIG")
+	}
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return s, err
+	}
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// pick returns the first non-empty value.
+func pick(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+func (c *cli) metrics(args []string) error {
+	fs := c.flags("metrics")
+	sinceFlag := fs.String("since", "1h", "window ending now, e.g. 90m, 24h, 7d")
+	fromFlag := fs.String("from", "", "window start (RFC3339); needs -to")
+	toFlag := fs.String("to", "", "window end (RFC3339)")
+	id, err := c.oneTarget(fs, args)
+	if err != nil {
+		return err
+	}
+	var from, to time.Time
+	switch {
+	case (*fromFlag == "") != (*toFlag == ""):
+		return errors.New("metrics: -from and -to go together")
+	case *fromFlag != "":
+		if from, err = time.Parse(time.RFC3339, *fromFlag); err != nil {
+			return fmt.Errorf("metrics: -from: %w", err)
+		}
+		if to, err = time.Parse(time.RFC3339, *toFlag); err != nil {
+			return fmt.Errorf("metrics: -to: %w", err)
+		}
+	default:
+		if from, err = since(*sinceFlag); err != nil {
+			return err
+		}
+		to = time.Now().UTC()
+	}
+
+	m, err := c.api.Metrics(c.ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+	header := []string{"target_id", "from", "to", "checks", "failed", "availability_checks", "availability_time",
+		"avg_latency_ms", "outages", "downtime", "paused", "last_check_at", "agent_offline"}
+	row := []string{itoa(id), m.From, m.To, itoa(m.TotalChecks), itoa(m.FailedChecks),
+		pct(m.AvailabilityPercentChecks), pct(m.AvailabilityPercentTime), latency(m),
+		itoa(int64(len(m.Outages))), dur(m.DowntimeMs), flagCell(m.Paused, m.PausedMs), str(m.LastCheckAt), flagCell(m.AgentOffline, m.AgentOfflineMs)}
+	if c.output == "table" {
+		// one wide row reads badly, so the table is field/value pairs
+		rows := make([][]string, len(header))
+		for i := range header {
+			rows[i] = []string{header[i], row[i]}
+		}
+		return c.print(m, []string{"field", "value"}, rows)
+	}
+	return c.print(m, header, [][]string{row})
+}
+
+func pct(p *float64) string {
+	if p == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", *p)
+}
+
+// latency is "-" rather than 0 when no check succeeded in the window.
+func latency(m *client.Metrics) string {
+	if m.SuccessfulChecks == 0 {
+		return "-"
+	}
+	return itoa(int64(m.AverageLatencyMs))
+}
+
+func str(p *string) string {
+	if p == nil {
+		return "-"
+	}
+	return *p
+}
+
+// flagCell shows a state with the time spent in it over the window.
+func flagCell(now bool, ms int64) string {
+	if !now && ms == 0 {
+		return "no"
+	}
+	return fmt.Sprintf("%s (%s)", yesNo(now), dur(ms))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+func (c *cli) outages(args []string) error {
+	fs := c.flags("outages")
+	ref := fs.String("target", "", "only this target (id, key or name)")
+	open := fs.Bool("open", false, "only outages still open")
+	sinceFlag := fs.String("since", "", "only outages overlapping this window, e.g. 24h, 7d")
+	limit := fs.Int("limit", 100, "at most this many outages (max 1000)")
+	pos, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 {
+		return fmt.Errorf("outages: unexpected argument %q", pos[0])
+	}
+	q := client.OutageQuery{OpenOnly: *open, Limit: *limit}
+	if *ref != "" {
+		if q.TargetID, err = c.resolveTarget(*ref); err != nil {
+			return err
+		}
+	}
+	if *sinceFlag != "" {
+		if q.From, err = since(*sinceFlag); err != nil {
+			return err
+		}
+	}
+
+	list, err := c.api.ListOutages(c.ctx, q)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(list))
+	for _, o := range list {
+		ended := "open"
+		if o.EndedAt != nil {
+			ended = *o.EndedAt
+		}
+		rows = append(rows, []string{itoa(o.ID), itoa(o.TargetID), o.TargetName, o.StartedAt, ended, dur(o.DurationMs), o.Reason})
+	}
+	return c.print(list, []string{"id", "target_id", "target", "started_at", "ended_at", "duration", "reason"}, rows)
+}
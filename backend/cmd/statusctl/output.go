@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func checkOutput(o string) error {
+	switch o {
+	case "table", "json", "csv":
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want table, json or csv)", o)
+}
+
+// flags returns a flag set for a subcommand that also accepts -o.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&c.output, "o", c.output, "output format: table, json or csv")
+	return fs
+}
+
+// parse parses args whose flags may come before or after the positional
+// arguments, and returns the positional ones.
+func (c *cli) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+	return pos, checkOutput(c.output)
+}
+
+// print writes rows as an aligned table or CSV, or v (what the server
+// returned) as indented JSON for json output.
+func (c *cli) print(v any, header []string, rows [][]string) error {
+	switch c.output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(v)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write(header)
+		return w.WriteAll(rows)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
+
+// since turns "90m", "24h" or "7d" into a time before now.
+func since(s string) (time.Time, error) {
+	var d time.Duration
+	var err error
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		var days int
+		days, err = strconv.Atoi(n)
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("bad duration %q (e.g. 90m, 24h, 7d)", s)
+	}
+	return time.Now().UTC().Add(-d), nil
+}
+
+func itoa(n int64) string { return strconv.FormatInt(n, 10) }
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// dur formats milliseconds for tables, e.g. 1h2m3s or 450ms.
+func dur(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+func (c *cli) plan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	file := fs.String("f", "", "config file (YAML or JSON), - for stdin")
+	detailed := fs.Bool("detailed-exitcode", false, "exit 2 when there are changes")
+	_ = fs.Parse(args)
+	body, err := readFile(*file)
+	if err != nil {
+		return err
+	}
+	p, err := c.api.PlanConfig(c.ctx, body)
+	if err != nil {
+		return err
+	}
+	printPlan(p)
+	if *detailed && len(p.Changes) > 0 {
+		return exitCode(2)
+	}
+	return nil
+}
+
+func (c *cli) apply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	file := fs.String("f", "", "config file (YAML or JSON), - for stdin")
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	_ = fs.Parse(args)
+	body, err := readFile(*file)
+	if err != nil {
+		return err
+	}
+	p, err := c.api.PlanConfig(c.ctx, body)
+	if err != nil {
+		return err
+	}
+	printPlan(p)
+	if len(p.Changes) == 0 {
+		return nil
+	}
+	if !*yes && !confirm("Apply these changes?") {
+		return errors.New("cancelled")
+	}
+	// the server plans again, so changes made since are not lost
+	if p, err = c.api.ApplyConfig(c.ctx, body); err != nil {
+		return err
+	}
+	fmt.Printf("Applied: %d created, %d updated, %d deleted.\n", p.Summary["create"], p.Summary["update"], p.Summary["delete"])
+	return nil
+}
+
+func (c *cli) export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "yaml", "yaml or json")
+	_ = fs.Parse(args)
+	b, err := c.api.ExportConfig(c.ctx, *format == "yaml")
+	if err != nil {
+		return err
+	}
+	if *format == "yaml" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	var buf bytes.Buffer
+	_ = json.Indent(&buf, b, "", "  ")
+	fmt.Println(buf.String())
+	return nil
+}
+
+func printPlan(p *client.PlanResult) {
+	if len(p.Changes) == 0 {
+		fmt.Println("No changes. The server matches the configuration.")
+		return
+	}
+	sym := map[string]string{"create": "+", "update": "~", "delete": "-"}
+	for _, ch := range p.Changes {
+		line := fmt.Sprintf("%s %s %s %s", sym[ch.Action], ch.Action, ch.Kind, ch.Key)
+		if len(ch.Fields) > 0 {
+			line += " (" + strings.Join(ch.Fields, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("Plan: %d to create, %d to update, %d to delete.\n", p.Summary["create"], p.Summary["update"], p.Summary["delete"])
+}
+
+func confirm(q string) bool {
+	fmt.Printf("%s [y/N] ", q)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	line = strings.ToLower(strings.TrimSpace(line))
+	return line == "y" || line == "yes"
+}
+
+func readFile(path string) ([]byte, error) {
+	switch path {
+	case "":
+		return nil, errors.New("-f is required")
+	case "-":
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
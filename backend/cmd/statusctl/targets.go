@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+func (c *cli) targets(args []string) error {
+	if len(args) == 0 {
+		return errors.New("targets: want list, get, create, update, delete, pause or resume")
+	}
+	switch sub, args := args[0], args[1:]; sub {
+	case "list", "ls":
+		return c.listTargets(args)
+	case "get":
+		return c.getTarget(args)
+	case "create":
+		return c.writeTarget(args, false)
+	case "update":
+		return c.writeTarget(args, true)
+	case "delete", "rm":
+		return c.deleteTarget(args)
+	case "pause", "resume":
+		return c.pauseTarget(sub, args)
+	default:
+		return fmt.Errorf("targets: unknown command %q", sub)
+	}
+}
+
+var targetHeader = []string{"id", "key", "name", "type", "url", "group", "tags", "paused"}
+
+func targetRow(t client.Target) []string {
+	return []string{itoa(t.ID), t.Key, t.Name, t.Type, t.URL, t.Group, strings.Join(t.Tags, ","), yesNo(t.Paused)}
+}
+
+func (c *cli) listTargets(args []string) error {
+	fs := c.flags("targets list")
+	tag := fs.String("tag", "", "only targets with this tag")
+	if _, err := c.parse(fs, args); err != nil {
+		return err
+	}
+	ts, err := c.api.ListTargets(c.ctx, *tag)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(ts))
+	for _, t := range ts {
+		rows = append(rows, targetRow(t))
+	}
+	return c.print(ts, targetHeader, rows)
+}
+
+// oneTarget parses args for commands that take a single TARGET.
+func (c *cli) oneTarget(fs *flag.FlagSet, args []string) (int64, error) {
+	pos, err := c.parse(fs, args)
+	if err != nil {
+		return 0, err
+	}
+	if len(pos) != 1 {
+		return 0, fmt.Errorf("%s: want one target (id, key or name)", fs.Name())
+	}
+	return c.resolveTarget(pos[0])
+}
+
+func (c *cli) getTarget(args []string) error {
+	id, err := c.oneTarget(c.flags("targets get"), args)
+	if err != nil {
+		return err
+	}
+	return c.showTarget(c.api.GetTarget(c.ctx, id))
+}
+
+func (c *cli) showTarget(t *client.Target, err error) error {
+	if err != nil {
+		return err
+	}
+	return c.print(t, targetHeader, [][]string{targetRow(*t)})
+}
+
+// writeTarget creates a target, or updates one when update is set. The
+// body is the -f file (YAML or JSON) with any field flags laid over it;
+// on update only the flags that were given are sent.
+func (c *cli) writeTarget(args []string, update bool) error {
+	name := "targets create"
+	if update {
+		name = "targets update"
+	}
+	fs := c.flags(name)
+	file := fs.String("f", "", "target fields as YAML or JSON, - for stdin")
+	fs.String("name", "", "name")
+	fs.String("url", "", "URL, or host:port for grpc")
+	fs.String("type", "", "http, grpc or multistep")
+	fs.String("method", "", "HTTP method")
+	fs.Int("timeout-ms", 0, "timeout in milliseconds")
+	fs.Int("interval", 0, "seconds between checks, 0 for the agent's default")
+	fs.String("description", "", "description")
+	fs.String("group", "", "group key")
+	fs.String("tags", "", "comma-separated tags, replacing the current ones")
+
+	pos, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	var id int64
+	switch {
+	case update && len(pos) != 1:
+		return errors.New("targets update: want one target (id, key or name)")
+	case update:
+		if id, err = c.resolveTarget(pos[0]); err != nil {
+			return err
+		}
+	case len(pos) != 0:
+		return fmt.Errorf("targets create: unexpected argument %q", pos[0])
+	}
+
+	body := map[string]any{}
+	if *file != "" {
+		b, err := readFile(*file)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(b, &body); err != nil {
+			return fmt.Errorf("%s: %w", *file, err)
+		}
+		if body == nil {
+			body = map[string]any{}
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		key := strings.ReplaceAll(f.Name, "-", "_")
+		v := f.Value.String()
+		switch f.Name {
+		case "f", "o":
+		case "timeout-ms":
+			body[key], _ = strconv.Atoi(v)
+		case "interval":
+			body["interval_sec"], _ = strconv.Atoi(v)
+		case "tags":
+			tags := []string{}
+			for _, t := range strings.Split(v, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					tags = append(tags, t)
+				}
+			}
+			body[key] = tags
+		default:
+			body[key] = v
+		}
+	})
+	if update {
+		if len(body) == 0 {
+			return errors.New("targets update: nothing to change, give -f or field flags")
+		}
+		return c.showTarget(c.api.UpdateTarget(c.ctx, id, body))
+	}
+	// round-trip through JSON so a misspelt field is an error rather
+	// than silently left out
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	var t client.Target
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return fmt.Errorf("targets create: %w", err)
+	}
+	return c.showTarget(c.api.CreateTarget(c.ctx, t))
+}
+
+func (c *cli) deleteTarget(args []string) error {
+	fs := c.flags("targets delete")
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	id, err := c.oneTarget(fs, args)
+	if err != nil {
+		return err
+	}
+	if !*yes && !confirm(fmt.Sprintf("Delete target #%d with its checks, outages and logs?", id)) {
+		return errors.New("cancelled")
+	}
+	if err := c.api.DeleteTarget(c.ctx, id); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted target #%d.\n", id)
+	return nil
+}
+
+func (c *cli) pauseTarget(action string, args []string) error {
+	id, err := c.oneTarget(c.flags("targets "+action), args)
+	if err != nil {
+		return err
+	}
+	if action == "pause" {
+		return c.showTarget(c.api.PauseTarget(c.ctx, id))
+	}
+	return c.showTarget(c.api.ResumeTarget(c.ctx, id))
+}
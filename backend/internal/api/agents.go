@@ -1,42 +1,348 @@
 package api
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
 )
 
-type AgentsHandler struct{ Store *store.Store }
+type AgentsHandler struct {
+	Store  store.Storage
+	Alerts *Alerter
+}
 
-func NewAgentsHandler(st *store.Store) *AgentsHandler { return &AgentsHandler{Store: st} }
+func NewAgentsHandler(st store.Storage, alerts *Alerter) *AgentsHandler {
+	return &AgentsHandler{Store: st, Alerts: alerts}
+}
 
 func (h *AgentsHandler) Register(r *gin.Engine) {
 	g := r.Group("/api/agents")
-	g.POST("/register", h.register)
+	agent := g.Group("", RequireAgentKey(h.Store), checkBody)
+	agent.POST("/heartbeat", h.heartbeat)
+	agent.GET("/targets", h.agentTargets)
+
+	viewer := g.Group("", RequireRole(h.Store, store.RoleViewer))
+	viewer.GET("", h.listAgents)
+	viewer.GET("/:id", h.getAgent)
+
+	admin := g.Group("", RequireRole(h.Store, store.RoleAdmin), checkBody)
+	admin.POST("/register", h.register)
+	admin.PATCH("/:id", h.updateAgent)
+	admin.DELETE("/:id", h.revokeAgent)
+	admin.POST("/:id/rotate-key", h.rotateKey)
 }
 
-func (h *AgentsHandler) register(c *gin.Context) {
-	type req struct {
-		Name string `json:"name"`
+// key rotation overlap: how long the previous key keeps working
+const (
+	defaultKeyOverlap = time.Hour
+	maxKeyOverlap     = 7 * 24 * time.Hour
+)
+
+// toAgentDTO is the API view of an agent.
+func toAgentDTO(a store.AgentRow, now time.Time) client.Agent {
+	out := client.Agent{
+		ID:          a.ID,
+		Name:        a.Name,
+		KeyPrefix:   a.KeyPrefix,
+		Labels:      a.Labels,
+		CreatedAt:   a.CreatedAt.UTC().Format(time.RFC3339),
+		Revoked:     a.RevokedAt.Valid,
+		RevokedAt:   rfc3339OrEmpty(a.RevokedAt),
+		LastSeenAt:  rfc3339OrEmpty(a.LastSeenAt),
+		Version:     a.Version,
+		Hostname:    a.Hostname,
+		UptimeSec:   a.UptimeSec,
+		TargetCount: a.TargetCount,
+		SpoolDepth:  a.SpoolDepth,
 	}
-	var body req
+	if a.PrevKeyExpiresAt.Valid && a.PrevKeyExpiresAt.Time.After(now) {
+		out.PrevKeyExpiresAt = rfc3339OrEmpty(a.PrevKeyExpiresAt)
+	}
+	return out
+}
+
+type registerAgentRequest struct {
+	Name string `json:"name"`
+}
+
+type updateAgentRequest struct {
+	Name   *string            `json:"name"`
+	Labels *map[string]string `json:"labels"`
+}
+
+type rotateKeyRequest struct {
+	OverlapSec *int `json:"overlap_sec"`
+}
+
+func (h *AgentsHandler) register(c *gin.Context) {
+	var body registerAgentRequest
 	if err := c.ShouldBindJSON(&body); err != nil || body.Name == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
 		return
 	}
 	key := randKey(32)
-	id, err := h.Store.CreateAgent(c.Request.Context(), body.Name, key)
+	id, err := h.Store.CreateAgent(c.Request.Context(), projectID(c), body.Name, key)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"agent_id": id, "api_key": key})
+	c.JSON(http.StatusOK, client.AgentKey{AgentID: id, APIKey: key})
+}
+
+func (h *AgentsHandler) listAgents(c *gin.Context) {
+	rows, err := h.Store.ListAgents(c.Request.Context(), projectID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list agents"})
+		return
+	}
+	now := time.Now().UTC()
+	out := make([]client.Agent, 0, len(rows))
+	for _, a := range rows {
+		out = append(out, toAgentDTO(a, now))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// loadAgent resolves :id, writing the error response itself when it
+// returns nil.
+func (h *AgentsHandler) loadAgent(c *gin.Context) *store.AgentRow {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil
+	}
+	a, err := h.Store.GetAgent(c.Request.Context(), projectID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load agent"})
+		return nil
+	}
+	if a == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return nil
+	}
+	return a
+}
+
+func (h *AgentsHandler) getAgent(c *gin.Context) {
+	a := h.loadAgent(c)
+	if a == nil {
+		return
+	}
+	c.JSON(http.StatusOK, toAgentDTO(*a, time.Now().UTC()))
+}
+
+func (h *AgentsHandler) updateAgent(c *gin.Context) {
+	a := h.loadAgent(c)
+	if a == nil {
+		return
+	}
+	var body updateAgentRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+	if body.Name != nil {
+		if *body.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		a.Name = *body.Name
+	}
+	if body.Labels != nil {
+		for k := range *body.Labels {
+			if k == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "label keys must not be empty"})
+				return
+			}
+		}
+		a.Labels = *body.Labels
+	}
+	if err := h.Store.UpdateAgent(c.Request.Context(), a.ProjectID, a.ID, a.Name, a.Labels); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toAgentDTO(*a, time.Now().UTC()))
+}
+
+// revokeAgent disables the agent's keys immediately. History is kept, so
+// the agent still shows up in listings, marked revoked.
+func (h *AgentsHandler) revokeAgent(c *gin.Context) {
+	a := h.loadAgent(c)
+	if a == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	now := time.Now().UTC()
+	if err := h.Store.RevokeAgent(ctx, a.ProjectID, a.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "revoke failed"})
+		return
+	}
+	// a revoked agent is expected to go quiet; don't leave it alerting
+	if open, err := h.Store.GetOpenAgentOutage(ctx, a.ID); err == nil && open != nil {
+		_ = h.Store.CloseAgentOutage(ctx, open.ID, now)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// rotateKey issues a new key. The old one keeps working for overlap_sec
+// (default 1h, 0 drops it at once) so agents can be redeployed first.
+func (h *AgentsHandler) rotateKey(c *gin.Context) {
+	a := h.loadAgent(c)
+	if a == nil {
+		return
+	}
+	if a.RevokedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "agent is revoked"})
+		return
+	}
+	var body rotateKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+	}
+	overlap := defaultKeyOverlap
+	if body.OverlapSec != nil {
+		overlap = time.Duration(*body.OverlapSec) * time.Second
+		if overlap < 0 || overlap > maxKeyOverlap {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("overlap_sec must be between 0 and %d", int(maxKeyOverlap.Seconds()))})
+			return
+		}
+	}
+	key := randKey(32)
+	until := time.Now().UTC().Add(overlap)
+	if err := h.Store.RotateAgentKey(c.Request.Context(), a.ProjectID, a.ID, key, until); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "rotate failed"})
+		return
+	}
+	out := client.AgentKey{AgentID: a.ID, APIKey: key}
+	if overlap > 0 {
+		out.PrevKeyExpiresAt = until.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *AgentsHandler) heartbeat(c *gin.Context) {
+	var hb store.Heartbeat
+	if err := c.ShouldBindJSON(&hb); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	id := c.GetInt64("agent_id")
+	now := time.Now().UTC()
+	if err := h.Store.RecordHeartbeat(c.Request.Context(), id, hb, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "heartbeat failed"})
+		return
+	}
+	markAgentSeen(c.Request.Context(), h.Store, h.Alerts, projectID(c), id, now)
+	c.Status(http.StatusNoContent)
+}
+
+// markAgentSeen records contact from an agent and ends its offline period.
+func markAgentSeen(ctx context.Context, st store.Storage, alerts *Alerter, projectID, agentID int64, at time.Time) {
+	_ = st.TouchAgent(ctx, agentID, at)
+	open, err := st.GetOpenAgentOutage(ctx, agentID)
+	if err != nil || open == nil {
+		return
+	}
+	if err := st.CloseAgentOutage(ctx, open.ID, at); err != nil {
+		return
+	}
+	since := open.StartedAt.UTC().Format(time.RFC3339)
+	fmt.Printf("RESOLVED agent #%d back online (offline since %s)\n", agentID, since)
+	if ag, err := st.GetAgent(ctx, projectID, agentID); err == nil && ag != nil {
+		alerts.NotifyAgent(*ag, eventAgentOnline, "offline since "+since, at)
+	}
+}
+
+// WatchOffline checks every interval for agents that have gone quiet for
+// longer than offlineAfter, opens an agent outage and sends an
+// agent_offline alert.
+// Revoked agents and agents never seen are ignored. Runs until ctx is done.
+func (h *AgentsHandler) WatchOffline(ctx context.Context, offlineAfter, every time.Duration) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		agents, err := h.Store.ListAllAgents(ctx)
+		if err != nil {
+			continue
+		}
+		now := time.Now().UTC()
+		for _, a := range agents {
+			if a.RevokedAt.Valid || !a.LastSeenAt.Valid || now.Sub(a.LastSeenAt.Time) < offlineAfter {
+				continue
+			}
+			open, err := h.Store.GetOpenAgentOutage(ctx, a.ID)
+			if err != nil || open != nil {
+				continue
+			}
+			if err := h.Store.OpenAgentOutage(ctx, a.ID, a.LastSeenAt.Time); err == nil {
+				seen := a.LastSeenAt.Time.UTC().Format(time.RFC3339)
+				fmt.Printf("ALERT agent #%d %q offline: last seen %s\n", a.ID, a.Name, seen)
+				h.Alerts.NotifyAgent(a, eventAgentOffline, "last seen "+seen, now)
+			}
+		}
+	}
+}
+
+// agentTargets is what agents sync from: the active targets of the agent's
+// project whose agent_selector matches the agent's labels. The ETag lets
+// agents poll cheaply; an unchanged list is a 304.
+func (h *AgentsHandler) agentTargets(c *gin.Context) {
+	ctx := c.Request.Context()
+	ag, err := h.Store.GetAgent(ctx, projectID(c), c.GetInt64("agent_id"))
+	if err != nil || ag == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load agent"})
+		return
+	}
+	rows, err := h.Store.ListTargets(ctx, projectID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list targets"})
+		return
+	}
+	active := make([]store.TargetRow, 0, len(rows))
+	for _, t := range rows {
+		if !t.Paused && labelsMatch(t.AgentSelector, ag.Labels) {
+			active = append(active, t)
+		}
+	}
+	b, _ := json.Marshal(active)
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", b)
+}
+
+func labelsMatch(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
 }
 
-func RequireAgentKey(st *store.Store) gin.HandlerFunc {
+func RequireAgentKey(st store.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		key := c.GetHeader("X-Api-Key")
 		if key == "" {
@@ -49,6 +355,7 @@ func RequireAgentKey(st *store.Store) gin.HandlerFunc {
 			return
 		}
 		c.Set("agent_id", ag.ID)
+		c.Set("project_id", ag.ProjectID)
 		c.Next()
 	}
 }
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+)
+
+// events sent to alert route webhooks
+const (
+	eventOutageOpened   = "outage_opened"
+	eventOutageResolved = "outage_resolved"
+	eventAgentOffline   = "agent_offline"
+	eventAgentOnline    = "agent_online"
+)
+
+// Alerter posts outage events to the webhooks of the alert routes that
+// match a target, unless a maintenance window covering the target is open.
+// Agent events go to routes with an empty match, and are held back by
+// windows with an empty match. Delivery is best effort: one attempt per
+// route, failures are logged.
+type Alerter struct {
+	Store  store.Storage
+	Client *http.Client
+}
+
+func NewAlerter(st store.Storage) *Alerter {
+	return &Alerter{Store: st, Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+type alertPayload struct {
+	Event     string       `json:"event"`
+	ProjectID int64        `json:"project_id"`
+	Target    *alertTarget `json:"target,omitempty"`
+	Agent     *alertAgent  `json:"agent,omitempty"`
+	Reason    string       `json:"reason,omitempty"`
+	At        string       `json:"at"`
+}
+
+type alertTarget struct {
+	ID    int64    `json:"id"`
+	Key   string   `json:"key,omitempty"`
+	Name  string   `json:"name"`
+	URL   string   `json:"url"`
+	Group string   `json:"group,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type alertAgent struct {
+	ID     int64             `json:"id"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Notify sends event for t in the background.
+func (a *Alerter) Notify(t store.TargetRow, event, reason string, at time.Time) {
+	if a == nil {
+		return
+	}
+	p := alertPayload{
+		Event:     event,
+		ProjectID: t.ProjectID,
+		Target:    &alertTarget{ID: t.ID, Key: t.Key, Name: t.Name, URL: t.URL, Group: t.Group, Tags: t.Tags},
+		Reason:    reason,
+		At:        at.UTC().Format(time.RFC3339),
+	}
+	go a.send(context.Background(), fmt.Sprintf("target #%d", t.ID), p, at,
+		func(sel store.Selector) bool { return sel.Matches(t) })
+}
+
+// NotifyAgent sends event for ag in the background.
+func (a *Alerter) NotifyAgent(ag store.AgentRow, event, reason string, at time.Time) {
+	if a == nil {
+		return
+	}
+	p := alertPayload{
+		Event:     event,
+		ProjectID: ag.ProjectID,
+		Agent:     &alertAgent{ID: ag.ID, Name: ag.Name, Labels: ag.Labels},
+		Reason:    reason,
+		At:        at.UTC().Format(time.RFC3339),
+	}
+	go a.send(context.Background(), fmt.Sprintf("agent #%d", ag.ID), p, at, store.Selector.Empty)
+}
+
+// send posts p to the project's routes whose match passes matches, unless
+// a maintenance window whose match passes is open at at.
+func (a *Alerter) send(ctx context.Context, subject string, p alertPayload, at time.Time, matches func(store.Selector) bool) {
+	routes, err := a.Store.ListAlertRoutes(ctx, p.ProjectID)
+	if err != nil || len(routes) == 0 {
+		return
+	}
+	windows, err := a.Store.ActiveMaintenanceWindows(ctx, p.ProjectID, at)
+	if err != nil {
+		return
+	}
+	for _, w := range windows {
+		if matches(w.Match) {
+			fmt.Printf("alert for %s suppressed by maintenance window %q\n", subject, w.Key)
+			return
+		}
+	}
+	body, _ := json.Marshal(p)
+	for _, r := range routes {
+		if !matches(r.Match) {
+			continue
+		}
+		if err := a.post(ctx, r.WebhookURL, body); err != nil {
+			fmt.Printf("alert route %q: %v\n", r.Key, err)
+		}
+	}
+}
+
+func (a *Alerter) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,583 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+)
+
+// Management routes are guarded by RequireRole. Callers send a bearer token,
+// either a login session or a long-lived token from /api/tokens; the
+// dashboard gets the session as a cookie instead. Every token is scoped to
+// one project and handlers read it with projectID.
+
+const sessionCookie = "sp_session"
+
+// password bounds; bcrypt ignores anything past 72 bytes
+const (
+	minPasswordLen = 8
+	maxPasswordLen = 72
+)
+
+var roleRank = map[string]int{
+	store.RoleViewer: 1,
+	store.RoleEditor: 2,
+	store.RoleAdmin:  3,
+}
+
+type AuthHandler struct {
+	Store      store.Storage
+	SessionTTL time.Duration
+}
+
+func NewAuthHandler(st store.Storage, sessionTTL time.Duration) *AuthHandler {
+	return &AuthHandler{Store: st, SessionTTL: sessionTTL}
+}
+
+func (h *AuthHandler) Register(r *gin.Engine) {
+	r.POST("/api/auth/login", checkBody, h.login)
+	a := r.Group("/api/auth", RequireRole(h.Store, store.RoleViewer), checkBody)
+	a.POST("/logout", h.logout)
+	a.GET("/me", h.me)
+	a.POST("/project", h.switchProject)
+
+	u := r.Group("/api/users", RequireRole(h.Store, store.RoleAdmin), checkBody)
+	u.GET("", h.listUsers)
+	u.POST("", h.createUser)
+	u.PATCH("/:id", h.updateUser)
+	u.DELETE("/:id", h.deleteUser)
+
+	// every user manages their own tokens
+	t := r.Group("/api/tokens", RequireRole(h.Store, store.RoleViewer), checkBody)
+	t.GET("", h.listTokens)
+	t.POST("", h.createToken)
+	t.DELETE("/:id", h.deleteToken)
+}
+
+// Bootstrap creates the first admin when there are no users yet. Without a
+// password one is generated and printed once.
+func (h *AuthHandler) Bootstrap(ctx context.Context, username, password string) error {
+	n, err := h.Store.CountUsers(ctx)
+	if err != nil || n > 0 {
+		return err
+	}
+	generated := password == ""
+	if generated {
+		password = randKey(12)
+	}
+	id, err := h.Store.CreateUser(ctx, username, password, store.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := h.Store.AddProjectMember(ctx, store.DefaultProjectID, id); err != nil {
+		return err
+	}
+	if generated {
+		fmt.Printf("Created admin user %q with password %s (set ADMIN_PASSWORD to choose it)\n", username, password)
+	} else {
+		fmt.Printf("Created admin user %q\n", username)
+	}
+	return nil
+}
+
+// RequireRole lets the request through if it carries a valid token for a
+// user with at least the given role.
+func RequireRole(st store.Storage, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tok := bearerToken(c)
+		if tok == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+			return
+		}
+		u, t, err := st.FindUserByToken(c.Request.Context(), tok)
+		if err != nil || u == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		if roleRank[u.Role] < roleRank[role] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": role + " role required"})
+			return
+		}
+		c.Set("user_id", u.ID)
+		c.Set("role", u.Role)
+		c.Set("token_id", t.ID)
+		c.Set("session", t.Session)
+		c.Set("project_id", t.ProjectID)
+		c.Next()
+	}
+}
+
+// projectID is the project the request is scoped to, set by RequireRole
+// from the caller's token or by RequireAgentKey from the agent.
+func projectID(c *gin.Context) int64 { return c.GetInt64("project_id") }
+
+// userProjects lists the projects u may use: all of them for admins,
+// their memberships for everyone else.
+func userProjects(ctx context.Context, st store.Storage, u *store.UserRow) ([]store.ProjectRow, error) {
+	if u.Role == store.RoleAdmin {
+		return st.ListProjects(ctx)
+	}
+	return st.ListUserProjects(ctx, u.ID)
+}
+
+func canUseProject(ctx context.Context, st store.Storage, u *store.UserRow, pid int64) (bool, error) {
+	ps, err := userProjects(ctx, st, u)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range ps {
+		if p.ID == pid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func bearerToken(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	if v, err := c.Cookie(sessionCookie); err == nil {
+		return v
+	}
+	return ""
+}
+
+type userDTO struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+	Disabled  bool   `json:"disabled"`
+}
+
+func toUserDTO(u store.UserRow) userDTO {
+	return userDTO{
+		ID:        u.ID,
+		Username:  u.Username,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt.UTC().Format(time.RFC3339),
+		Disabled:  u.DisabledAt.Valid,
+	}
+}
+
+type tokenDTO struct {
+	ID        int64  `json:"id"`
+	ProjectID int64  `json:"project_id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// newTokenDTO is a token as created; the token itself is only shown here.
+type newTokenDTO struct {
+	ID        int64  `json:"id"`
+	ProjectID int64  `json:"project_id"`
+	Name      string `json:"name"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+type loginRequest struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	ProjectID int64  `json:"project_id"` // defaults to the first project the user can use
+}
+
+type loginResponse struct {
+	Token     string  `json:"token"`
+	ExpiresAt string  `json:"expires_at"`
+	User      userDTO `json:"user"`
+	ProjectID int64   `json:"project_id"`
+}
+
+type meResponse struct {
+	User      userDTO      `json:"user"`
+	ProjectID int64        `json:"project_id"`
+	Projects  []projectDTO `json:"projects"`
+}
+
+// projectSwitch is both the request and the response of POST /api/auth/project.
+type projectSwitch struct {
+	ProjectID int64 `json:"project_id"`
+}
+
+type createUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type updateUserRequest struct {
+	Role     *string `json:"role"`
+	Password *string `json:"password"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type createTokenRequest struct {
+	Name         string `json:"name"`
+	ProjectID    int64  `json:"project_id"`
+	ExpiresInSec int64  `json:"expires_in_sec"`
+}
+
+func rfc3339OrEmpty(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.UTC().Format(time.RFC3339)
+}
+
+// -------- Handlers --------
+
+func (h *AuthHandler) login(c *gin.Context) {
+	var body loginRequest
+	if err := c.ShouldBindJSON(&body); err != nil || body.Username == "" || body.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and password required"})
+		return
+	}
+	ctx := c.Request.Context()
+	u, err := h.Store.AuthenticateUser(ctx, body.Username, body.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if u == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
+	}
+	projects, err := userProjects(ctx, h.Store, u)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if len(projects) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no project access"})
+		return
+	}
+	pid := projects[0].ID
+	if body.ProjectID != 0 {
+		pid = 0
+		for _, p := range projects {
+			if p.ID == body.ProjectID {
+				pid = p.ID
+			}
+		}
+		if pid == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no access to project"})
+			return
+		}
+	}
+	tok := randKey(32)
+	exp := time.Now().UTC().Add(h.SessionTTL)
+	if _, err := h.Store.CreateSession(ctx, u.ID, pid, tok, exp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(sessionCookie, tok, int(h.SessionTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+	c.JSON(http.StatusOK, loginResponse{
+		Token:     tok,
+		ExpiresAt: exp.Format(time.RFC3339),
+		User:      toUserDTO(*u),
+		ProjectID: pid,
+	})
+}
+
+func (h *AuthHandler) logout(c *gin.Context) {
+	_, _ = h.Store.DeleteToken(c.Request.Context(), c.GetInt64("user_id"), c.GetInt64("token_id"))
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthHandler) me(c *gin.Context) {
+	ctx := c.Request.Context()
+	u, err := h.Store.GetUser(ctx, c.GetInt64("user_id"))
+	if err != nil || u == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
+	projects, err := userProjects(ctx, h.Store, u)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load projects"})
+		return
+	}
+	out := make([]projectDTO, 0, len(projects))
+	for _, p := range projects {
+		out = append(out, toProjectDTO(p))
+	}
+	c.JSON(http.StatusOK, meResponse{User: toUserDTO(*u), ProjectID: projectID(c), Projects: out})
+}
+
+// switchProject re-scopes the current login session, which is how the
+// dashboard moves between projects without logging in again.
+func (h *AuthHandler) switchProject(c *gin.Context) {
+	// an API token stays in the project it was issued for
+	if !c.GetBool("session") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only login sessions can switch project"})
+		return
+	}
+	var body projectSwitch
+	if err := c.ShouldBindJSON(&body); err != nil || body.ProjectID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "project_id required"})
+		return
+	}
+	ctx := c.Request.Context()
+	u, err := h.Store.GetUser(ctx, c.GetInt64("user_id"))
+	if err != nil || u == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
+	ok, err := canUseProject(ctx, h.Store, u, body.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "switch failed"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no access to project"})
+		return
+	}
+	if err := h.Store.SetTokenProject(ctx, c.GetInt64("token_id"), body.ProjectID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "switch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, projectSwitch{ProjectID: body.ProjectID})
+}
+
+func (h *AuthHandler) listUsers(c *gin.Context) {
+	rows, err := h.Store.ListUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users"})
+		return
+	}
+	out := make([]userDTO, 0, len(rows))
+	for _, u := range rows {
+		out = append(out, toUserDTO(u))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func validPassword(pw string) error {
+	if len(pw) < minPasswordLen || len(pw) > maxPasswordLen {
+		return fmt.Errorf("password must be %d to %d bytes", minPasswordLen, maxPasswordLen)
+	}
+	return nil
+}
+
+func (h *AuthHandler) createUser(c *gin.Context) {
+	var body createUserRequest
+	if err := c.ShouldBindJSON(&body); err != nil || body.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
+		return
+	}
+	if roleRank[body.Role] == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be viewer, editor or admin"})
+		return
+	}
+	if err := validPassword(body.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	id, err := h.Store.CreateUser(ctx, body.Username, body.Password, body.Role)
+	if err != nil {
+		if store.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "username taken"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	u, err := h.Store.GetUser(ctx, id)
+	if err != nil || u == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, toUserDTO(*u))
+}
+
+// loadUser resolves :id, writing the error response itself when it
+// returns nil.
+func (h *AuthHandler) loadUser(c *gin.Context) *store.UserRow {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil
+	}
+	u, err := h.Store.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return nil
+	}
+	if u == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil
+	}
+	return u
+}
+
+func (h *AuthHandler) updateUser(c *gin.Context) {
+	u := h.loadUser(c)
+	if u == nil {
+		return
+	}
+	var body updateUserRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+	role, disabled := u.Role, u.DisabledAt.Valid
+	if body.Role != nil {
+		if roleRank[*body.Role] == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be viewer, editor or admin"})
+			return
+		}
+		role = *body.Role
+	}
+	if body.Disabled != nil {
+		disabled = *body.Disabled
+	}
+	// an admin locking themselves out is almost always a mistake
+	if u.ID == c.GetInt64("user_id") && (role != u.Role || disabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot change your own role or disable yourself"})
+		return
+	}
+	var password string
+	if body.Password != nil {
+		if err := validPassword(*body.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		password = *body.Password
+	}
+
+	ctx := c.Request.Context()
+	if err := h.Store.UpdateUser(ctx, u.ID, role, disabled, password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	u, err := h.Store.GetUser(ctx, u.ID)
+	if err != nil || u == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toUserDTO(*u))
+}
+
+func (h *AuthHandler) deleteUser(c *gin.Context) {
+	u := h.loadUser(c)
+	if u == nil {
+		return
+	}
+	if u.ID == c.GetInt64("user_id") {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete yourself"})
+		return
+	}
+	if err := h.Store.DeleteUser(c.Request.Context(), u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthHandler) listTokens(c *gin.Context) {
+	rows, err := h.Store.ListTokens(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list tokens"})
+		return
+	}
+	out := make([]tokenDTO, 0, len(rows))
+	for _, t := range rows {
+		out = append(out, tokenDTO{
+			ID:        t.ID,
+			ProjectID: t.ProjectID,
+			Name:      t.Name,
+			Prefix:    t.Prefix,
+			CreatedAt: t.CreatedAt.UTC().Format(time.RFC3339),
+			ExpiresAt: rfc3339OrEmpty(t.ExpiresAt),
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// createToken issues a token with the caller's role, for scripts and CLIs.
+// It is scoped to project_id, or the caller's current project; only a login
+// session may pick another project. expires_in_sec 0 or omitted means it
+// never expires.
+func (h *AuthHandler) createToken(c *gin.Context) {
+	var body createTokenRequest
+	if err := c.ShouldBindJSON(&body); err != nil || body.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	if body.ExpiresInSec < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_sec must not be negative"})
+		return
+	}
+	ctx := c.Request.Context()
+	pid := projectID(c)
+	if body.ProjectID != 0 && body.ProjectID != pid {
+		// an API token can't mint tokens outside the project it was issued for
+		if !c.GetBool("session") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only login sessions can create tokens for another project"})
+			return
+		}
+		u, err := h.Store.GetUser(ctx, c.GetInt64("user_id"))
+		if err != nil || u == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+			return
+		}
+		ok, err := canUseProject(ctx, h.Store, u, body.ProjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no access to project"})
+			return
+		}
+		pid = body.ProjectID
+	}
+	var exp *time.Time
+	if body.ExpiresInSec > 0 {
+		t := time.Now().UTC().Add(time.Duration(body.ExpiresInSec) * time.Second)
+		exp = &t
+	}
+	tok := randKey(32)
+	id, err := h.Store.CreateToken(ctx, c.GetInt64("user_id"), pid, body.Name, tok, exp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	out := newTokenDTO{ID: id, ProjectID: pid, Name: body.Name, Token: tok}
+	if exp != nil {
+		out.ExpiresAt = exp.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *AuthHandler) deleteToken(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ok, err := h.Store.DeleteToken(c.Request.Context(), c.GetInt64("user_id"), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
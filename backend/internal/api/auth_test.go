@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+)
+
+// An API token stays in its project, so it can't be used to mint a token
+// for another project the user belongs to; a login session can.
+func TestCreateTokenForAnotherProject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	st, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	a := int64(store.DefaultProjectID)
+	b, err := st.CreateProject(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, err := st.CreateUser(ctx, "editor", "correct horse", store.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pid := range []int64{a, b} {
+		if err := st.AddProjectMember(ctx, pid, uid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const apiToken, session = "apitoken-0123456789abcdef", "session-0123456789abcdef"
+	if _, err := st.CreateToken(ctx, uid, a, "ci", apiToken, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateSession(ctx, uid, a, session, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	NewAuthHandler(st, time.Hour).Register(r)
+	for _, tc := range []struct {
+		token string
+		want  int
+	}{
+		{apiToken, http.StatusForbidden},
+		{session, http.StatusCreated},
+	} {
+		body := `{"name":"escape","project_id":` + strconv.FormatInt(b, 10) + `}`
+		req := httptest.NewRequest(http.MethodPost, "/api/tokens", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: got %d %s, want %d", tc.token, w.Code, w.Body, tc.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+)
+
+// BackupHandler gives admins database snapshots and portable exports of
+// every project. Restoring a snapshot is done offline with `server restore`.
+type BackupHandler struct{ Store store.Storage }
+
+func NewBackupHandler(st store.Storage) *BackupHandler { return &BackupHandler{Store: st} }
+
+func (h *BackupHandler) Register(r *gin.Engine) {
+	// no checkBody: imports can be far larger than a request body it
+	// reads, and are streamed and checked record by record instead
+	g := r.Group("/api", RequireRole(h.Store, store.RoleAdmin))
+	g.GET("/backup", h.backup)
+	g.GET("/export", h.export)
+	g.POST("/import", h.importData)
+}
+
+// importResponse counts the imported records by type.
+type importResponse struct {
+	Imported store.ImportResult `json:"imported"`
+}
+
+// backup snapshots the SQLite database into a temporary file and sends it.
+func (h *BackupHandler) backup(c *gin.Context) {
+	dir, err := os.MkdirTemp("", "status-backup")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "backup failed"})
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	name := "status-" + time.Now().UTC().Format("20060102T150405Z") + ".db"
+	path := filepath.Join(dir, name)
+	if err := h.Store.Backup(c.Request.Context(), path); err != nil {
+		if errors.Is(err, store.ErrBackupUnsupported) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Printf("[backup] failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "backup failed"})
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// export streams the export as NDJSON, or as a JSON array with
+// ?format=json.
+func (h *BackupHandler) export(c *gin.Context) {
+	format := c.DefaultQuery("format", "ndjson")
+	ctype, ext := "application/x-ndjson", "ndjson"
+	switch format {
+	case "ndjson":
+	case "json":
+		ctype, ext = "application/json", "json"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson or json"})
+		return
+	}
+	name := "status-export-" + time.Now().UTC().Format("20060102T150405Z") + "." + ext
+	c.Header("Content-Type", ctype)
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	if err := h.Store.Export(c.Request.Context(), c.Writer, format == "json"); err != nil {
+		fmt.Printf("[export] failed: %v\n", err)
+		if !c.Writer.Written() {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "export failed"})
+			return
+		}
+		// the status is already sent; the missing end record tells
+		// Import the file is incomplete
+	}
+}
+
+// importData loads an export into a server without targets or agents.
+func (h *BackupHandler) importData(c *gin.Context) {
+	res, err := h.Store.Import(c.Request.Context(), c.Request.Body)
+	if err != nil {
+		if errors.Is(err, store.ErrImportNotEmpty) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "import failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, importResponse{Imported: res})
+}
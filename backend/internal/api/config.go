@@ -0,0 +1,655 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigHandler serves declarative configuration. A manifest describes a
+// project's groups, targets, agent labels, alert routes and maintenance
+// windows by stable keys; plan diffs it against the store and apply makes
+// the store match. Applying the same manifest twice changes nothing.
+type ConfigHandler struct {
+	Store store.Storage
+
+	applyMu sync.Mutex // one apply at a time, so plans don't interleave
+}
+
+func NewConfigHandler(st store.Storage) *ConfigHandler { return &ConfigHandler{Store: st} }
+
+func (h *ConfigHandler) Register(r *gin.Engine) {
+	g := r.Group("/api/config")
+	g.GET("/export", RequireRole(h.Store, store.RoleViewer), h.export)
+	g.Group("", RequireRole(h.Store, store.RoleEditor), checkBody).POST("/plan", h.plan)
+	g.Group("", RequireRole(h.Store, store.RoleAdmin), checkBody).POST("/apply", h.apply)
+}
+
+const manifestVersion = 1
+
+// manifest is the file format, in YAML or JSON. A section that is left out
+// is not managed: its objects are neither changed nor deleted. A section
+// that is present, even empty, is authoritative for its kind.
+type manifest struct {
+	Version            int                           `json:"version"`
+	Groups             *[]store.GroupRow             `json:"groups,omitempty"`
+	Targets            *[]store.TargetRow            `json:"targets,omitempty"`
+	Agents             *[]agentSpec                  `json:"agents,omitempty"`
+	AlertRoutes        *[]store.AlertRouteRow        `json:"alert_routes,omitempty"`
+	MaintenanceWindows *[]store.MaintenanceWindowRow `json:"maintenance_windows,omitempty"`
+}
+
+// agentSpec sets the labels of an agent registered elsewhere; agents can't
+// be created from config because their keys are secrets.
+type agentSpec struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// change is one step of a plan. run performs it on the apply's
+// transaction.
+type change struct {
+	client.Change
+	run func(ctx context.Context, st store.Storage) error
+}
+
+type planResult struct {
+	Changes []change       `json:"changes"`
+	Summary map[string]int `json:"summary"`
+	Applied bool           `json:"applied"`
+}
+
+// -------- Handlers --------
+
+func (h *ConfigHandler) plan(c *gin.Context) {
+	p, ok := h.buildPlan(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *ConfigHandler) apply(c *gin.Context) {
+	h.applyMu.Lock()
+	defer h.applyMu.Unlock()
+	p, ok := h.buildPlan(c)
+	if !ok {
+		return
+	}
+	// all changes commit together, so a failure leaves the project as it was
+	ctx := c.Request.Context()
+	err := h.Store.WithTx(ctx, func(st store.Storage) error {
+		for _, ch := range p.Changes {
+			if err := ch.run(ctx, st); err != nil {
+				return fmt.Errorf("%s %s %q failed: %v", ch.Action, ch.Kind, ch.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "apply failed, nothing was changed: " + err.Error()})
+		return
+	}
+	p.Applied = true
+	c.JSON(http.StatusOK, p)
+}
+
+// export returns the project as a manifest, as JSON or with ?format=yaml.
+// Targets created through the API get a key derived from their name, so
+// applying the export adopts them into config.
+func (h *ConfigHandler) export(c *gin.Context) {
+	ctx := c.Request.Context()
+	pid := projectID(c)
+	m, err := h.current(ctx, pid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export failed"})
+		return
+	}
+	b, _ := json.MarshalIndent(m, "", "  ")
+	if c.Query("format") != "yaml" {
+		c.Data(http.StatusOK, "application/json", b)
+		return
+	}
+	y, err := jsonToYAML(b)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export failed"})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", y)
+}
+
+// -------- Manifest parsing --------
+
+// parseManifest accepts YAML or JSON (JSON is valid YAML). Unknown fields
+// are errors so typos don't silently drop settings.
+func parseManifest(body []byte) (*manifest, error) {
+	var doc any
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	var m manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("version must be %d", manifestVersion)
+	}
+	return &m, nil
+}
+
+// jsonToYAML re-encodes JSON as block-style YAML, keeping key order.
+// Strings are only quoted where YAML needs it to keep them strings.
+func jsonToYAML(js []byte) ([]byte, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(js, &n); err != nil {
+		return nil, err
+	}
+	var plain func(*yaml.Node)
+	plain = func(n *yaml.Node) {
+		n.Style = 0
+		for _, c := range n.Content {
+			plain(c)
+		}
+	}
+	plain(&n)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// -------- Planning --------
+
+// buildPlan parses the body and diffs it against the caller's project,
+// writing the error response itself when ok is false.
+func (h *ConfigHandler) buildPlan(c *gin.Context) (p *planResult, ok bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 4<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return nil, false
+	}
+	m, err := parseManifest(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid manifest: " + err.Error()})
+		return nil, false
+	}
+	pl := &planner{st: h.Store, pid: projectID(c), now: time.Now().UTC()}
+	if err := pl.load(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load current config"})
+		return nil, false
+	}
+	pl.diff(m)
+	if len(pl.fe) > 0 {
+		writeFieldErrors(c, http.StatusBadRequest, pl.fe)
+		return nil, false
+	}
+	// creates and updates first so nothing still in use is deleted early
+	changes := append(pl.upserts, pl.deletes...)
+	summary := map[string]int{"create": 0, "update": 0, "delete": 0}
+	for _, ch := range changes {
+		summary[ch.Action]++
+	}
+	if changes == nil {
+		changes = []change{}
+	}
+	return &planResult{Changes: changes, Summary: summary}, true
+}
+
+// planner holds the project's current state while a manifest is diffed
+// against it.
+type planner struct {
+	st  store.Storage
+	pid int64
+	now time.Time
+
+	groups  []store.GroupRow
+	targets []store.TargetRow
+	agents  []store.AgentRow
+	routes  []store.AlertRouteRow
+	windows []store.MaintenanceWindowRow
+
+	// keys that will exist after apply, for checking references
+	groupKeys  map[string]bool
+	targetKeys map[string]bool
+
+	fe      fieldErrors
+	upserts []change
+	deletes []change
+}
+
+func (pl *planner) load(ctx context.Context) error {
+	var err error
+	if pl.groups, err = pl.st.ListGroups(ctx, pl.pid); err != nil {
+		return err
+	}
+	if pl.targets, err = pl.st.ListTargets(ctx, pl.pid); err != nil {
+		return err
+	}
+	if pl.agents, err = pl.st.ListAgents(ctx, pl.pid); err != nil {
+		return err
+	}
+	if pl.routes, err = pl.st.ListAlertRoutes(ctx, pl.pid); err != nil {
+		return err
+	}
+	pl.windows, err = pl.st.ListMaintenanceWindows(ctx, pl.pid)
+	return err
+}
+
+func (pl *planner) diff(m *manifest) {
+	pl.groupKeys = map[string]bool{}
+	if m.Groups != nil {
+		for _, g := range *m.Groups {
+			pl.groupKeys[g.Key] = true
+		}
+	} else {
+		for _, g := range pl.groups {
+			pl.groupKeys[g.Key] = true
+		}
+	}
+	pl.targetKeys = map[string]bool{}
+	if m.Targets != nil {
+		for _, t := range *m.Targets {
+			pl.targetKeys[t.Key] = true
+		}
+	} else {
+		for _, t := range pl.targets {
+			if t.Key != "" {
+				pl.targetKeys[t.Key] = true
+			}
+		}
+	}
+
+	if m.Groups != nil {
+		pl.diffGroups(*m.Groups)
+	}
+	if m.Targets != nil {
+		pl.diffTargets(*m.Targets)
+	}
+	if m.Agents != nil {
+		pl.diffAgents(*m.Agents)
+	}
+	if m.AlertRoutes != nil {
+		pl.diffRoutes(*m.AlertRoutes)
+	}
+	if m.MaintenanceWindows != nil {
+		pl.diffWindows(*m.MaintenanceWindows)
+	}
+}
+
+func (pl *planner) upsert(ch change) { pl.upserts = append(pl.upserts, ch) }
+func (pl *planner) remove(ch change) { pl.deletes = append(pl.deletes, ch) }
+
+// checkKey validates the key of section[i] and reports duplicates.
+func (pl *planner) checkKey(field, key string, seen map[string]bool) {
+	switch {
+	case key == "":
+		pl.fe.add(field, "required")
+	case !keyRe.MatchString(key):
+		pl.fe.add(field, "%s", keyRule)
+	case seen[key]:
+		pl.fe.add(field, "duplicate key %q", key)
+	}
+	seen[key] = true
+}
+
+func (pl *planner) checkSelector(field string, sel store.Selector) {
+	for i, k := range sel.Targets {
+		if !pl.targetKeys[k] {
+			pl.fe.add(fmt.Sprintf("%s.targets[%d]", field, i), "no target with key %q", k)
+		}
+	}
+	for i, k := range sel.Groups {
+		if !pl.groupKeys[k] {
+			pl.fe.add(fmt.Sprintf("%s.groups[%d]", field, i), "no group with key %q", k)
+		}
+	}
+}
+
+func (pl *planner) diffGroups(want []store.GroupRow) {
+	cur := map[string]store.GroupRow{}
+	for _, g := range pl.groups {
+		cur[g.Key] = g
+	}
+	seen := map[string]bool{}
+	for i, g := range want {
+		field := fmt.Sprintf("groups[%d]", i)
+		pl.checkKey(field+".key", g.Key, seen)
+		g.Name = strings.TrimSpace(g.Name)
+		if g.Name == "" {
+			pl.fe.add(field+".name", "required")
+		}
+		g.ProjectID = pl.pid
+		old, exists := cur[g.Key]
+		switch {
+		case !exists:
+			pl.upsert(change{Change: client.Change{Kind: "group", Key: g.Key, Action: "create"},
+				run: func(ctx context.Context, st store.Storage) error { return st.InsertGroup(ctx, g) }})
+		default:
+			if fields := changedFields(old, g); len(fields) > 0 {
+				pl.upsert(change{Change: client.Change{Kind: "group", Key: g.Key, Action: "update", Fields: fields},
+					run: func(ctx context.Context, st store.Storage) error { return st.UpdateGroup(ctx, g) }})
+			}
+		}
+	}
+	for _, g := range pl.groups {
+		if !seen[g.Key] {
+			key := g.Key
+			pl.remove(change{Change: client.Change{Kind: "group", Key: key, Action: "delete"},
+				run: func(ctx context.Context, st store.Storage) error { return st.DeleteGroup(ctx, pl.pid, key) }})
+		}
+	}
+}
+
+// diffTargets matches targets by key. A target created through the API
+// with the same name as a keyed target in the manifest is adopted: it
+// gets the key, and keeps its id and history.
+func (pl *planner) diffTargets(want []store.TargetRow) {
+	byKey := map[string]store.TargetRow{}
+	unkeyed := map[string]store.TargetRow{}
+	for _, t := range pl.targets {
+		if t.Key != "" {
+			byKey[t.Key] = t
+		} else {
+			unkeyed[strings.ToLower(t.Name)] = t
+		}
+	}
+	seen := map[string]bool{}
+	names := map[string]bool{}
+	for i, t := range want {
+		field := fmt.Sprintf("targets[%d]", i)
+		pl.checkKey(field+".key", t.Key, seen)
+		for _, e := range validateTarget(&t) {
+			pl.fe.add(field+"."+e.Field, "%s", e.Message)
+		}
+		if t.Group != "" && !pl.groupKeys[t.Group] {
+			pl.fe.add(field+".group", "no group with key %q", t.Group)
+		}
+		if lower := strings.ToLower(t.Name); names[lower] {
+			pl.fe.add(field+".name", "duplicate name %q", t.Name)
+		} else {
+			names[lower] = true
+		}
+
+		old, exists := byKey[t.Key]
+		if !exists {
+			old, exists = unkeyed[strings.ToLower(t.Name)]
+			delete(unkeyed, strings.ToLower(t.Name))
+		}
+		if !exists {
+			t.ID, t.ProjectID, t.CreatedAt = 0, pl.pid, pl.now
+			paused := t.Paused
+			t.Paused = false
+			pl.upsert(change{Change: client.Change{Kind: "target", Key: t.Key, Action: "create"},
+				run: func(ctx context.Context, st store.Storage) error {
+					id, err := st.InsertTarget(ctx, t)
+					if err != nil || !paused {
+						return err
+					}
+					return pauseAndEndOutage(ctx, st, pl.pid, id, pl.now)
+				}})
+			continue
+		}
+		t.ID, t.ProjectID, t.CreatedAt = old.ID, old.ProjectID, old.CreatedAt
+		fields := changedFields(old, t, "id", "project_id", "created_at")
+		if len(fields) == 0 {
+			continue
+		}
+		pl.upsert(change{Change: client.Change{Kind: "target", Key: t.Key, Action: "update", Fields: fields},
+			run: func(ctx context.Context, st store.Storage) error {
+				if len(fields) > 1 || fields[0] != "paused" {
+					if err := st.UpdateTarget(ctx, t); err != nil {
+						return err
+					}
+				}
+				switch {
+				case t.Paused && !old.Paused:
+					return pauseAndEndOutage(ctx, st, pl.pid, t.ID, pl.now)
+				case !t.Paused && old.Paused:
+					_, err := st.ResumeTarget(ctx, pl.pid, t.ID, pl.now)
+					return err
+				}
+				return nil
+			}})
+	}
+	for _, t := range pl.targets {
+		if t.Key != "" && !seen[t.Key] {
+			id := t.ID
+			pl.remove(change{Change: client.Change{Kind: "target", Key: t.Key, Action: "delete"},
+				run: func(ctx context.Context, st store.Storage) error { return st.DeleteTarget(ctx, pl.pid, id) }})
+		}
+	}
+}
+
+// diffAgents only updates labels; agents missing from the manifest are
+// left alone.
+func (pl *planner) diffAgents(want []agentSpec) {
+	seen := map[string]bool{}
+	for i, spec := range want {
+		field := fmt.Sprintf("agents[%d]", i)
+		if spec.Name == "" {
+			pl.fe.add(field+".name", "required")
+			continue
+		}
+		if seen[spec.Name] {
+			pl.fe.add(field+".name", "duplicate name %q", spec.Name)
+			continue
+		}
+		seen[spec.Name] = true
+		for k := range spec.Labels {
+			if k == "" {
+				pl.fe.add(field+".labels", "label name required")
+			}
+		}
+		var matches []store.AgentRow
+		for _, a := range pl.agents {
+			if a.Name == spec.Name && !a.RevokedAt.Valid {
+				matches = append(matches, a)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			pl.fe.add(field+".name", "no active agent named %q; register it first", spec.Name)
+			continue
+		case 1:
+		default:
+			pl.fe.add(field+".name", "%d active agents are named %q; rename all but one", len(matches), spec.Name)
+			continue
+		}
+		a := matches[0]
+		if len(a.Labels) == 0 && len(spec.Labels) == 0 || reflect.DeepEqual(a.Labels, spec.Labels) {
+			continue
+		}
+		labels := spec.Labels
+		pl.upsert(change{Change: client.Change{Kind: "agent", Key: spec.Name, Action: "update", Fields: []string{"labels"}},
+			run: func(ctx context.Context, st store.Storage) error {
+				return st.UpdateAgent(ctx, pl.pid, a.ID, a.Name, labels)
+			}})
+	}
+}
+
+func (pl *planner) diffRoutes(want []store.AlertRouteRow) {
+	cur := map[string]store.AlertRouteRow{}
+	for _, r := range pl.routes {
+		cur[r.Key] = r
+	}
+	seen := map[string]bool{}
+	for i, r := range want {
+		field := fmt.Sprintf("alert_routes[%d]", i)
+		pl.checkKey(field+".key", r.Key, seen)
+		r.Name = strings.TrimSpace(r.Name)
+		if r.Name == "" {
+			pl.fe.add(field+".name", "required")
+		}
+		if msg := checkHTTPURL(r.WebhookURL); msg != "" {
+			pl.fe.add(field+".webhook_url", "%s", msg)
+		}
+		pl.checkSelector(field+".match", r.Match)
+		r.ProjectID = pl.pid
+		old, exists := cur[r.Key]
+		if !exists {
+			pl.upsert(change{Change: client.Change{Kind: "alert_route", Key: r.Key, Action: "create"},
+				run: func(ctx context.Context, st store.Storage) error { return st.InsertAlertRoute(ctx, r) }})
+		} else if fields := changedFields(old, r); len(fields) > 0 {
+			pl.upsert(change{Change: client.Change{Kind: "alert_route", Key: r.Key, Action: "update", Fields: fields},
+				run: func(ctx context.Context, st store.Storage) error { return st.UpdateAlertRoute(ctx, r) }})
+		}
+	}
+	for _, r := range pl.routes {
+		if !seen[r.Key] {
+			key := r.Key
+			pl.remove(change{Change: client.Change{Kind: "alert_route", Key: key, Action: "delete"},
+				run: func(ctx context.Context, st store.Storage) error { return st.DeleteAlertRoute(ctx, pl.pid, key) }})
+		}
+	}
+}
+
+func (pl *planner) diffWindows(want []store.MaintenanceWindowRow) {
+	cur := map[string]store.MaintenanceWindowRow{}
+	for _, w := range pl.windows {
+		cur[w.Key] = w
+	}
+	seen := map[string]bool{}
+	for i, w := range want {
+		field := fmt.Sprintf("maintenance_windows[%d]", i)
+		pl.checkKey(field+".key", w.Key, seen)
+		w.Name = strings.TrimSpace(w.Name)
+		if w.Name == "" {
+			pl.fe.add(field+".name", "required")
+		}
+		if w.StartsAt.IsZero() {
+			pl.fe.add(field+".starts_at", "required")
+		}
+		if !w.EndsAt.After(w.StartsAt) {
+			pl.fe.add(field+".ends_at", "must be after starts_at")
+		}
+		pl.checkSelector(field+".match", w.Match)
+		w.ProjectID = pl.pid
+		w.StartsAt, w.EndsAt = w.StartsAt.UTC(), w.EndsAt.UTC()
+		old, exists := cur[w.Key]
+		if !exists {
+			pl.upsert(change{Change: client.Change{Kind: "maintenance_window", Key: w.Key, Action: "create"},
+				run: func(ctx context.Context, st store.Storage) error { return st.InsertMaintenanceWindow(ctx, w) }})
+		} else if fields := changedFields(old, w); len(fields) > 0 {
+			pl.upsert(change{Change: client.Change{Kind: "maintenance_window", Key: w.Key, Action: "update", Fields: fields},
+				run: func(ctx context.Context, st store.Storage) error { return st.UpdateMaintenanceWindow(ctx, w) }})
+		}
+	}
+	for _, w := range pl.windows {
+		if !seen[w.Key] {
+			key := w.Key
+			pl.remove(change{Change: client.Change{Kind: "maintenance_window", Key: key, Action: "delete"},
+				run: func(ctx context.Context, st store.Storage) error { return st.DeleteMaintenanceWindow(ctx, pl.pid, key) }})
+		}
+	}
+}
+
+// changedFields compares the JSON form of two objects and returns the
+// top-level fields that differ, sorted, skipping ignore.
+func changedFields(old, next any, ignore ...string) []string {
+	a, b := jsonFields(old), jsonFields(next)
+	skip := map[string]bool{}
+	for _, k := range ignore {
+		skip[k] = true
+	}
+	var out []string
+	for k := range a {
+		if !skip[k] && !bytes.Equal(a[k], b[k]) {
+			out = append(out, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok && !skip[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func jsonFields(v any) map[string]json.RawMessage {
+	var m map[string]json.RawMessage
+	b, _ := json.Marshal(v)
+	_ = json.Unmarshal(b, &m)
+	return m
+}
+
+// -------- Export --------
+
+// exportTarget is a target as written to a manifest: without the id,
+// project and creation time the server assigns.
+func exportTarget(t store.TargetRow) map[string]json.RawMessage {
+	m := jsonFields(t)
+	delete(m, "id")
+	delete(m, "project_id")
+	delete(m, "created_at")
+	return m
+}
+
+var slugRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// current describes the project as a manifest.
+func (h *ConfigHandler) current(ctx context.Context, pid int64) (any, error) {
+	pl := &planner{st: h.Store, pid: pid}
+	if err := pl.load(ctx); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, t := range pl.targets {
+		used[t.Key] = true
+	}
+	targets := make([]map[string]json.RawMessage, 0, len(pl.targets))
+	for _, t := range pl.targets {
+		if t.Key == "" {
+			t.Key = strings.Trim(slugRe.ReplaceAllString(strings.ToLower(t.Name), "-"), "-")
+			if t.Key == "" || used[t.Key] || !keyRe.MatchString(t.Key) {
+				t.Key = fmt.Sprintf("target-%d", t.ID)
+			}
+			used[t.Key] = true
+		}
+		targets = append(targets, exportTarget(t))
+	}
+	agents := []agentSpec{}
+	for _, a := range pl.agents {
+		if !a.RevokedAt.Valid {
+			agents = append(agents, agentSpec{Name: a.Name, Labels: a.Labels})
+		}
+	}
+	orEmpty := func(v any) any {
+		if reflect.ValueOf(v).IsNil() {
+			return []struct{}{}
+		}
+		return v
+	}
+	// field order matches manifest
+	return struct {
+		Version            int                          `json:"version"`
+		Groups             any                          `json:"groups"`
+		Targets            []map[string]json.RawMessage `json:"targets"`
+		Agents             []agentSpec                  `json:"agents"`
+		AlertRoutes        any                          `json:"alert_routes"`
+		MaintenanceWindows any                          `json:"maintenance_windows"`
+	}{manifestVersion, orEmpty(pl.groups), targets, agents, orEmpty(pl.routes), orEmpty(pl.windows)}, nil
+}
@@ -1,119 +1,186 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
 )
 
 type IngestHandler struct {
-	Store *store.Store
-	Logs  *LogsHandler
+	Store  store.Storage
+	Logs   *LogsHandler
+	Alerts *Alerter
 }
 
-func NewIngestHandler(st *store.Store, logs *LogsHandler) *IngestHandler {
-	return &IngestHandler{Store: st, Logs: logs}
+func NewIngestHandler(st store.Storage, logs *LogsHandler, alerts *Alerter) *IngestHandler {
+	return &IngestHandler{Store: st, Logs: logs, Alerts: alerts}
 }
 
 func (h *IngestHandler) Register(r *gin.Engine) {
-	g := r.Group("/api/ingest", RequireAgentKey(h.Store))
+	g := r.Group("/api/ingest", RequireAgentKey(h.Store), checkBody)
 	g.POST("/checks", h.checks)
 }
 
-// ----- DTOs from agent -----
-
-type checkLogDTO struct {
-	TS    string `json:"ts"`    // RFC3339
-	Level string `json:"level"` // trace|info|warn|error
-	Line  string `json:"line"`
-}
-
-type checkDTO struct {
-	TargetID   int64         `json:"target_id"`
-	TS         string        `json:"ts"` // RFC3339
-	StatusCode int           `json:"status_code"`
-	OK         bool          `json:"ok"`
-	LatencyMs  int           `json:"latency_ms"`
-	Error      string        `json:"error"`
-	Logs       []checkLogDTO `json:"logs,omitempty"` // NEW
-}
-
-type checksReq struct {
-	Checks []checkDTO `json:"checks"`
-}
-
 func (h *IngestHandler) checks(c *gin.Context) {
-	var req checksReq
+	var req client.IngestRequest
 	if err := c.ShouldBindJSON(&req); err != nil || len(req.Checks) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
 
+	ctx := c.Request.Context()
+	agentID := c.GetInt64("agent_id")
+	pid := projectID(c)
+	markAgentSeen(ctx, h.Store, h.Alerts, pid, agentID, time.Now().UTC())
+
+	// agents may only report on active targets in their own project;
+	// checks that were in flight when a target was paused are dropped.
+	// Targets are read before the transaction, which on SQLite holds the
+	// write lock from its start.
+	targets := map[int64]*store.TargetRow{}
 	for _, x := range req.Checks {
-		ts, err := time.Parse(time.RFC3339, x.TS)
-		if err != nil {
+		if _, seen := targets[x.TargetID]; seen {
 			continue
 		}
+		t, err := h.Store.GetTarget(ctx, pid, x.TargetID)
+		if err != nil {
+			// the agent keeps the batch and retries, rather than have its
+			// checks dropped as if the target were gone
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ingest failed"})
+			return
+		}
+		if t != nil && t.Paused {
+			t = nil
+		}
+		targets[x.TargetID] = t
+	}
 
-		_ = h.Store.InsertCheck(c.Request.Context(), x.TargetID, ts, x.StatusCode, x.OK, x.LatencyMs, x.Error)
+	// the whole batch is one transaction; log lines and alerts go out only
+	// once it has committed
+	var (
+		ingested int
+		lines    []publishedLine
+		events   []outageEvent
+	)
+	err := h.Store.WithBatch(ctx, func(b store.Batch) error {
+		for _, x := range req.Checks {
+			t := targets[x.TargetID]
+			ts, err := time.Parse(time.RFC3339, x.TS)
+			if t == nil || err != nil {
+				continue
+			}
 
-		for _, lg := range x.Logs {
-			lts, err := time.Parse(time.RFC3339, lg.TS)
+			// a check that can't be stored is skipped with its logs and
+			// outage change, rather than failing the whole batch
+			var (
+				checkLines []publishedLine
+				ev         *outageEvent
+			)
+			err = b.Savepoint(ctx, func(b store.Batch) error {
+				if err := b.InsertCheck(ctx, pid, x.TargetID, agentID, ts, x.StatusCode, x.OK, x.LatencyMs, x.Error, x.Timings); err != nil {
+					return err
+				}
+
+				for _, lg := range x.Logs {
+					lts, err := time.Parse(time.RFC3339, lg.TS)
+					if err != nil {
+						continue
+					}
+					level := normLevel(lg.Level)
+					line := truncate(lg.Line, 2000)
+
+					if err := b.InsertCheckLog(ctx, pid, x.TargetID, nil, lts, level, line); err != nil {
+						return err
+					}
+					checkLines = append(checkLines, publishedLine{x.TargetID, client.CheckLog{
+						TS:    lts.UTC().Format(time.RFC3339),
+						Level: level,
+						Line:  line,
+					}})
+				}
+
+				// Outage stabilization
+				var err error
+				ev, err = handleOutage(ctx, b, *t, ts, x.OK, x.Error)
+				return err
+			})
 			if err != nil {
+				fmt.Printf("[ingest] target #%d: check at %s skipped: %v\n", x.TargetID, x.TS, err)
 				continue
 			}
-			level := normLevel(lg.Level)
-			line := truncate(lg.Line, 2000)
-
-			_ = h.Store.InsertCheckLog(c.Request.Context(), x.TargetID, nil, lts, level, line)
-
-			// Live tail via SSE
-			if h.Logs != nil {
-				b, _ := json.Marshal(struct {
-					TS    string `json:"ts"`
-					Level string `json:"level"`
-					Line  string `json:"line"`
-				}{
-					TS:    lts.UTC().Format(time.RFC3339),
-					Level: level,
-					Line:  line,
-				})
-				h.Logs.Publish(x.TargetID, string(b))
+			ingested++
+			lines = append(lines, checkLines...)
+			if ev != nil {
+				events = append(events, *ev)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		// the transaction didn't begin or commit, so nothing was stored;
+		// the agent keeps the batch and retries
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ingest failed"})
+		return
+	}
 
-		// Outage stabilization
-		h.handleOutage(c, x.TargetID, ts, x.OK, x.Error)
+	// Live tail via SSE
+	if h.Logs != nil {
+		for _, l := range lines {
+			b, _ := json.Marshal(l.log)
+			h.Logs.Publish(l.targetID, string(b))
+		}
 	}
+	for _, ev := range events {
+		h.Alerts.Notify(ev.target, ev.event, ev.reason, ev.at)
+	}
+
+	c.JSON(http.StatusOK, client.IngestResponse{Ingested: ingested})
+}
 
-	c.JSON(http.StatusOK, gin.H{"ingested": len(req.Checks)})
+type publishedLine struct {
+	targetID int64
+	log      client.CheckLog
+}
+
+// outageEvent is an outage opened or resolved by a batch, alerted once the
+// batch has committed.
+type outageEvent struct {
+	target        store.TargetRow
+	event, reason string
+	at            time.Time
 }
 
 // open after 2 consecutive fails, close after 2 consecutive ok
-func (h *IngestHandler) handleOutage(c *gin.Context, targetID int64, ts time.Time, ok bool, reason string) {
-	open, err := h.Store.GetOpenOutage(c.Request.Context(), targetID)
+func handleOutage(ctx context.Context, b store.Batch, t store.TargetRow, ts time.Time, ok bool, reason string) (*outageEvent, error) {
+	projectID, targetID := t.ProjectID, t.ID
+	open, err := b.GetOpenOutage(ctx, projectID, targetID)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if ok == (open == nil) {
+		return nil, nil
+	}
+	recent, err := b.GetRecentChecks(ctx, projectID, targetID, 2)
+	if err != nil || len(recent) < 2 || recent[0].OK != ok || recent[1].OK != ok {
+		return nil, err
 	}
 	if ok {
-		if open != nil {
-			recent, err := h.Store.GetRecentChecks(c.Request.Context(), targetID, 2)
-			if err == nil && len(recent) >= 2 && recent[0].OK && recent[1].OK {
-				_ = h.Store.CloseOutage(c.Request.Context(), open.ID, ts)
-			}
+		if err := b.CloseOutage(ctx, open.ID, ts); err != nil {
+			return nil, err
 		}
-		return
+		return &outageEvent{t, eventOutageResolved, open.Reason, ts}, nil
 	}
-	if open == nil {
-		recent, err := h.Store.GetRecentChecks(c.Request.Context(), targetID, 2)
-		if err == nil && len(recent) >= 2 && !recent[0].OK && !recent[1].OK {
-			_ = h.Store.OpenOutage(c.Request.Context(), targetID, ts, reason)
-		}
+	if err := b.OpenOutage(ctx, projectID, targetID, ts, reason); err != nil {
+		return nil, err
 	}
+	return &outageEvent{t, eventOutageOpened, reason, ts}, nil
 }
 
 // ----- helpers -----
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+// unbatched runs every statement of an ingest batch on its own, the way
+// ingest worked before batches became transactions.
+type unbatched struct{ *store.Store }
+
+func (u unbatched) WithBatch(_ context.Context, fn func(store.Batch) error) error {
+	return fn(u.Store)
+}
+
+// failingBatch fails every log line insert for one target, after its
+// check was inserted.
+type failingBatch struct {
+	store.Batch
+	target int64
+}
+
+func (f failingBatch) Savepoint(ctx context.Context, fn func(store.Batch) error) error {
+	return f.Batch.Savepoint(ctx, func(b store.Batch) error { return fn(failingBatch{b, f.target}) })
+}
+
+func (f failingBatch) InsertCheckLog(ctx context.Context, projectID, targetID int64, checkID *int64, ts time.Time, level, line string) error {
+	if targetID == f.target {
+		return errors.New("insert failed")
+	}
+	return f.Batch.InsertCheckLog(ctx, projectID, targetID, checkID, ts, level, line)
+}
+
+type failingStore struct {
+	*store.Store
+	target int64
+}
+
+func (f failingStore) WithBatch(ctx context.Context, fn func(store.Batch) error) error {
+	return f.Store.WithBatch(ctx, func(b store.Batch) error { return fn(failingBatch{b, f.target}) })
+}
+
+func TestIngestSkipsFailedRows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	st, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	var ids []int64
+	for _, name := range []string{"good", "bad"} {
+		id, err := st.InsertTarget(ctx, store.TargetRow{ProjectID: store.DefaultProjectID, Name: name, URL: "https://example.com", TimeoutMs: 4000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	const key = "testkey00-0123456789abcdef"
+	if _, err := st.CreateAgent(ctx, store.DefaultProjectID, "edge", key); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	NewIngestHandler(failingStore{st, ids[1]}, nil, nil).Register(r)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var req client.IngestRequest
+	for _, id := range ids {
+		req.Checks = append(req.Checks, client.Check{TargetID: id, TS: now, StatusCode: 200, OK: true, LatencyMs: 10,
+			Logs: []client.CheckLog{{TS: now, Level: "info", Line: "checked"}}})
+	}
+	body, _ := json.Marshal(req)
+	hr := httptest.NewRequest(http.MethodPost, "/api/ingest/checks", bytes.NewReader(body))
+	hr.Header.Set("X-Api-Key", key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hr)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ingested":1`) {
+		t.Fatalf("got %d %s, want 200 with 1 ingested", w.Code, w.Body)
+	}
+	// the bad target's check is rolled back with its log line
+	for i, want := range []int{1, 0} {
+		checks, err := st.GetRecentChecks(ctx, store.DefaultProjectID, ids[i], 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs, err := st.ListLogs(ctx, store.DefaultProjectID, ids[i], 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(checks) != want || len(logs) != want {
+			t.Errorf("target %d: %d checks and %d log lines stored, want %d of each", ids[i], len(checks), len(logs), want)
+		}
+	}
+}
+
+// unreadableStore fails every target read.
+type unreadableStore struct{ *store.Store }
+
+func (unreadableStore) GetTarget(context.Context, int64, int64) (*store.TargetRow, error) {
+	return nil, errors.New("read failed")
+}
+
+func TestIngestFailsWhenTargetsCantBeRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	st, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	id, err := st.InsertTarget(ctx, store.TargetRow{ProjectID: store.DefaultProjectID, Name: "web", URL: "https://example.com", TimeoutMs: 4000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const key = "testkey00-0123456789abcdef"
+	if _, err := st.CreateAgent(ctx, store.DefaultProjectID, "edge", key); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	NewIngestHandler(unreadableStore{st}, nil, nil).Register(r)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	body, _ := json.Marshal(client.IngestRequest{Checks: []client.Check{{TargetID: id, TS: now, StatusCode: 200, OK: true, LatencyMs: 10}}})
+	hr := httptest.NewRequest(http.MethodPost, "/api/ingest/checks", bytes.NewReader(body))
+	hr.Header.Set("X-Api-Key", key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hr)
+	// a 500 makes the agent keep the batch for a retry
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d %s, want 500", w.Code, w.Body)
+	}
+}
+
+func BenchmarkIngestUnbatched(b *testing.B) { benchmarkIngest(b, false) }
+
+func BenchmarkIngestBatched(b *testing.B) { benchmarkIngest(b, true) }
+
+const (
+	benchTargets = 10
+	benchChecks  = 20 // per batch
+)
+
+// benchmarkIngest pushes batches through the ingest handler from parallel
+// agents, one agent per goroutine, and reports checks stored per second
+// and batches that failed.
+func benchmarkIngest(b *testing.B, batch bool) {
+	gin.SetMode(gin.ReleaseMode)
+	ctx := context.Background()
+	st, err := store.Open(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer st.Close()
+	for i := 0; i < benchTargets; i++ {
+		if _, err := st.InsertTarget(ctx, store.TargetRow{ProjectID: store.DefaultProjectID,
+			Name: fmt.Sprintf("bench-%d", i), URL: "https://example.com", TimeoutMs: 4000}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var storage store.Storage = st
+	if !batch {
+		storage = unbatched{st}
+	}
+	r := gin.New()
+	NewIngestHandler(storage, nil, nil).Register(r)
+
+	var agents, failed atomic.Int64
+	base := time.Now().UTC().Add(-time.Hour)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		agent := int(agents.Add(1))
+		key := fmt.Sprintf("benchkey%02d-0123456789abcdef", agent)
+		if _, err := st.CreateAgent(ctx, store.DefaultProjectID, fmt.Sprintf("bench-%d", agent), key); err != nil {
+			b.Error(err)
+			return
+		}
+		for n := 0; pb.Next(); n++ {
+			req := httptest.NewRequest(http.MethodPost, "/api/ingest/checks", bytes.NewReader(ingestBody(base, agent, n)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Api-Key", key)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				failed.Add(1)
+			}
+		}
+	})
+	b.StopTimer()
+	ok := int64(b.N) - failed.Load()
+	b.ReportMetric(float64(ok*benchChecks)/b.Elapsed().Seconds(), "checks/s")
+	b.ReportMetric(float64(failed.Load()), "failed-batches")
+}
+
+// ingestBody is the n-th batch of an agent. Targets fail in runs of three,
+// so outages open and close as they would in production.
+func ingestBody(base time.Time, agent, n int) []byte {
+	req := client.IngestRequest{Checks: make([]client.Check, benchChecks)}
+	for i := range req.Checks {
+		seq := (n*benchChecks+i)/benchTargets + agent
+		ok := seq/3%2 == 0
+		c := client.Check{
+			TargetID:   int64((n*benchChecks+i)%benchTargets + 1),
+			TS:         base.Add(time.Duration(seq) * time.Second).Format(time.RFC3339),
+			StatusCode: 200,
+			OK:         ok,
+			LatencyMs:  40,
+			Logs:       []client.CheckLog{{TS: base.Format(time.RFC3339), Level: "info", Line: "GET https://example.com"}},
+		}
+		if !ok {
+			c.StatusCode, c.Error = 503, "non_2xx"
+		}
+		req.Checks[i] = c
+	}
+	b, _ := json.Marshal(req)
+	return b
+}
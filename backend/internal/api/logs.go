@@ -3,25 +3,27 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
 )
 
 type LogsHandler struct {
-	Store *store.Store
+	Store store.Storage
 	Hub   *LogHub
 }
 
-func NewLogsHandler(st *store.Store) *LogsHandler {
+func NewLogsHandler(st store.Storage) *LogsHandler {
 	return &LogsHandler{Store: st, Hub: NewLogHub()}
 }
 
 func (h *LogsHandler) Register(r *gin.Engine) {
 	g := r.Group("/api")
-	g.GET("/logs", h.listLogs)          // history:  GET /api/logs?target_id=1&limit=200&before=RFC3339
-	g.GET("/logs/stream", h.streamLogs) // SSE:      GET /api/logs/stream?target_id=1
+	g.GET("/logs", RequireRole(h.Store, store.RoleViewer), h.listLogs)          // history:  GET /api/logs?target_id=1&limit=200&before=RFC3339
+	g.GET("/logs/stream", RequireRole(h.Store, store.RoleViewer), h.streamLogs) // SSE:      GET /api/logs/stream?target_id=1
 }
 
 // ----- history -----
@@ -36,6 +38,15 @@ func (h *LogsHandler) listLogs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
 		return
 	}
+	t, err := h.Store.GetTarget(c.Request.Context(), projectID(c), tid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "target lookup failed"})
+		return
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "target not found"})
+		return
+	}
 	limit := 200
 	if s := c.Query("limit"); s != "" {
 		if n, err := strconv.Atoi(s); err == nil && n > 0 && n <= 1000 {
@@ -49,19 +60,14 @@ func (h *LogsHandler) listLogs(c *gin.Context) {
 		}
 	}
 
-	rows, err := h.Store.ListLogs(c.Request.Context(), tid, limit, before)
+	rows, err := h.Store.ListLogs(c.Request.Context(), projectID(c), tid, limit, before)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "list logs failed"})
 		return
 	}
-	type item struct {
-		TS    string `json:"ts"`
-		Level string `json:"level"`
-		Line  string `json:"line"`
-	}
-	out := make([]item, 0, len(rows))
+	out := make([]client.CheckLog, 0, len(rows))
 	for i := range rows {
-		out = append(out, item{
+		out = append(out, client.CheckLog{
 			TS:    rows[i].TS.UTC().Format(time.RFC3339),
 			Level: rows[i].Level,
 			Line:  rows[i].Line,
@@ -80,6 +86,8 @@ type LogHub struct {
 	add  chan subReq
 	del  chan subReq
 	pub  chan LogEvent
+	done chan struct{} // closed by Close; ends the hub and every stream
+	once sync.Once
 }
 type subReq struct {
 	TargetID int64
@@ -92,10 +100,13 @@ func NewLogHub() *LogHub {
 		add:  make(chan subReq),
 		del:  make(chan subReq),
 		pub:  make(chan LogEvent, 1024),
+		done: make(chan struct{}),
 	}
 	go func() {
 		for {
 			select {
+			case <-h.done:
+				return
 			case r := <-h.add:
 				if h.subs[r.TargetID] == nil {
 					h.subs[r.TargetID] = map[chan string]struct{}{}
@@ -118,6 +129,9 @@ func NewLogHub() *LogHub {
 	return h
 }
 
+// Close ends all SSE streams so the HTTP server can drain on shutdown.
+func (h *LogHub) Close() { h.once.Do(func() { close(h.done) }) }
+
 func (h *LogsHandler) streamLogs(c *gin.Context) {
 	tidStr := c.Query("target_id")
 	if tidStr == "" {
@@ -129,6 +143,15 @@ func (h *LogsHandler) streamLogs(c *gin.Context) {
 		c.String(http.StatusBadRequest, "invalid target_id")
 		return
 	}
+	t, err := h.Store.GetTarget(c.Request.Context(), projectID(c), tid)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "target lookup failed")
+		return
+	}
+	if t == nil {
+		c.String(http.StatusNotFound, "target not found")
+		return
+	}
 
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -137,8 +160,17 @@ func (h *LogsHandler) streamLogs(c *gin.Context) {
 	flusher, _ := c.Writer.(http.Flusher)
 
 	ch := make(chan string, 64)
-	h.Hub.add <- subReq{TargetID: tid, Ch: ch}
-	defer func() { h.Hub.del <- subReq{TargetID: tid, Ch: ch} }()
+	select {
+	case h.Hub.add <- subReq{TargetID: tid, Ch: ch}:
+	case <-h.Hub.done:
+		return
+	}
+	defer func() {
+		select {
+		case h.Hub.del <- subReq{TargetID: tid, Ch: ch}:
+		case <-h.Hub.done:
+		}
+	}()
 
 	// initial comment to establish stream
 	_, _ = c.Writer.Write([]byte(": ok\n\n"))
@@ -152,6 +184,8 @@ func (h *LogsHandler) streamLogs(c *gin.Context) {
 		select {
 		case <-notify:
 			return
+		case <-h.Hub.done:
+			return
 		case <-tick.C:
 			_, _ = c.Writer.Write([]byte("event: ping\ndata: {}\n\n"))
 			flusher.Flush()
@@ -164,5 +198,11 @@ func (h *LogsHandler) streamLogs(c *gin.Context) {
 
 // Publish is called by ingest after inserting a log line.
 func (h *LogsHandler) Publish(targetID int64, jsonLine string) {
-	h.Hub.pub <- LogEvent{TargetID: targetID, Data: jsonLine}
+	select {
+	case h.Hub.pub <- LogEvent{TargetID: targetID, Data: jsonLine}:
+	case <-h.Hub.done:
+	}
 }
+
+// Close ends open log streams; see LogHub.Close.
+func (h *LogsHandler) Close() { h.Hub.Close() }
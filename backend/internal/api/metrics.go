@@ -1,21 +1,23 @@
 package api
 
 import (
+	"database/sql"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
 )
 
-type MetricsHandler struct{ Store *store.Store }
+type MetricsHandler struct{ Store store.Storage }
 
-func NewMetricsHandler(st *store.Store) *MetricsHandler { return &MetricsHandler{Store: st} }
+func NewMetricsHandler(st store.Storage) *MetricsHandler { return &MetricsHandler{Store: st} }
 
 func (h *MetricsHandler) Register(r *gin.Engine) {
 	g := r.Group("/api")
-	g.GET("/metrics", h.get)
+	g.GET("/metrics", RequireRole(h.Store, store.RoleViewer), h.get)
 }
 
 func (h *MetricsHandler) get(c *gin.Context) {
@@ -29,6 +31,16 @@ func (h *MetricsHandler) get(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
 		return
 	}
+	pid := projectID(c)
+	target, err := h.Store.GetTarget(c.Request.Context(), pid, tid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "target lookup failed"})
+		return
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "target not found"})
+		return
+	}
 
 	var from, to time.Time
 	if c.Query("from") == "" || c.Query("to") == "" {
@@ -51,59 +63,94 @@ func (h *MetricsHandler) get(c *gin.Context) {
 		}
 	}
 
-	total, success, err := h.Store.CountChecksAgg(c.Request.Context(), tid, from, to)
+	total, success, err := h.Store.CountChecksAgg(c.Request.Context(), pid, tid, from, to)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "count failed"})
 		return
 	}
-	avg, err := h.Store.AvgLatencyOK(c.Request.Context(), tid, from, to)
+	avg, err := h.Store.AvgLatencyOK(c.Request.Context(), pid, tid, from, to)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "avg failed"})
 		return
 	}
-	reasons, err := h.Store.FailuresByReason(c.Request.Context(), tid, from, to)
+	phases, err := h.Store.AvgPhasesOK(c.Request.Context(), pid, tid, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "phases failed"})
+		return
+	}
+	reasons, err := h.Store.FailuresByReason(c.Request.Context(), pid, tid, from, to)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "reasons failed"})
 		return
 	}
-	outs, err := h.Store.ListOutagesOverlapping(c.Request.Context(), tid, from, to)
+	outs, err := h.Store.ListOutagesOverlapping(c.Request.Context(), pid, tid, from, to)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "outages failed"})
 		return
 	}
+	pauses, err := h.Store.ListPausesOverlapping(c.Request.Context(), pid, tid, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "pauses failed"})
+		return
+	}
 
-	// clamp outages to window + compute downtime
-	type Out struct {
-		StartedAt  string  `json:"started_at"`
-		EndedAt    *string `json:"ended_at,omitempty"`
-		DurationMs int64   `json:"duration_ms"`
-		Reason     string  `json:"reason"`
+	// a quiet target whose agent is offline is unknown, not healthy
+	var lastCheckAt *string
+	agentOffline := false
+	var agentOuts []store.AgentOutageRow
+	lastTS, lastAgent, seen, err := h.Store.LastCheck(c.Request.Context(), pid, tid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "last check failed"})
+		return
 	}
-	var outArr []Out
-	var downtimeMs int64
+	if seen {
+		s := lastTS.UTC().Format(time.RFC3339)
+		lastCheckAt = &s
+		if lastAgent.Valid {
+			open, err := h.Store.GetOpenAgentOutage(c.Request.Context(), lastAgent.Int64)
+			agentOffline = err == nil && open != nil
+			agentOuts, err = h.Store.ListAgentOutagesOverlapping(c.Request.Context(), lastAgent.Int64, from, to)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "agent outages failed"})
+				return
+			}
+		}
+	}
+
 	now := time.Now().UTC()
+	var pauseSpans, offlineSpans []span
+	for _, p := range pauses {
+		pauseSpans = append(pauseSpans, clampSpan(p.StartedAt, p.EndedAt, from, to, now))
+	}
+	for _, o := range agentOuts {
+		offlineSpans = append(offlineSpans, clampSpan(o.StartedAt, o.EndedAt, from, to, now))
+	}
+
+	// clamp outages to window + compute downtime; while the agent was
+	// offline nothing was measured, so that part isn't downtime
+	var outArr []client.MetricsOutage
+	var downtimeMs int64
 	for _, o := range outs {
-		start := o.StartedAt
-		if start.Before(from) {
-			start = from
-		}
-		realEnd := to
-		if o.EndedAt.Valid && o.EndedAt.Time.Before(to) {
-			realEnd = o.EndedAt.Time
-		} else if !o.EndedAt.Valid && now.Before(to) {
-			realEnd = now
-		}
-		dur := realEnd.Sub(start)
-		if dur < 0 {
-			dur = 0
-		}
-		downtimeMs += dur.Milliseconds()
+		sp := clampSpan(o.StartedAt, o.EndedAt, from, to, now)
+		dur := sp.dur()
+		downtimeMs += (dur - sp.overlap(offlineSpans)).Milliseconds()
 		var endStr *string
 		if o.EndedAt.Valid {
 			s := o.EndedAt.Time.UTC().Format(time.RFC3339)
 			endStr = &s
 		}
-		outArr = append(outArr, Out{StartedAt: o.StartedAt.UTC().Format(time.RFC3339), EndedAt: endStr, DurationMs: dur.Milliseconds(), Reason: o.Reason})
+		outArr = append(outArr, client.MetricsOutage{StartedAt: o.StartedAt.UTC().Format(time.RFC3339), EndedAt: endStr, DurationMs: dur.Milliseconds(), Reason: o.Reason})
+	}
+
+	// paused and agent-offline time count as neither up nor down; pausing
+	// closes any open outage, so pauses and outages never overlap
+	var pausedMs, offlineMs, offlineUnpausedMs int64
+	for _, sp := range pauseSpans {
+		pausedMs += sp.dur().Milliseconds()
+	}
+	for _, sp := range offlineSpans {
+		offlineMs += sp.dur().Milliseconds()
+		offlineUnpausedMs += (sp.dur() - sp.overlap(pauseSpans)).Milliseconds()
 	}
 
 	var availPtr *float64
@@ -111,7 +158,7 @@ func (h *MetricsHandler) get(c *gin.Context) {
 		v := float64(success) / float64(total) * 100
 		availPtr = &v
 	}
-	windowMs := to.Sub(from).Milliseconds()
+	windowMs := to.Sub(from).Milliseconds() - pausedMs - offlineUnpausedMs
 	var availTimePtr *float64
 	if windowMs > 0 {
 		v := float64(windowMs-downtimeMs) / float64(windowMs) * 100
@@ -119,26 +166,90 @@ func (h *MetricsHandler) get(c *gin.Context) {
 	}
 
 	failMap := map[string]int64{}
+	catMap := map[string]int64{}
 	for _, r := range reasons {
 		failMap[r.Reason] = r.Count
+		catMap[r.Category] += r.Count
 	}
 	avgLatency := 0
 	if avg.Valid {
 		avgLatency = int(avg.Float64)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"target_id":                   tid,
-		"from":                        from.UTC().Format(time.RFC3339),
-		"to":                          to.UTC().Format(time.RFC3339),
-		"availability_percent_checks": availPtr,
-		"availability_percent_time":   availTimePtr,
-		"total_checks":                total,
-		"successful_checks":           success,
-		"failed_checks":               total - success,
-		"average_latency_ms":          avgLatency,
-		"failures_by_reason":          failMap,
-		"outages":                     outArr,
-		"downtime_ms":                 downtimeMs,
+	// nil when no successful check in the window reported that phase
+	phaseMs := func(v sql.NullFloat64) *int {
+		if !v.Valid {
+			return nil
+		}
+		n := int(v.Float64)
+		return &n
+	}
+	c.JSON(http.StatusOK, client.Metrics{
+		TargetID:                  tid,
+		From:                      from.UTC().Format(time.RFC3339),
+		To:                        to.UTC().Format(time.RFC3339),
+		AvailabilityPercentChecks: availPtr,
+		AvailabilityPercentTime:   availTimePtr,
+		TotalChecks:               total,
+		SuccessfulChecks:          success,
+		FailedChecks:              total - success,
+		AverageLatencyMs:          avgLatency,
+		AveragePhasesMs: client.PhaseAverages{
+			DNS:      phaseMs(phases.DNS),
+			Connect:  phaseMs(phases.Connect),
+			TLS:      phaseMs(phases.TLS),
+			TTFB:     phaseMs(phases.TTFB),
+			Transfer: phaseMs(phases.Transfer),
+		},
+		FailuresByReason:   failMap,
+		FailuresByCategory: catMap,
+		Outages:            outArr,
+		DowntimeMs:         downtimeMs,
+		Paused:             target.Paused,
+		PausedMs:           pausedMs,
+		LastCheckAt:        lastCheckAt,
+		AgentOffline:       agentOffline,
+		AgentOfflineMs:     offlineMs,
 	})
 }
+
+// span is a period clamped to a metrics window. Periods still open run to
+// now, or to the end of the window if that comes first.
+type span struct{ start, end time.Time }
+
+func clampSpan(start time.Time, ended sql.NullTime, from, to, now time.Time) span {
+	if start.Before(from) {
+		start = from
+	}
+	end := to
+	if ended.Valid && ended.Time.Before(to) {
+		end = ended.Time
+	} else if !ended.Valid && now.Before(to) {
+		end = now
+	}
+	return span{start, end}
+}
+
+func (s span) dur() time.Duration {
+	if d := s.end.Sub(s.start); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// overlap is how much of s the given spans cover; they must not overlap
+// each other.
+func (s span) overlap(others []span) time.Duration {
+	var d time.Duration
+	for _, o := range others {
+		start, end := s.start, s.end
+		if o.start.After(start) {
+			start = o.start
+		}
+		if o.end.Before(end) {
+			end = o.end
+		}
+		d += span{start, end}.dur()
+	}
+	return d
+}
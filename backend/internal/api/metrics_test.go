@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+// An agent that was offline for part of the window leaves a gap that is
+// neither up nor down, even once it is back.
+func TestMetricsLeaveOutAgentOfflineTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	st, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	pid := int64(store.DefaultProjectID)
+	tid, err := st.InsertTarget(ctx, store.TargetRow{ProjectID: pid, Name: "web", URL: "https://example.com", TimeoutMs: 4000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aid, err := st.CreateAgent(ctx, pid, "edge", "testkey00-0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, err := st.CreateUser(ctx, "viewer", "correct horse", store.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddProjectMember(ctx, pid, uid); err != nil {
+		t.Fatal(err)
+	}
+	const token = "testtoken-0123456789abcdef"
+	if _, err := st.CreateToken(ctx, uid, pid, "test", token, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a 60 minute window: the agent is offline from 10 to 30, and an
+	// outage runs from 20 to 40, so 10 minutes of it were measured
+	from := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Minute)
+	at := func(min int) time.Time { return from.Add(time.Duration(min) * time.Minute) }
+	for _, min := range []int{5, 35, 50} {
+		if err := st.InsertCheck(ctx, pid, tid, aid, at(min), 200, min != 35, 10, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.OpenAgentOutage(ctx, aid, at(10)); err != nil {
+		t.Fatal(err)
+	}
+	ao, err := st.GetOpenAgentOutage(ctx, aid)
+	if err != nil || ao == nil {
+		t.Fatalf("agent outage = %+v, %v", ao, err)
+	}
+	if err := st.CloseAgentOutage(ctx, ao.ID, at(30)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.OpenOutage(ctx, pid, tid, at(20), "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	o, err := st.GetOpenOutage(ctx, pid, tid)
+	if err != nil || o == nil {
+		t.Fatalf("outage = %+v, %v", o, err)
+	}
+	if err := st.CloseOutage(ctx, o.ID, at(40)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	NewMetricsHandler(st).Register(r)
+	req := httptest.NewRequest(http.MethodGet, "/api/metrics?target_id="+strconv.FormatInt(tid, 10)+"&from="+from.Format(time.RFC3339)+"&to="+at(60).Format(time.RFC3339), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var m client.Metrics
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.AgentOffline || m.AgentOfflineMs != (20*time.Minute).Milliseconds() {
+		t.Errorf("agent_offline = %v, agent_offline_ms = %d, want false and 20 minutes", m.AgentOffline, m.AgentOfflineMs)
+	}
+	if m.DowntimeMs != (10 * time.Minute).Milliseconds() {
+		t.Errorf("downtime_ms = %d, want 10 minutes", m.DowntimeMs)
+	}
+	// 10 minutes down out of 40 measured
+	if m.AvailabilityPercentTime == nil || *m.AvailabilityPercentTime != 75 {
+		t.Errorf("availability_percent_time = %v, want 75", m.AvailabilityPercentTime)
+	}
+}
+
+// A failed target read is a server error, not a missing target.
+func TestTargetReadsFailWith500(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	st, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	pid := int64(store.DefaultProjectID)
+	uid, err := st.CreateUser(ctx, "viewer", "correct horse", store.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddProjectMember(ctx, pid, uid); err != nil {
+		t.Fatal(err)
+	}
+	const token = "testtoken-0123456789abcdef"
+	if _, err := st.CreateToken(ctx, uid, pid, "test", token, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	NewMetricsHandler(unreadableStore{st}).Register(r)
+	NewLogsHandler(unreadableStore{st}).Register(r)
+	for _, path := range []string{"/api/metrics?target_id=1", "/api/logs?target_id=1", "/api/logs/stream?target_id=1"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: got %d %s, want 500", path, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,561 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/openapi"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+// access is who may call a route.
+type access int
+
+const (
+	authNone access = iota
+	authViewer
+	authEditor
+	authAdmin
+	authAgent // X-Api-Key
+)
+
+// param is a query or header parameter; typ is a JSON schema type, or
+// "date-time" for RFC3339 strings.
+type param struct {
+	in, name, typ, desc string
+	required            bool
+}
+
+func query(name, typ, desc string) param { return param{in: "query", name: name, typ: typ, desc: desc} }
+
+// route documents one registered route. Bodies are Go values whose types
+// the schemas are generated from; nil means none. Responses are JSON
+// unless ctype says otherwise.
+type route struct {
+	id, method, path string // path as registered with gin
+	tag, summary     string
+	auth             access
+	params           []param
+	body             any
+	bodyYAML         bool // the body may also be YAML
+	bodyNDJSON       bool // the body may also be NDJSON
+	optionalBody     bool
+	status           int
+	resp             any
+	respYAML         bool // ?format=yaml returns YAML
+	ctype            string
+	also             map[int]any // other documented responses besides errors
+}
+
+// routes is every route cmd/server registers. CheckRoutes keeps the two in
+// step; update this table when adding a route.
+var routes = []route{
+	{id: "healthz", method: "GET", path: "/healthz", tag: "meta", summary: "Liveness probe", status: 200, ctype: "text/plain"},
+	{id: "version", method: "GET", path: "/version", tag: "meta", summary: "Server version", status: 200,
+		resp: struct {
+			Version string `json:"version"`
+		}{}},
+	{id: "openapi", method: "GET", path: "/api/openapi.json", tag: "meta", summary: "This document", status: 200, resp: map[string]any{}},
+
+	{id: "login", method: "POST", path: "/api/auth/login", tag: "auth", summary: "Log in and start a session",
+		body: loginRequest{}, status: 200, resp: loginResponse{}},
+	{id: "logout", method: "POST", path: "/api/auth/logout", tag: "auth", summary: "End the current session", auth: authViewer, status: 204},
+	{id: "me", method: "GET", path: "/api/auth/me", tag: "auth", summary: "Current user and projects", auth: authViewer, status: 200, resp: meResponse{}},
+	{id: "switchProject", method: "POST", path: "/api/auth/project", tag: "auth", summary: "Switch the session's project", auth: authViewer,
+		body: projectSwitch{}, status: 200, resp: projectSwitch{}},
+
+	{id: "listUsers", method: "GET", path: "/api/users", tag: "users", summary: "List users", auth: authAdmin, status: 200, resp: []userDTO{}},
+	{id: "createUser", method: "POST", path: "/api/users", tag: "users", summary: "Create a user", auth: authAdmin,
+		body: createUserRequest{}, status: 201, resp: userDTO{}},
+	{id: "updateUser", method: "PATCH", path: "/api/users/:id", tag: "users", summary: "Change a user's role, password or status", auth: authAdmin,
+		body: updateUserRequest{}, status: 200, resp: userDTO{}},
+	{id: "deleteUser", method: "DELETE", path: "/api/users/:id", tag: "users", summary: "Delete a user", auth: authAdmin, status: 204},
+
+	{id: "listTokens", method: "GET", path: "/api/tokens", tag: "tokens", summary: "List your API tokens", auth: authViewer, status: 200, resp: []tokenDTO{}},
+	{id: "createToken", method: "POST", path: "/api/tokens", tag: "tokens", summary: "Create an API token", auth: authViewer,
+		body: createTokenRequest{}, status: 201, resp: newTokenDTO{}},
+	{id: "deleteToken", method: "DELETE", path: "/api/tokens/:id", tag: "tokens", summary: "Revoke an API token", auth: authViewer, status: 204},
+
+	{id: "listProjects", method: "GET", path: "/api/projects", tag: "projects", summary: "List your projects", auth: authViewer, status: 200, resp: []projectDTO{}},
+	{id: "createProject", method: "POST", path: "/api/projects", tag: "projects", summary: "Create a project", auth: authAdmin,
+		body: projectNameRequest{}, status: 201, resp: projectDTO{}},
+	{id: "renameProject", method: "PATCH", path: "/api/projects/:id", tag: "projects", summary: "Rename a project", auth: authAdmin,
+		body: projectNameRequest{}, status: 200, resp: projectDTO{}},
+	{id: "deleteProject", method: "DELETE", path: "/api/projects/:id", tag: "projects", summary: "Delete a project that has no targets or agents", auth: authAdmin,
+		status: 204, also: map[int]any{409: errorDTO{}}},
+	{id: "listMembers", method: "GET", path: "/api/projects/:id/members", tag: "projects", summary: "List project members", auth: authAdmin, status: 200, resp: []userDTO{}},
+	{id: "addMember", method: "PUT", path: "/api/projects/:id/members/:user_id", tag: "projects", summary: "Add a member", auth: authAdmin, status: 204},
+	{id: "removeMember", method: "DELETE", path: "/api/projects/:id/members/:user_id", tag: "projects", summary: "Remove a member", auth: authAdmin, status: 204},
+
+	{id: "exportConfig", method: "GET", path: "/api/config/export", tag: "config", summary: "Export the project as a manifest", auth: authViewer,
+		params: []param{query("format", "string", "json (default) or yaml")}, status: 200, resp: manifest{}, respYAML: true},
+	{id: "planConfig", method: "POST", path: "/api/config/plan", tag: "config", summary: "Show what applying a manifest would change", auth: authEditor,
+		body: manifest{}, bodyYAML: true, status: 200, resp: planResult{}},
+	{id: "applyConfig", method: "POST", path: "/api/config/apply", tag: "config", summary: "Apply a manifest", auth: authAdmin,
+		body: manifest{}, bodyYAML: true, status: 200, resp: planResult{}, also: map[int]any{500: errorDTO{}}},
+
+	{id: "backup", method: "GET", path: "/api/backup", tag: "backup", summary: "Download a snapshot of the SQLite database", auth: authAdmin,
+		status: 200, ctype: "application/octet-stream"},
+	{id: "exportData", method: "GET", path: "/api/export", tag: "backup", summary: "Export targets, agents and their history", auth: authAdmin,
+		params: []param{query("format", "string", "ndjson (default) or json")}, status: 200, ctype: "application/x-ndjson"},
+	{id: "importData", method: "POST", path: "/api/import", tag: "backup", summary: "Import an export into a server without targets or agents", auth: authAdmin,
+		body: []store.ExportRecord{}, bodyNDJSON: true, status: 200, resp: importResponse{}},
+
+	{id: "listTargets", method: "GET", path: "/api/targets", tag: "targets", summary: "List targets", auth: authViewer,
+		params: []param{query("tag", "string", "only targets with this tag")}, status: 200, resp: []store.TargetRow{}},
+	{id: "exportTargets", method: "GET", path: "/api/targets/export", tag: "targets", summary: "Download targets as an agent TARGETS_FILE", auth: authViewer,
+		status: 200, resp: []store.TargetRow{}},
+	{id: "createTarget", method: "POST", path: "/api/targets", tag: "targets", summary: "Create a target", auth: authEditor,
+		body: store.TargetRow{}, status: 201, resp: store.TargetRow{}},
+	{id: "getTarget", method: "GET", path: "/api/targets/:id", tag: "targets", summary: "Get a target", auth: authViewer, status: 200, resp: store.TargetRow{}},
+	{id: "updateTarget", method: "PATCH", path: "/api/targets/:id", tag: "targets", summary: "Change some fields of a target; null clears one", auth: authEditor,
+		body: targetPatch{}, status: 200, resp: store.TargetRow{}},
+	{id: "deleteTarget", method: "DELETE", path: "/api/targets/:id", tag: "targets", summary: "Delete a target with its history", auth: authEditor, status: 204},
+	{id: "pauseTarget", method: "POST", path: "/api/targets/:id/pause", tag: "targets", summary: "Pause checks", auth: authEditor, status: 200, resp: store.TargetRow{}},
+	{id: "resumeTarget", method: "POST", path: "/api/targets/:id/resume", tag: "targets", summary: "Resume checks", auth: authEditor, status: 200, resp: store.TargetRow{}},
+
+	{id: "getMetrics", method: "GET", path: "/api/metrics", tag: "metrics", summary: "Availability, latency and outages of a target", auth: authViewer,
+		params: []param{
+			{in: "query", name: "target_id", typ: "integer", required: true},
+			query("from", "date-time", "defaults to an hour before to"),
+			query("to", "date-time", "defaults to now"),
+		}, status: 200, resp: client.Metrics{}},
+	{id: "listOutages", method: "GET", path: "/api/outages", tag: "metrics", summary: "List outages, newest first", auth: authViewer,
+		params: []param{
+			query("target_id", "integer", ""),
+			query("open", "boolean", "only outages still open"),
+			query("from", "date-time", "outages overlapping from..to"),
+			query("to", "date-time", ""),
+			query("limit", "integer", "default 100, max 1000"),
+		}, status: 200, resp: []client.Outage{}},
+	{id: "listLogs", method: "GET", path: "/api/logs", tag: "logs", summary: "Check logs of a target, newest first", auth: authViewer,
+		params: []param{
+			{in: "query", name: "target_id", typ: "integer", required: true},
+			query("limit", "integer", "default 200"),
+			query("before", "date-time", "page back from here"),
+		}, status: 200, resp: []client.CheckLog{}},
+	{id: "streamLogs", method: "GET", path: "/api/logs/stream", tag: "logs", summary: "Follow check logs as server-sent events", auth: authViewer,
+		params: []param{{in: "query", name: "target_id", typ: "integer", required: true}}, status: 200, ctype: "text/event-stream"},
+
+	{id: "heartbeat", method: "POST", path: "/api/agents/heartbeat", tag: "ingest", summary: "Report the agent as alive", auth: authAgent,
+		body: client.Heartbeat{}, status: 204},
+	{id: "agentTargets", method: "GET", path: "/api/agents/targets", tag: "ingest", summary: "Active targets for the calling agent", auth: authAgent,
+		params: []param{{in: "header", name: "If-None-Match", typ: "string", desc: "ETag of the last list; unchanged is a 304"}},
+		status: 200, resp: []store.TargetRow{}, also: map[int]any{304: nil}},
+	{id: "ingestChecks", method: "POST", path: "/api/ingest/checks", tag: "ingest", summary: "Push a batch of check results", auth: authAgent,
+		body: client.IngestRequest{}, status: 200, resp: client.IngestResponse{}},
+	{id: "registerAgent", method: "POST", path: "/api/agents/register", tag: "agents", summary: "Register an agent and get its key", auth: authAdmin,
+		body: registerAgentRequest{}, status: 200, resp: client.AgentKey{}},
+	{id: "listAgents", method: "GET", path: "/api/agents", tag: "agents", summary: "List agents", auth: authViewer, status: 200, resp: []client.Agent{}},
+	{id: "getAgent", method: "GET", path: "/api/agents/:id", tag: "agents", summary: "Get an agent", auth: authViewer, status: 200, resp: client.Agent{}},
+	{id: "updateAgent", method: "PATCH", path: "/api/agents/:id", tag: "agents", summary: "Rename an agent or set its labels", auth: authAdmin,
+		body: updateAgentRequest{}, status: 200, resp: client.Agent{}},
+	{id: "revokeAgent", method: "DELETE", path: "/api/agents/:id", tag: "agents", summary: "Revoke an agent's keys", auth: authAdmin, status: 204},
+	{id: "rotateAgentKey", method: "POST", path: "/api/agents/:id/rotate-key", tag: "agents", summary: "Issue a new key; the old one works for overlap_sec", auth: authAdmin,
+		body: rotateKeyRequest{}, optionalBody: true, status: 200, resp: client.AgentKey{}},
+
+	{id: "dashboard", method: "GET", path: "/dashboard/*filepath", tag: "meta", summary: "Static dashboard files", status: 200, ctype: "*/*"},
+
+	{id: "demoSet", method: "GET", path: "/demo/set", tag: "demo", summary: "Point the demo target somewhere else",
+		params: []param{{in: "query", name: "to", typ: "string", required: true}}, status: 200,
+		resp: struct {
+			NowPointingTo string `json:"now_pointing_to"`
+		}{}},
+	{id: "demoCurrent", method: "GET", path: "/demo/current", tag: "demo", summary: "Where the demo target points", status: 200,
+		resp: struct {
+			CurrentURL string `json:"current_url"`
+		}{}},
+	{id: "demoTarget", method: "GET", path: "/demo/target", tag: "demo", summary: "Redirect to the current demo URL", status: 307},
+}
+
+// errorDTO is the body of every error response. fields lists validation
+// failures.
+type errorDTO struct {
+	Error  string       `json:"error"`
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+// targetPatch stands in for the PATCH /api/targets/:id body in the spec;
+// the handler reads a map. It becomes a copy of Target with every field
+// optional and nullable.
+type targetPatch struct{}
+
+var ginParamRe = regexp.MustCompile(`[:*](\w+)`)
+
+// BuildSpec documents routes as an OpenAPI 3.0 document.
+func BuildSpec(version string) *openapi.Document {
+	g := openapi.NewGenerator()
+	errRef := g.Of(errorDTO{})
+	target := g.Of(store.TargetRow{})
+	g.Of(manifest{})
+
+	// a manifest names targets by key; the server assigns the rest
+	tname := strings.TrimPrefix(target.Ref, "#/components/schemas/")
+	mt := omitProps(g.Schemas[tname], "id", "project_id", "created_at")
+	g.Schemas["ManifestTarget"] = mt
+	g.Schemas["Manifest"].Properties["targets"].Items = &openapi.Schema{Ref: "#/components/schemas/ManifestTarget"}
+
+	patch := omitProps(g.Schemas[tname])
+	patch.Required = nil
+	for k, p := range patch.Properties {
+		patch.Properties[k] = nullable(p)
+	}
+	g.Schemas["TargetPatch"] = patch
+
+	doc := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title:   "Status Probe Lite API",
+			Version: version,
+			Description: "Management API for users and the dashboard, and the ingest API agents use. " +
+				"Every error response is an Error.",
+		},
+		Paths: map[string]map[string]*openapi.Operation{},
+		Components: openapi.Components{
+			Schemas: g.Schemas,
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearer":  {Type: "http", Scheme: "bearer", Description: "session or API token"},
+				"session": {Type: "apiKey", In: "cookie", Name: sessionCookie, Description: "set by POST /api/auth/login"},
+				"agentKey": {Type: "apiKey", In: "header", Name: "X-Api-Key",
+					Description: "agent key from POST /api/agents/register"},
+			},
+		},
+	}
+
+	for _, rt := range routes {
+		op := &openapi.Operation{
+			OperationID: rt.id,
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Responses:   map[string]*openapi.Response{},
+		}
+		switch rt.auth {
+		case authViewer, authEditor, authAdmin:
+			op.Description = "Requires the " + [...]string{authViewer: "viewer", authEditor: "editor", authAdmin: "admin"}[rt.auth] +
+				" role or higher in the current project."
+			op.Security = []map[string][]string{{"bearer": {}}, {"session": {}}}
+		case authAgent:
+			op.Security = []map[string][]string{{"agentKey": {}}}
+		}
+		for _, m := range ginParamRe.FindAllStringSubmatch(rt.path, -1) {
+			s := &openapi.Schema{Type: "integer", Format: "int64"}
+			if m[0][0] == '*' {
+				s = &openapi.Schema{Type: "string"}
+			}
+			op.Parameters = append(op.Parameters, &openapi.Parameter{Name: m[1], In: "path", Required: true, Schema: s})
+		}
+		for _, p := range rt.params {
+			s := &openapi.Schema{Type: p.typ}
+			if p.typ == "date-time" {
+				s = &openapi.Schema{Type: "string", Format: "date-time"}
+			}
+			op.Parameters = append(op.Parameters, &openapi.Parameter{Name: p.name, In: p.in, Description: p.desc, Required: p.required, Schema: s})
+		}
+		if rt.body != nil {
+			var s *openapi.Schema
+			if _, ok := rt.body.(targetPatch); ok {
+				s = &openapi.Schema{Ref: "#/components/schemas/TargetPatch"}
+			} else {
+				s = g.Of(rt.body)
+			}
+			op.RequestBody = &openapi.RequestBody{Required: !rt.optionalBody, Content: map[string]openapi.MediaType{"application/json": {Schema: s}}}
+			if rt.bodyYAML {
+				op.RequestBody.Content["application/yaml"] = openapi.MediaType{Schema: s}
+			}
+			if rt.bodyNDJSON {
+				op.RequestBody.Content["application/x-ndjson"] = openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+			}
+		}
+
+		op.Responses[strconv.Itoa(rt.status)] = response(g, rt.status, rt.resp, rt.ctype, rt.respYAML)
+		for status, body := range rt.also {
+			op.Responses[strconv.Itoa(status)] = response(g, status, body, "", false)
+		}
+		op.Responses["default"] = &openapi.Response{
+			Description: "Error",
+			Content:     map[string]openapi.MediaType{"application/json": {Schema: errRef}},
+		}
+
+		path := ginParamRe.ReplaceAllString(rt.path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openapi.Operation{}
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = op
+	}
+	return doc
+}
+
+func response(g *openapi.Generator, status int, body any, ctype string, yaml bool) *openapi.Response {
+	r := &openapi.Response{Description: http.StatusText(status)}
+	switch {
+	case ctype != "":
+		r.Content = map[string]openapi.MediaType{ctype: {Schema: &openapi.Schema{Type: "string"}}}
+	case body != nil:
+		s := g.Of(body)
+		r.Content = map[string]openapi.MediaType{"application/json": {Schema: s}}
+		if yaml {
+			r.Content["application/yaml"] = openapi.MediaType{Schema: s}
+		}
+	}
+	return r
+}
+
+// omitProps copies an object schema without the named properties.
+func omitProps(s *openapi.Schema, names ...string) *openapi.Schema {
+	c := *s
+	c.Properties = map[string]*openapi.Schema{}
+	for k, p := range s.Properties {
+		c.Properties[k] = p
+	}
+	c.Required = nil
+	for _, k := range s.Required {
+		c.Required = append(c.Required, k)
+	}
+	for _, k := range names {
+		delete(c.Properties, k)
+		for i, r := range c.Required {
+			if r == k {
+				c.Required = append(c.Required[:i], c.Required[i+1:]...)
+				break
+			}
+		}
+	}
+	return &c
+}
+
+func nullable(s *openapi.Schema) *openapi.Schema {
+	if s.Ref != "" {
+		return &openapi.Schema{Nullable: true, AllOf: []*openapi.Schema{s}}
+	}
+	c := *s
+	c.Nullable = true
+	return &c
+}
+
+// -------- Handler --------
+
+// OpenAPIHandler serves the document and checks traffic against it.
+// Request bodies are always checked loosely, for types only, so a wrong
+// type is a field error rather than "invalid JSON". With
+// ValidateResponses every JSON response is checked strictly and mismatches
+// are logged; that is how the spec and the handlers are kept honest.
+type OpenAPIHandler struct {
+	Doc               *openapi.Document
+	ValidateResponses bool
+
+	spec []byte
+	ops  map[string]*openapi.Operation // "GET /api/targets/:id"
+	raw  map[string]route
+}
+
+func NewOpenAPIHandler(version string, validateResponses bool) *OpenAPIHandler {
+	doc := BuildSpec(version)
+	h := &OpenAPIHandler{Doc: doc, ValidateResponses: validateResponses,
+		ops: map[string]*openapi.Operation{}, raw: map[string]route{}}
+	h.spec, _ = json.MarshalIndent(doc, "", "  ")
+	for _, rt := range routes {
+		path := ginParamRe.ReplaceAllString(rt.path, "{$1}")
+		h.ops[rt.method+" "+rt.path] = doc.Paths[path][strings.ToLower(rt.method)]
+		h.raw[rt.method+" "+rt.path] = rt
+	}
+	return h
+}
+
+func (h *OpenAPIHandler) Register(r *gin.Engine) {
+	r.GET("/api/openapi.json", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", h.spec) })
+}
+
+// CheckRoutes compares the registered routes with the document and
+// describes each difference. HEAD routes come with GET and are skipped.
+func (h *OpenAPIHandler) CheckRoutes(registered gin.RoutesInfo) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, ri := range registered {
+		if ri.Method == http.MethodHead {
+			continue
+		}
+		k := ri.Method + " " + ri.Path
+		seen[k] = true
+		if h.ops[k] == nil {
+			out = append(out, k+" is not documented")
+		}
+	}
+	for k := range h.ops {
+		if !seen[k] {
+			out = append(out, k+" is documented but not registered")
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Middleware must be installed with r.Use before any route is registered.
+// It only validates responses; request bodies are checked by checkBody,
+// which route groups run after auth so that unauthenticated callers can't
+// make the server read and parse their bodies.
+func (h *OpenAPIHandler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := c.Request.Method + " " + c.FullPath()
+		op := h.ops[k]
+		if op == nil {
+			c.Next()
+			return
+		}
+		c.Set(specKey, h)
+		rt := h.raw[k]
+		if !h.ValidateResponses || rt.ctype != "" {
+			c.Next()
+			return
+		}
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		h.checkResponse(c, op, w)
+	}
+}
+
+const specKey = "openapi"
+
+// maxRequestBody caps the JSON bodies checkBody reads into memory.
+// Streamed bodies (NDJSON imports, YAML manifests) are not read.
+const maxRequestBody = 4 << 20
+
+// checkBody is route group middleware, installed after the group's auth.
+// It does nothing when the OpenAPI middleware is not installed.
+func checkBody(c *gin.Context) {
+	h, _ := c.Value(specKey).(*OpenAPIHandler)
+	if h == nil {
+		return
+	}
+	if !h.checkRequest(c, h.ops[c.Request.Method+" "+c.FullPath()]) {
+		c.Abort()
+	}
+}
+
+// checkRequest writes a 400 and returns false when a JSON body has a value
+// of the wrong type, or a 413 when it is over maxRequestBody. The handlers
+// read any body as JSON whatever its Content-Type, so only the other
+// documented types (YAML manifests) are skipped. Bodies that don't parse
+// are left to the handler.
+func (h *OpenAPIHandler) checkRequest(c *gin.Context, op *openapi.Operation) bool {
+	if op == nil || op.RequestBody == nil || c.Request.Body == nil {
+		return true
+	}
+	mt, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return true
+	}
+	if ct := c.ContentType(); !isJSON(ct) && op.RequestBody.Content[ct].Schema != nil {
+		return true
+	}
+	b, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBody))
+	c.Request.Body = io.NopCloser(bytes.NewReader(b))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("body is over %d bytes", tooLarge.Limit)})
+		return false
+	}
+	if err != nil || len(bytes.TrimSpace(b)) == 0 {
+		return true
+	}
+	v, err := openapi.Decode(b)
+	if err != nil {
+		return true
+	}
+	problems := h.Doc.Validate(mt.Schema, v, false)
+	if len(problems) == 0 {
+		return true
+	}
+	var fe fieldErrors
+	for _, p := range problems {
+		field := p.Path
+		if field == "" {
+			field = "body"
+		}
+		fe.add(field, "%s", p.Message)
+	}
+	writeFieldErrors(c, http.StatusBadRequest, fe)
+	return false
+}
+
+func (h *OpenAPIHandler) checkResponse(c *gin.Context, op *openapi.Operation, w *captureWriter) {
+	for _, p := range h.responseProblems(op, w.Status(), w.Header().Get("Content-Type"), w.buf.Bytes()) {
+		h.logMismatch(c, w.Status(), p)
+	}
+}
+
+// responseProblems describes how a response differs from what op
+// documents for its status, checking JSON bodies strictly.
+func (h *OpenAPIHandler) responseProblems(op *openapi.Operation, status int, ctype string, body []byte) []string {
+	resp := op.Responses[strconv.Itoa(status)]
+	if resp == nil {
+		if status < 400 {
+			return []string{"status is not documented"}
+		}
+		resp = op.Responses["default"]
+	}
+	mt, ok := resp.Content["application/json"]
+	if !ok {
+		if len(body) > 0 && isJSON(ctype) {
+			return []string{"body is not documented"}
+		}
+		return nil
+	}
+	if !isJSON(ctype) {
+		// e.g. YAML from ?format=yaml
+		if _, ok := resp.Content[mediaType(ctype)]; !ok {
+			return []string{fmt.Sprintf("content type %q is not documented", ctype)}
+		}
+		return nil
+	}
+	v, err := openapi.Decode(body)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	var out []string
+	for _, p := range h.Doc.Validate(mt.Schema, v, true) {
+		out = append(out, p.String())
+	}
+	return out
+}
+
+// logMismatch reports a response that differs from the spec. Only
+// reached with ValidateResponses set; the client has already been sent the
+// response, so this is for the server's operators.
+func (h *OpenAPIHandler) logMismatch(c *gin.Context, status int, problem string) {
+	fmt.Printf("[openapi] response mismatch: %s %s %d: %s\n", c.Request.Method, c.FullPath(), status, problem)
+}
+
+func mediaType(ct string) string {
+	mt, _, _ := mime.ParseMediaType(ct)
+	return mt
+}
+
+func isJSON(ct string) bool { return mediaType(ct) == "application/json" }
+
+// captureWriter keeps a copy of the response body for checkResponse.
+type captureWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/demo"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/openapi"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+)
+
+const testPassword = "secretpass1"
+
+// newTestServer wires the handlers the way cmd/server does, on a fresh
+// SQLite database.
+func newTestServer(t testing.TB) (*gin.Engine, *OpenAPIHandler, *store.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	st, err := store.Connect("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	if _, err := st.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	spec := NewOpenAPIHandler("test", false)
+	r.Use(spec.Middleware())
+	spec.Register(r)
+	r.GET("/healthz", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/version", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"version": "test"}) })
+
+	auth := NewAuthHandler(st, time.Hour)
+	if err := auth.Bootstrap(context.Background(), "admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	auth.Register(r)
+	NewProjectsHandler(st).Register(r)
+	NewConfigHandler(st).Register(r)
+	NewBackupHandler(st).Register(r)
+	NewTargetsHandler(st).Register(r)
+	NewMetricsHandler(st).Register(r)
+	NewOutagesHandler(st).Register(r)
+	logs := NewLogsHandler(st)
+	logs.Register(r)
+	t.Cleanup(logs.Close)
+	alerts := NewAlerter(st)
+	NewAgentsHandler(st, alerts).Register(r)
+	NewIngestHandler(st, logs, alerts).Register(r)
+	r.Static("/dashboard", "../web/static")
+	demo.NewToggler("https://example.com").Register(r)
+	return r, spec, st
+}
+
+func TestOpenAPIRoutesRegistered(t *testing.T) {
+	r, spec, _ := newTestServer(t)
+	for _, p := range spec.CheckRoutes(r.Routes()) {
+		t.Errorf("%s", p)
+	}
+}
+
+// contractCall is one request of TestOpenAPIContract. path may refer to
+// ids saved by earlier calls as {name}; save names the response's id.
+type contractCall struct {
+	route   string // route id in routes
+	path    string
+	body    string
+	agent   bool // authenticate with the agent key instead of the session
+	save    string
+	saveKey string // save the response's api_key as the agent key
+}
+
+// TestOpenAPIContract calls every documented route and checks each
+// response strictly against the document.
+func TestOpenAPIContract(t *testing.T) {
+	r, spec, _ := newTestServer(t)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	hc := srv.Client()
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	calls := []contractCall{
+		{route: "healthz", path: "/healthz"},
+		{route: "version", path: "/version"},
+		{route: "openapi", path: "/api/openapi.json"},
+		{route: "login", path: "/api/auth/login", body: `{"username":"admin","password":"` + testPassword + `"}`},
+		{route: "me", path: "/api/auth/me"},
+		{route: "exportData", path: "/api/export"},
+		{route: "backup", path: "/api/backup"},
+
+		{route: "createProject", path: "/api/projects", body: `{"name":"staging"}`, save: "project"},
+		{route: "listProjects", path: "/api/projects"},
+		{route: "renameProject", path: "/api/projects/{project}", body: `{"name":"stage"}`},
+		{route: "createUser", path: "/api/users", body: `{"username":"ops","password":"opspass123","role":"editor"}`, save: "user"},
+		{route: "listUsers", path: "/api/users"},
+		{route: "updateUser", path: "/api/users/{user}", body: `{"role":"viewer"}`},
+		{route: "addMember", path: "/api/projects/{project}/members/{user}"},
+		{route: "listMembers", path: "/api/projects/{project}/members"},
+		{route: "removeMember", path: "/api/projects/{project}/members/{user}"},
+		{route: "switchProject", path: "/api/auth/project", body: `{"project_id":1}`},
+		{route: "createToken", path: "/api/tokens", body: `{"name":"ci","expires_in_sec":3600}`, save: "token"},
+		{route: "listTokens", path: "/api/tokens"},
+		{route: "deleteToken", path: "/api/tokens/{token}"},
+
+		{route: "createTarget", path: "/api/targets", body: `{"name":"api","url":"https://example.com","tags":["prod"]}`, save: "target"},
+		{route: "listTargets", path: "/api/targets?tag=prod"},
+		{route: "exportTargets", path: "/api/targets/export"},
+		{route: "getTarget", path: "/api/targets/{target}"},
+		{route: "updateTarget", path: "/api/targets/{target}", body: `{"timeout_ms":2000,"description":null}`},
+		{route: "pauseTarget", path: "/api/targets/{target}/pause"},
+		{route: "resumeTarget", path: "/api/targets/{target}/resume"},
+
+		{route: "registerAgent", path: "/api/agents/register", body: `{"name":"edge"}`, saveKey: "agent"},
+		{route: "heartbeat", path: "/api/agents/heartbeat", agent: true, body: `{"version":"test","hostname":"edge-1","uptime_sec":5}`},
+		{route: "agentTargets", path: "/api/agents/targets", agent: true},
+		{route: "ingestChecks", path: "/api/ingest/checks", agent: true, body: `{"checks":[{"target_id":{target},"ts":"` + now +
+			`","status_code":503,"ok":false,"latency_ms":12,"error":"http_5xx","timings":{"ttfb_ms":10},"logs":[{"ts":"` + now + `","level":"error","line":"503"}]}]}`},
+		{route: "listAgents", path: "/api/agents"},
+		{route: "getAgent", path: "/api/agents/{agent}"},
+		{route: "updateAgent", path: "/api/agents/{agent}", body: `{"labels":{"region":"eu"}}`},
+		{route: "rotateAgentKey", path: "/api/agents/{agent}/rotate-key", body: `{"overlap_sec":60}`},
+
+		{route: "getMetrics", path: "/api/metrics?target_id={target}"},
+		{route: "listOutages", path: "/api/outages?target_id={target}"},
+		{route: "listLogs", path: "/api/logs?target_id={target}"},
+		{route: "streamLogs", path: "/api/logs/stream?target_id={target}"},
+
+		{route: "exportConfig", path: "/api/config/export"},
+		{route: "planConfig", path: "/api/config/plan", body: `{"version":1,"groups":[{"key":"core","name":"Core"}]}`},
+		{route: "applyConfig", path: "/api/config/apply", body: `{"version":1,"groups":[{"key":"core","name":"Core"}]}`},
+
+		{route: "dashboard", path: "/dashboard/"},
+		{route: "demoSet", path: "/demo/set?to=https://example.org"},
+		{route: "demoCurrent", path: "/demo/current"},
+		{route: "demoTarget", path: "/demo/target"},
+
+		{route: "revokeAgent", path: "/api/agents/{agent}"},
+		{route: "deleteTarget", path: "/api/targets/{target}"},
+		{route: "deleteUser", path: "/api/users/{user}"},
+		{route: "deleteProject", path: "/api/projects/{project}"},
+		{route: "logout", path: "/api/auth/logout"},
+	}
+
+	byID := map[string]route{}
+	for _, rt := range routes {
+		byID[rt.id] = rt
+	}
+	ids := map[string]string{}
+	var session, agentKey, export string
+	called := map[string]bool{}
+	for _, call := range calls {
+		rt, ok := byID[call.route]
+		if !ok {
+			t.Fatalf("%s: no such route", call.route)
+		}
+		called[call.route] = true
+		path, body := call.path, call.body
+		for name, id := range ids {
+			path = strings.ReplaceAll(path, "{"+name+"}", id)
+			body = strings.ReplaceAll(body, "{"+name+"}", id)
+		}
+		op := spec.ops[rt.method+" "+rt.path]
+		if body != "" {
+			checkBodyDocumented(t, spec, call.route, op, body)
+		}
+
+		req, err := http.NewRequest(rt.method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		switch {
+		case call.agent:
+			req.Header.Set("X-API-Key", agentKey)
+		case session != "":
+			req.Header.Set("Authorization", "Bearer "+session)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		resp, err := hc.Do(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			t.Fatalf("%s %s: %v", rt.method, path, err)
+		}
+		var b []byte
+		if rt.ctype == "text/event-stream" {
+			// the stream never ends; the status and headers are enough
+			resp.Body.Close()
+		} else {
+			b, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		if resp.StatusCode != rt.status {
+			t.Errorf("%s %s: status %d, documented %d: %s", rt.method, path, resp.StatusCode, rt.status, b)
+			continue
+		}
+		for _, p := range spec.responseProblems(op, resp.StatusCode, resp.Header.Get("Content-Type"), b) {
+			t.Errorf("%s %s %d: %s", rt.method, path, resp.StatusCode, p)
+		}
+		if rt.ctype != "" && rt.ctype != "*/*" && mediaType(resp.Header.Get("Content-Type")) != rt.ctype {
+			t.Errorf("%s %s: content type %q, documented %q", rt.method, path, resp.Header.Get("Content-Type"), rt.ctype)
+		}
+
+		var out struct {
+			ID     int64  `json:"id"`
+			Token  string `json:"token"`
+			APIKey string `json:"api_key"`
+			Agent  int64  `json:"agent_id"`
+		}
+		_ = json.Unmarshal(b, &out)
+		switch {
+		case call.route == "login":
+			session = out.Token
+		case call.route == "exportData":
+			export = string(b)
+		case call.save != "":
+			ids[call.save] = fmt.Sprint(out.ID)
+		case call.saveKey != "":
+			ids[call.saveKey] = fmt.Sprint(out.Agent)
+			agentKey = out.APIKey
+		}
+	}
+
+	// import needs a server without targets or agents
+	r2, spec2, _ := newTestServer(t)
+	srv2 := httptest.NewServer(r2)
+	defer srv2.Close()
+	called["importData"] = true
+	if resp := importExport(t, srv2.URL, export); resp.status != http.StatusOK {
+		t.Errorf("POST /api/import: status %d: %s", resp.status, resp.body)
+	} else {
+		op := spec2.ops["POST /api/import"]
+		for _, p := range spec2.responseProblems(op, resp.status, resp.ctype, resp.body) {
+			t.Errorf("POST /api/import %d: %s", resp.status, p)
+		}
+	}
+
+	for _, rt := range routes {
+		if !called[rt.id] {
+			t.Errorf("%s %s is not called", rt.method, rt.path)
+		}
+	}
+}
+
+// checkBodyDocumented fails when a request body of the test itself does
+// not match the document, checked loosely like the server checks requests.
+func checkBodyDocumented(t *testing.T, spec *OpenAPIHandler, id string, op *openapi.Operation, body string) {
+	t.Helper()
+	if op.RequestBody == nil {
+		t.Errorf("%s: sends a body but none is documented", id)
+		return
+	}
+	v, err := openapi.Decode([]byte(body))
+	if err != nil {
+		t.Fatalf("%s: %v", id, err)
+	}
+	for _, p := range spec.Doc.Validate(op.RequestBody.Content["application/json"].Schema, v, false) {
+		t.Errorf("%s request: %s", id, p)
+	}
+}
+
+type rawResponse struct {
+	status int
+	ctype  string
+	body   []byte
+}
+
+func importExport(t *testing.T, base, export string) rawResponse {
+	t.Helper()
+	tok := loginSession(t, base)
+	req, _ := http.NewRequest(http.MethodPost, base+"/api/import", strings.NewReader(export))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Authorization", "Bearer "+tok)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return rawResponse{status: resp.StatusCode, ctype: resp.Header.Get("Content-Type"), body: b}
+}
+
+func loginSession(t testing.TB, base string) string {
+	t.Helper()
+	body, _ := json.Marshal(loginRequest{Username: "admin", Password: testPassword})
+	resp, err := http.Post(base+"/api/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out loginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Token == "" {
+		t.Fatalf("login: %d %v", resp.StatusCode, err)
+	}
+	return out.Token
+}
+
+func TestCheckBody(t *testing.T) {
+	r, _, _ := newTestServer(t)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	tok := loginSession(t, srv.URL)
+
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{"wrong type", tok, `{"name":"api","url":"https://example.com","timeout_ms":"fast"}`, http.StatusBadRequest},
+		{"checked after auth", "", `{"name":"api","url":"https://example.com","timeout_ms":"fast"}`, http.StatusUnauthorized},
+		{"too large", tok, `{"name":"api","url":"https://example.com","description":"` + strings.Repeat("x", maxRequestBody) + `"}`, http.StatusRequestEntityTooLarge},
+		{"valid", tok, `{"name":"api","url":"https://example.com"}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/targets", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d: %s", resp.StatusCode, tt.want, b)
+			}
+		})
+	}
+}
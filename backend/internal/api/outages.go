@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
+)
+
+type OutagesHandler struct{ Store store.Storage }
+
+func NewOutagesHandler(st store.Storage) *OutagesHandler { return &OutagesHandler{Store: st} }
+
+func (h *OutagesHandler) Register(r *gin.Engine) {
+	g := r.Group("/api")
+	g.GET("/outages", RequireRole(h.Store, store.RoleViewer), h.list) // GET /api/outages?target_id=1&open=true&from=RFC3339&to=RFC3339&limit=100
+}
+
+func (h *OutagesHandler) list(c *gin.Context) {
+	pid := projectID(c)
+	f := store.OutageFilter{Limit: 100}
+	if s := c.Query("target_id"); s != "" {
+		tid, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || tid <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
+			return
+		}
+		f.TargetID = tid
+	}
+	f.OpenOnly = c.Query("open") == "true" || c.Query("open") == "1"
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if s := c.Query(p.name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "bad " + p.name})
+				return
+			}
+			*p.dst = t
+		}
+	}
+	if s := c.Query("limit"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 && n <= 1000 {
+			f.Limit = n
+		}
+	}
+
+	rows, err := h.Store.ListOutages(c.Request.Context(), pid, f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list outages failed"})
+		return
+	}
+	targets, err := h.Store.ListTargets(c.Request.Context(), pid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list targets failed"})
+		return
+	}
+	names := make(map[int64]string, len(targets))
+	for _, t := range targets {
+		names[t.ID] = t.Name
+	}
+
+	now := time.Now().UTC()
+	out := make([]client.Outage, 0, len(rows))
+	for _, o := range rows {
+		d := client.Outage{
+			ID:         o.ID,
+			TargetID:   o.TargetID,
+			TargetName: names[o.TargetID],
+			StartedAt:  o.StartedAt.UTC().Format(time.RFC3339),
+			DurationMs: now.Sub(o.StartedAt).Milliseconds(),
+			Reason:     o.Reason,
+		}
+		if o.EndedAt.Valid {
+			s := o.EndedAt.Time.UTC().Format(time.RFC3339)
+			d.EndedAt = &s
+			d.DurationMs = o.EndedAt.Time.Sub(o.StartedAt).Milliseconds()
+		}
+		out = append(out, d)
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+)
+
+// ProjectsHandler manages tenants. Admins see and manage every project;
+// other users only list the projects they are members of.
+type ProjectsHandler struct{ Store store.Storage }
+
+func NewProjectsHandler(st store.Storage) *ProjectsHandler { return &ProjectsHandler{Store: st} }
+
+func (h *ProjectsHandler) Register(r *gin.Engine) {
+	g := r.Group("/api/projects")
+	g.GET("", RequireRole(h.Store, store.RoleViewer), h.listProjects)
+
+	admin := g.Group("", RequireRole(h.Store, store.RoleAdmin), checkBody)
+	admin.POST("", h.createProject)
+	admin.PATCH("/:id", h.renameProject)
+	admin.DELETE("/:id", h.deleteProject)
+	admin.GET("/:id/members", h.listMembers)
+	admin.PUT("/:id/members/:user_id", h.addMember)
+	admin.DELETE("/:id/members/:user_id", h.removeMember)
+}
+
+type projectDTO struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// projectNameRequest creates or renames a project.
+type projectNameRequest struct {
+	Name string `json:"name"`
+}
+
+func toProjectDTO(p store.ProjectRow) projectDTO {
+	return projectDTO{ID: p.ID, Name: p.Name, CreatedAt: p.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+func (h *ProjectsHandler) listProjects(c *gin.Context) {
+	ctx := c.Request.Context()
+	u, err := h.Store.GetUser(ctx, c.GetInt64("user_id"))
+	if err != nil || u == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
+	rows, err := userProjects(ctx, h.Store, u)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list projects"})
+		return
+	}
+	out := make([]projectDTO, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, toProjectDTO(p))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *ProjectsHandler) createProject(c *gin.Context) {
+	var body projectNameRequest
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	ctx := c.Request.Context()
+	id, err := h.Store.CreateProject(ctx, strings.TrimSpace(body.Name))
+	if err != nil {
+		if store.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "project name taken"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	p, err := h.Store.GetProject(ctx, id)
+	if err != nil || p == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, toProjectDTO(*p))
+}
+
+// loadProject resolves :id, writing the error response itself when it
+// returns nil.
+func (h *ProjectsHandler) loadProject(c *gin.Context) *store.ProjectRow {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil
+	}
+	p, err := h.Store.GetProject(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load project"})
+		return nil
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return nil
+	}
+	return p
+}
+
+func (h *ProjectsHandler) renameProject(c *gin.Context) {
+	p := h.loadProject(c)
+	if p == nil {
+		return
+	}
+	var body projectNameRequest
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	p.Name = strings.TrimSpace(body.Name)
+	if err := h.Store.RenameProject(c.Request.Context(), p.ID, p.Name); err != nil {
+		if store.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "project name taken"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toProjectDTO(*p))
+}
+
+// deleteProject only removes projects without targets or agents, so
+// history is never dropped by accident; delete those first. Memberships,
+// tokens and config objects go with the project.
+func (h *ProjectsHandler) deleteProject(c *gin.Context) {
+	p := h.loadProject(c)
+	if p == nil {
+		return
+	}
+	if p.ID == store.DefaultProjectID {
+		c.JSON(http.StatusConflict, gin.H{"error": "the default project cannot be deleted"})
+		return
+	}
+	ctx := c.Request.Context()
+	inUse, err := h.Store.ProjectInUse(ctx, p.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	if inUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "project still has targets or agents"})
+		return
+	}
+	if err := h.Store.DeleteProject(ctx, p.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProjectsHandler) listMembers(c *gin.Context) {
+	p := h.loadProject(c)
+	if p == nil {
+		return
+	}
+	rows, err := h.Store.ListProjectMembers(c.Request.Context(), p.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list members"})
+		return
+	}
+	out := make([]userDTO, 0, len(rows))
+	for _, u := range rows {
+		out = append(out, toUserDTO(u))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// memberUser resolves :user_id, writing the error response itself when it
+// returns nil.
+func (h *ProjectsHandler) memberUser(c *gin.Context) *store.UserRow {
+	id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return nil
+	}
+	u, err := h.Store.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return nil
+	}
+	if u == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil
+	}
+	return u
+}
+
+func (h *ProjectsHandler) addMember(c *gin.Context) {
+	p := h.loadProject(c)
+	if p == nil {
+		return
+	}
+	u := h.memberUser(c)
+	if u == nil {
+		return
+	}
+	if err := h.Store.AddProjectMember(c.Request.Context(), p.ID, u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "add member failed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// removeMember revokes access at once: the user's tokens for the project
+// stop working on their next request.
+func (h *ProjectsHandler) removeMember(c *gin.Context) {
+	p := h.loadProject(c)
+	if p == nil {
+		return
+	}
+	u := h.memberUser(c)
+	if u == nil {
+		return
+	}
+	if err := h.Store.RemoveProjectMember(c.Request.Context(), p.ID, u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "remove member failed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
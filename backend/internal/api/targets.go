@@ -1,148 +1,633 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/niranjini-kathiravan/status-probe-lite/backend/internal/store"
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
 )
 
 type TargetsHandler struct {
-	Store *store.Store
+	Store store.Storage
 }
 
-func NewTargetsHandler(st *store.Store) *TargetsHandler {
+func NewTargetsHandler(st store.Storage) *TargetsHandler {
 	return &TargetsHandler{Store: st}
 }
 
 func (h *TargetsHandler) Register(r *gin.Engine) {
 	g := r.Group("/api/targets")
-	g.GET("", h.listTargets)
-	g.POST("", h.createTarget)
-	g.DELETE("/:id", h.deleteTarget)
+	viewer := g.Group("", RequireRole(h.Store, store.RoleViewer))
+	viewer.GET("", h.listTargets)
+	viewer.GET("/export", h.exportTargets)
+	viewer.GET("/:id", h.getTarget)
+
+	editor := g.Group("", RequireRole(h.Store, store.RoleEditor), checkBody)
+	editor.POST("", h.createTarget)
+	editor.PATCH("/:id", h.updateTarget)
+	editor.DELETE("/:id", h.deleteTarget)
+	editor.POST("/:id/pause", h.pauseTarget)
+	editor.POST("/:id/resume", h.resumeTarget)
 }
 
 // -------- Handlers --------
 
+// listTargets takes an optional ?tag= filter.
 func (h *TargetsHandler) listTargets(c *gin.Context) {
-	rows, err := h.Store.ListTargets(c.Request.Context())
+	rows, err := h.Store.ListTargets(c.Request.Context(), projectID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list targets"})
 		return
 	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		filtered := rows[:0]
+		for _, t := range rows {
+			if hasTag(t.Tags, tag) {
+				filtered = append(filtered, t)
+			}
+		}
+		rows = filtered
+	}
+	if rows == nil {
+		rows = []store.TargetRow{}
+	}
 	c.JSON(http.StatusOK, rows)
 }
 
-func (h *TargetsHandler) createTarget(c *gin.Context) {
-	var req struct {
-		Name      string `json:"name"`
-		URL       string `json:"url"`
-		TimeoutMs int    `json:"timeout_ms"`
+// exportTargets returns the project's targets as a file in the format agents
+// read from TARGETS_FILE, paused targets included.
+func (h *TargetsHandler) exportTargets(c *gin.Context) {
+	rows, err := h.Store.ListTargets(c.Request.Context(), projectID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list targets"})
+		return
 	}
+	if rows == nil {
+		rows = []store.TargetRow{}
+	}
+	b, _ := json.MarshalIndent(rows, "", "  ")
+	c.Header("Content-Disposition", `attachment; filename="targets.json"`)
+	c.Data(http.StatusOK, "application/json", b)
+}
+
+func (h *TargetsHandler) createTarget(c *gin.Context) {
+	var req store.TargetRow
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
 		return
 	}
-	if !(strings.HasPrefix(req.URL, "http://") || strings.HasPrefix(req.URL, "https://")) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "URL must start with http:// or https://"})
+	if fe := validateTarget(&req); len(fe) > 0 {
+		writeFieldErrors(c, http.StatusBadRequest, fe)
 		return
 	}
-	if req.TimeoutMs <= 0 {
-		req.TimeoutMs = 4000
+	if h.nameTaken(c, projectID(c), req.Name, 0) || h.groupMissing(c, projectID(c), req.Group) {
+		return
 	}
 
-	id, err := h.Store.InsertTarget(c.Request.Context(), req.Name, req.URL, req.TimeoutMs)
+	// keys belong to declarative config; see /api/config
+	out := req
+	out.Key = ""
+	out.ID = 0
+	out.ProjectID = projectID(c)
+	out.CreatedAt = time.Now().UTC()
+	out.Paused = false
+	id, err := h.Store.InsertTarget(c.Request.Context(), out)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create target"})
 		return
 	}
+	out.ID = id
+
+	c.JSON(http.StatusCreated, out)
+}
+
+// loadTarget resolves :id in the caller's project, writing the error
+// response itself when it returns nil.
+func (h *TargetsHandler) loadTarget(c *gin.Context) *store.TargetRow {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil
+	}
+	t, err := h.Store.GetTarget(c.Request.Context(), projectID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load target"})
+		return nil
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "target not found"})
+		return nil
+	}
+	return t
+}
 
-	out := targetFileEntry{
-		ID:        id,
-		Name:      req.Name,
-		URL:       req.URL,
-		TimeoutMs: req.TimeoutMs,
+func (h *TargetsHandler) getTarget(c *gin.Context) {
+	t := h.loadTarget(c)
+	if t == nil {
+		return
 	}
+	c.JSON(http.StatusOK, t)
+}
 
-	appendTargetToFile(filepath.Join("cmd", "agent", "targets.json"), out)
+// updateTarget merges the body into the stored target field by field:
+// omitted fields are kept, null clears a field. id, project_id, key,
+// created_at and paused are not changed here.
+func (h *TargetsHandler) updateTarget(c *gin.Context) {
+	cur := h.loadTarget(c)
+	if cur == nil {
+		return
+	}
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+	var merged map[string]json.RawMessage
+	b, _ := json.Marshal(cur)
+	_ = json.Unmarshal(b, &merged)
+	for k, v := range patch {
+		merged[k] = v
+	}
+	b, _ = json.Marshal(merged)
+	var next store.TargetRow
+	if err := json.Unmarshal(b, &next); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+	next.ID, next.ProjectID, next.Key, next.CreatedAt, next.Paused = cur.ID, cur.ProjectID, cur.Key, cur.CreatedAt, cur.Paused
 
-	c.JSON(http.StatusCreated, out)
+	if fe := validateTarget(&next); len(fe) > 0 {
+		writeFieldErrors(c, http.StatusBadRequest, fe)
+		return
+	}
+	if h.nameTaken(c, next.ProjectID, next.Name, next.ID) || h.groupMissing(c, next.ProjectID, next.Group) {
+		return
+	}
+	if err := h.Store.UpdateTarget(c.Request.Context(), next); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, next)
 }
 
 func (h *TargetsHandler) deleteTarget(c *gin.Context) {
-	idStr := c.Param("id")
-	if idStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+	t := h.loadTarget(c)
+	if t == nil {
 		return
 	}
+	if err := h.Store.DeleteTarget(c.Request.Context(), t.ProjectID, t.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+// pauseTarget stops checks for a target. An open outage ends at the pause
+// so the paused period counts as neither up nor down.
+func (h *TargetsHandler) pauseTarget(c *gin.Context) {
+	t := h.loadTarget(c)
+	if t == nil {
 		return
 	}
+	if err := pauseAndEndOutage(c.Request.Context(), h.Store, t.ProjectID, t.ID, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "pause failed"})
+		return
+	}
+	t.Paused = true
+	c.JSON(http.StatusOK, t)
+}
+
+// pauseAndEndOutage pauses a target and ends its open outage, if any, at the
+// same time. Pausing a paused target does nothing.
+func pauseAndEndOutage(ctx context.Context, st store.Storage, projectID, id int64, at time.Time) error {
+	changed, err := st.PauseTarget(ctx, projectID, id, at)
+	if err != nil || !changed {
+		return err
+	}
+	if open, err := st.GetOpenOutage(ctx, projectID, id); err == nil && open != nil {
+		return st.CloseOutage(ctx, open.ID, at)
+	}
+	return nil
+}
 
-	if err := h.Store.DeleteTarget(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+func (h *TargetsHandler) resumeTarget(c *gin.Context) {
+	t := h.loadTarget(c)
+	if t == nil {
 		return
 	}
+	if _, err := h.Store.ResumeTarget(c.Request.Context(), t.ProjectID, t.ID, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "resume failed"})
+		return
+	}
+	t.Paused = false
+	c.JSON(http.StatusOK, t)
+}
 
-	removeTargetFromFile(filepath.Join("cmd", "agent", "targets.json"), id)
+// nameTaken writes a 409 and returns true if another target in the project
+// (other than exceptID) already uses name.
+func (h *TargetsHandler) nameTaken(c *gin.Context, projectID int64, name string, exceptID int64) bool {
+	other, err := h.Store.FindTargetByName(c.Request.Context(), projectID, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check name"})
+		return true
+	}
+	if other == nil || other.ID == exceptID {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "duplicate target name", "fields": fieldErrors{
+		{Field: "name", Message: fmt.Sprintf("already used by target %d", other.ID)},
+	}})
+	return true
+}
 
-	c.Status(http.StatusNoContent)
+// groupMissing writes a 400 and returns true if group is set but doesn't
+// exist in the project.
+func (h *TargetsHandler) groupMissing(c *gin.Context, projectID int64, group string) bool {
+	if group == "" {
+		return false
+	}
+	ok, err := h.Store.GroupExists(c.Request.Context(), projectID, group)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check group"})
+		return true
+	}
+	if !ok {
+		writeFieldErrors(c, http.StatusBadRequest, fieldErrors{{Field: "group", Message: "no such group"}})
+	}
+	return !ok
 }
 
-type targetFileEntry struct {
-	ID        int64  `json:"id"`
-	Name      string `json:"name"`
-	URL       string `json:"url"`
-	TimeoutMs int    `json:"timeout_ms"`
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
-func appendTargetToFile(path string, newT targetFileEntry) {
+// -------- Validation --------
+
+// fieldError is one problem with one field of a request body; Field uses
+// the JSON names, e.g. "steps[1].url".
+type fieldError = client.FieldError
 
-	var items []targetFileEntry
-	if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
-		_ = json.Unmarshal(b, &items)
+// fieldErrors collects every problem so clients can show them all at once.
+type fieldErrors []fieldError
+
+func (fe *fieldErrors) add(field, format string, args ...any) {
+	*fe = append(*fe, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (fe fieldErrors) Error() string {
+	parts := make([]string, 0, len(fe))
+	for _, e := range fe {
+		parts = append(parts, e.Field+": "+e.Message)
 	}
+	return strings.Join(parts, "; ")
+}
 
-	items = append(items, newT)
+func writeFieldErrors(c *gin.Context, status int, fe fieldErrors) {
+	c.JSON(status, gin.H{"error": "validation failed", "fields": fe})
+}
 
-	_ = ensureDir(path)
-	data, _ := json.MarshalIndent(items, "", "  ")
-	_ = os.WriteFile(path, data, 0644)
-	fmt.Printf("→ Added target #%d to %s\n", newT.ID, path)
+// validateAssertions rejects specs the agent could not evaluate.
+func validateAssertions(prefix string, a *store.Assertions, fe *fieldErrors) {
+	if a == nil {
+		return
+	}
+	for i, sc := range a.StatusCodes {
+		if !validStatusSpec(sc) {
+			fe.add(fmt.Sprintf("%sassertions.status_codes[%d]", prefix, i), "invalid status code spec %q", sc)
+		}
+	}
+	for i, re := range a.BodyRegex {
+		if _, err := regexp.Compile(re); err != nil {
+			fe.add(fmt.Sprintf("%sassertions.body_regex[%d]", prefix, i), "invalid regex %q", re)
+		}
+	}
+	for i, jp := range a.JSONPath {
+		field := fmt.Sprintf("%sassertions.json_path[%d]", prefix, i)
+		if !strings.HasPrefix(jp.Path, "$") {
+			fe.add(field+".path", "must start with $")
+		}
+		if len(jp.Equals) == 0 || !json.Valid(jp.Equals) {
+			fe.add(field+".equals", "must be a JSON value")
+		}
+	}
+	for i, hd := range a.Headers {
+		if hd.Name == "" {
+			fe.add(fmt.Sprintf("%sassertions.headers[%d].name", prefix, i), "required")
+		}
+	}
+	if a.MaxBodyBytes < 0 {
+		fe.add(prefix+"assertions.max_body_bytes", "must not be negative")
+	}
+	if a.MaxLatencyMs < 0 {
+		fe.add(prefix+"assertions.max_latency_ms", "must not be negative")
+	}
 }
 
-func removeTargetFromFile(path string, id int64) {
-	var items []targetFileEntry
-	if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
-		_ = json.Unmarshal(b, &items)
+// interval bounds; 0 means the agent's default
+const (
+	minIntervalSec = 5
+	maxIntervalSec = 24 * 60 * 60
+)
+
+// timeout bounds; 0 means defaultTimeoutMs
+const (
+	defaultTimeoutMs = 4000
+	minTimeoutMs     = 100
+	maxTimeoutMs     = 60_000
+)
+
+const (
+	maxNameLen        = 200
+	maxDescriptionLen = 1000
+	maxTags           = 20
+	maxTagLen         = 64
+)
+
+// validateTarget normalises t in place (type, method, timeout, tags) and
+// returns every problem found.
+func validateTarget(t *store.TargetRow) fieldErrors {
+	var fe fieldErrors
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		fe.add("name", "required")
+	} else if len(t.Name) > maxNameLen {
+		fe.add("name", "must be at most %d characters", maxNameLen)
+	}
+	if len(t.Description) > maxDescriptionLen {
+		fe.add("description", "must be at most %d characters", maxDescriptionLen)
 	}
+	t.Tags = normTags(t.Tags, &fe)
+	if t.Key != "" && !keyRe.MatchString(t.Key) {
+		fe.add("key", "%s", keyRule)
+	}
+	if t.Group != "" && !keyRe.MatchString(t.Group) {
+		fe.add("group", "%s", keyRule)
+	}
+	for k := range t.AgentSelector {
+		if k == "" {
+			fe.add("agent_selector", "label name required")
+		}
+	}
+	if t.TimeoutMs == 0 {
+		t.TimeoutMs = defaultTimeoutMs
+	} else if t.TimeoutMs < minTimeoutMs || t.TimeoutMs > maxTimeoutMs {
+		fe.add("timeout_ms", "must be between %d and %d", minTimeoutMs, maxTimeoutMs)
+	}
+	if t.IntervalSec != 0 && (t.IntervalSec < minIntervalSec || t.IntervalSec > maxIntervalSec) {
+		fe.add("interval_sec", "must be between %d and %d", minIntervalSec, maxIntervalSec)
+	}
+	switch t.Type {
+	case "", store.TargetHTTP:
+		t.Type = store.TargetHTTP
+		if msg := checkHTTPURL(t.URL); msg != "" {
+			fe.add("url", "%s", msg)
+		}
+		validateAssertions("", t.Assertions, &fe)
+		validateRequest("", &t.Method, t.Headers, t.Auth, &fe)
+		if len(t.Steps) > 0 {
+			fe.add("steps", "only supported for multistep targets")
+		}
+	case store.TargetGRPC:
+		if _, _, err := net.SplitHostPort(strings.TrimPrefix(t.URL, "grpc://")); err != nil {
+			fe.add("url", "grpc URL must be host:port")
+		}
+		httpOnly := map[string]bool{
+			"assertions": t.Assertions != nil,
+			"method":     t.Method != "",
+			"headers":    len(t.Headers) > 0,
+			"body":       t.Body != "",
+			"auth":       t.Auth != nil,
+			"steps":      len(t.Steps) > 0,
+		}
+		for _, f := range []string{"assertions", "method", "headers", "body", "auth", "steps"} {
+			if httpOnly[f] {
+				fe.add(f, "not supported for grpc targets")
+			}
+		}
+	case store.TargetMultistep:
+		if len(t.Steps) == 0 {
+			fe.add("steps", "multistep targets need at least one step")
+		}
+		perStep := map[string]bool{
+			"assertions": t.Assertions != nil,
+			"method":     t.Method != "",
+			"headers":    len(t.Headers) > 0,
+			"body":       t.Body != "",
+			"auth":       t.Auth != nil,
+		}
+		for _, f := range []string{"assertions", "method", "headers", "body", "auth"} {
+			if perStep[f] {
+				fe.add(f, "multistep targets take request options per step")
+			}
+		}
+		for i := range t.Steps {
+			validateStep(fmt.Sprintf("steps[%d].", i), &t.Steps[i], &fe)
+		}
+		// the first step stands in for the target on lists and the
+		// dashboard, so it follows the steps through every update
+		if len(t.Steps) > 0 {
+			t.URL = t.Steps[0].URL
+		}
+	default:
+		fe.add("type", "must be http, grpc or multistep")
+	}
+	return fe
+}
 
-	filtered := make([]targetFileEntry, 0, len(items))
-	for _, t := range items {
-		if t.ID != id {
-			filtered = append(filtered, t)
+// normTags trims tags and drops case-insensitive duplicates, keeping the
+// first spelling.
+func normTags(tags []string, fe *fieldErrors) []string {
+	if len(tags) > maxTags {
+		fe.add("tags", "at most %d tags", maxTags)
+	}
+	var out []string
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "":
+			fe.add(fmt.Sprintf("tags[%d]", i), "must not be empty")
+			continue
+		case len(tag) > maxTagLen:
+			fe.add(fmt.Sprintf("tags[%d]", i), "must be at most %d characters", maxTagLen)
+			continue
+		case hasTag(out, tag):
+			continue
 		}
+		out = append(out, tag)
 	}
+	return out
+}
 
-	_ = ensureDir(path)
-	data, _ := json.MarshalIndent(filtered, "", "  ")
-	_ = os.WriteFile(path, data, 0644)
-	fmt.Printf("→ Removed target #%d from %s\n", id, path)
+func validateStep(prefix string, st *store.Step, fe *fieldErrors) {
+	st.Name = strings.TrimSpace(st.Name)
+	if st.Name == "" {
+		fe.add(prefix+"name", "required")
+	}
+	if !strings.HasPrefix(st.URL, "{{") {
+		if msg := checkHTTPURL(st.URL); msg != "" {
+			fe.add(prefix+"url", "%s, or start with a {{var}}", msg)
+		}
+	}
+	if st.TimeoutMs != 0 && (st.TimeoutMs < minTimeoutMs || st.TimeoutMs > maxTimeoutMs) {
+		fe.add(prefix+"timeout_ms", "must be between %d and %d", minTimeoutMs, maxTimeoutMs)
+	}
+	validateAssertions(prefix, st.Assertions, fe)
+	validateRequest(prefix, &st.Method, st.Headers, st.Auth, fe)
+	for i, e := range st.Extract {
+		field := fmt.Sprintf("%sextract[%d]", prefix, i)
+		n := 0
+		for _, src := range []string{e.JSONPath, e.Header, e.Regex} {
+			if src != "" {
+				n++
+			}
+		}
+		if e.Var == "" {
+			fe.add(field+".var", "required")
+		}
+		if n != 1 {
+			fe.add(field, "needs exactly one of json_path, header or regex")
+		}
+		if e.Regex != "" {
+			if _, err := regexp.Compile(e.Regex); err != nil {
+				fe.add(field+".regex", "invalid regex %q", e.Regex)
+			}
+		}
+	}
 }
 
-func ensureDir(path string) error {
-	dir := filepath.Dir(path)
-	if dir == "." || dir == "" {
-		return nil
+// checkHTTPURL returns why u isn't an absolute http(s) URL, or "".
+func checkHTTPURL(u string) string {
+	if u == "" {
+		return "required"
+	}
+	p, err := url.Parse(u)
+	if err != nil {
+		return "not a valid URL"
+	}
+	if p.Scheme != "http" && p.Scheme != "https" {
+		return "must start with http:// or https://"
+	}
+	if p.Host == "" || p.Hostname() == "" {
+		return "must include a host"
+	}
+	if p.User != nil {
+		return "must not include credentials; use auth"
+	}
+	for k, vs := range p.Query() {
+		if !credentialParams[strings.ToLower(k)] {
+			continue
+		}
+		for _, v := range vs {
+			if !secretRefRe.MatchString(v) && !stepVarRe.MatchString(v) {
+				return fmt.Sprintf("query parameter %s must be a ${env:NAME} or ${file:PATH} ref", k)
+			}
+		}
+	}
+	return ""
+}
+
+// credentialParams are query parameters whose values are secrets, held to
+// the same rule as credentialHeaders.
+var credentialParams = map[string]bool{
+	"access_token":  true,
+	"api_key":       true,
+	"apikey":        true,
+	"client_secret": true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+}
+
+// keys of config-managed objects (targets, groups, alert routes,
+// maintenance windows)
+var keyRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,99}$`)
+
+const keyRule = "must be 1-100 letters, digits, '.', '_' or '-', starting with a letter or digit"
+
+var (
+	secretRefRe = regexp.MustCompile(`^\$\{(env|file):[^}]+\}$`)
+	// values captured by an earlier multistep step, e.g. "Bearer {{token}}"
+	stepVarAuthRe = regexp.MustCompile(`^(\w+ )?\{\{\s*[A-Za-z_][A-Za-z0-9_]*\s*\}\}$`)
+	stepVarRe     = regexp.MustCompile(`^\{\{\s*[A-Za-z_][A-Za-z0-9_]*\s*\}\}$`)
+)
+
+// credentialHeaders are headers whose values are secrets, so they may only
+// be refs or captured {{var}}s. Keys are in canonical form.
+var credentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+	"X-Access-Token":      true,
+	"Api-Key":             true,
+}
+
+// validateRequest keeps credentials off the server: auth secrets and
+// credential headers must be agent-side refs rather than literal values.
+// Bodies can't be told apart from data, so secrets in them are left to
+// the caller to write as refs.
+func validateRequest(prefix string, method *string, headers map[string]string, auth *store.Auth, fe *fieldErrors) {
+	*method = strings.ToUpper(*method)
+	switch *method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		fe.add(prefix+"method", "unsupported method %q", *method)
+	}
+	for k, v := range headers {
+		if k == "" {
+			fe.add(prefix+"headers", "header name required")
+		}
+		if credentialHeaders[http.CanonicalHeaderKey(k)] && !secretRefRe.MatchString(v) && !stepVarAuthRe.MatchString(v) {
+			fe.add(prefix+"headers."+k, "must be a ${env:NAME} or ${file:PATH} ref or a {{var}}")
+		}
+	}
+	if a := auth; a != nil {
+		switch a.Type {
+		case "basic":
+			if a.Username == "" {
+				fe.add(prefix+"auth.username", "required for basic auth")
+			}
+			if !secretRefRe.MatchString(a.Password) {
+				fe.add(prefix+"auth.password", "must be a ref like ${env:NAME}")
+			}
+		case "bearer":
+			if !secretRefRe.MatchString(a.Token) {
+				fe.add(prefix+"auth.token", "must be a ref like ${env:NAME}")
+			}
+		default:
+			fe.add(prefix+"auth.type", "must be basic or bearer")
+		}
+	}
+}
+
+// validStatusSpec accepts "200", "2xx" or "200-299".
+func validStatusSpec(s string) bool {
+	code := func(v string) bool {
+		n, err := strconv.Atoi(v)
+		return err == nil && n >= 100 && n <= 599
+	}
+	if len(s) == 3 && strings.HasSuffix(s, "xx") {
+		return s[0] >= '1' && s[0] <= '5'
+	}
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		return code(lo) && code(hi) && lo <= hi
 	}
-	return os.MkdirAll(dir, 0755)
+	return code(s)
 }
@@ -1,11 +1,31 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 type Config struct {
-	Port    string
-	DBPath  string
-	Version string
+	Port string
+	// sqlite (DBPath) or postgres (DatabaseURL)
+	DBDriver    string
+	DBPath      string
+	DatabaseURL string
+	// apply pending schema migrations at startup; if false the server
+	// refuses to start until `server migrate up` has run
+	AutoMigrate bool
+	Version     string
+	// how long SIGTERM waits for in-flight requests before closing the store
+	ShutdownTimeoutSec int
+	// an agent silent for this long is considered offline
+	AgentOfflineAfterSec int
+	// first admin account, created only while there are no users
+	AdminUsername string
+	AdminPassword string
+	// lifetime of dashboard/login sessions
+	SessionTTLHours int
+	// check every JSON response against the OpenAPI document and log mismatches
+	OpenAPIValidateResponses bool
 }
 
 func getEnv(k, def string) string {
@@ -15,10 +35,27 @@ func getEnv(k, def string) string {
 	return def
 }
 
+func getEnvInt(k string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(k)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
 func Load() *Config {
 	return &Config{
-		Port:    getEnv("PORT", "8080"),
-		DBPath:  getEnv("DB_PATH", "./status.db"),
-		Version: getEnv("VERSION", "v0.1"),
+		Port:                 getEnv("PORT", "8080"),
+		DBDriver:             getEnv("DB_DRIVER", "sqlite"),
+		DBPath:               getEnv("DB_PATH", "./status.db"),
+		DatabaseURL:          os.Getenv("DATABASE_URL"),
+		AutoMigrate:          getEnv("AUTO_MIGRATE", "true") != "false",
+		Version:              getEnv("VERSION", "v0.1"),
+		ShutdownTimeoutSec:   getEnvInt("SHUTDOWN_TIMEOUT_SEC", 15),
+		AgentOfflineAfterSec: getEnvInt("AGENT_OFFLINE_AFTER_SEC", 90),
+		AdminUsername:        getEnv("ADMIN_USERNAME", "admin"),
+		AdminPassword:        os.Getenv("ADMIN_PASSWORD"),
+		SessionTTLHours:      getEnvInt("SESSION_TTL_HOURS", 12),
+
+		OpenAPIValidateResponses: getEnv("OPENAPI_VALIDATE_RESPONSES", "false") == "true",
 	}
 }
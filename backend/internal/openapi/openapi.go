@@ -0,0 +1,253 @@
+// Package openapi builds OpenAPI 3.0 documents from Go types and checks
+// JSON values against the resulting schemas. It covers what this API
+// uses: structs with json tags, slices, maps, pointers and time.Time.
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"` // path → lower-case method
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`             // http|apiKey
+	Scheme      string `json:"scheme,omitempty"` // bearer
+	In          string `json:"in,omitempty"`     // header|cookie
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"` // status code or "default"
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path|query|header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is the subset of the OpenAPI 3.0 schema object used here.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// Generator turns Go types into schemas. Named struct types become
+// components referenced by $ref; see Name for how they are named.
+type Generator struct {
+	Schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{Schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Of returns the schema for the type of v; v is usually a zero value.
+func (g *Generator) Of(v any) *Schema { return g.schema(reflect.TypeOf(v)) }
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawType:
+		return &Schema{} // any JSON value
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		return nullable(s)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := &Schema{Type: "integer"}
+		if t.Bits() == 64 {
+			s.Format = "int64"
+		}
+		return s
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.uniqueName(t)
+			g.names[t] = name
+			g.Schemas[name] = nil // reserve it; types may refer to themselves
+			g.Schemas[name] = g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// object lists the JSON fields of a struct. Fields without omitempty are
+// always written, so they are required; slices, maps and pointers among
+// them may be null.
+func (g *Generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fs := g.schema(f.Type)
+			omit := strings.Contains(opts, "omitempty")
+			if omit {
+				// an omitted value is never written as null
+				fs = notNull(fs)
+			} else {
+				s.Required = append(s.Required, name)
+				switch f.Type.Kind() {
+				case reflect.Slice, reflect.Map:
+					if f.Type != rawType {
+						fs = nullable(fs)
+					}
+				}
+			}
+			s.Properties[name] = fs
+		}
+	}
+	walk(t)
+	return s
+}
+
+// Name is the component name for a named type: the type name with a
+// leading capital and without a DTO or Row suffix, so agentDTO is "Agent".
+func Name(t reflect.Type) string {
+	n := t.Name()
+	for _, suf := range []string{"DTO", "Row"} {
+		if len(n) > len(suf) {
+			n = strings.TrimSuffix(n, suf)
+		}
+	}
+	r := []rune(n)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func (g *Generator) uniqueName(t reflect.Type) string {
+	name := Name(t)
+	if _, taken := g.Schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	r := []rune(pkg)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r) + name
+}
+
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		// 3.0 ignores siblings of $ref, so wrap it
+		return &Schema{Nullable: true, AllOf: []*Schema{s}}
+	}
+	c := *s
+	c.Nullable = true
+	return &c
+}
+
+func notNull(s *Schema) *Schema {
+	if len(s.AllOf) == 1 && s.Nullable {
+		return s.AllOf[0]
+	}
+	c := *s
+	c.Nullable = false
+	return &c
+}
+
+// Resolve follows $ref to the component it names.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	if s == nil {
+		return &Schema{}
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Problem is one place where a value doesn't match its schema. Path uses
+// JSON names like "steps[1].url"; it is empty for the value itself.
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// Validate checks v, as decoded by a json.Decoder with UseNumber, against
+// s. Types are always checked. strict also reports nulls the schema
+// doesn't allow, missing required properties and properties the schema
+// doesn't list, which is what a response has to get right. Requests are
+// checked loosely: Go decodes null as a zero value and the handlers report
+// missing fields in their own words.
+func (d *Document) Validate(s *Schema, v any, strict bool) []Problem {
+	var out []Problem
+	d.check(s, v, "", strict, &out)
+	return out
+}
+
+// Decode parses JSON for Validate.
+func Decode(b []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	// a stream of values, like NDJSON, is not one JSON document
+	if dec.More() {
+		return nil, errors.New("data after the JSON value")
+	}
+	return v, nil
+}
+
+func (d *Document) check(s *Schema, v any, path string, strict bool, out *[]Problem) {
+	add := func(format string, args ...any) {
+		*out = append(*out, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.Ref != "" {
+		d.check(d.Resolve(s), v, path, strict, out)
+		return
+	}
+	if v == nil {
+		if strict && !s.Nullable && (s.Type != "" || len(s.AllOf) > 0) {
+			add("must not be null")
+		}
+		return
+	}
+	for _, sub := range s.AllOf {
+		d.check(sub, v, path, strict, out)
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]any)
+		if !ok {
+			add("must be an object")
+			return
+		}
+		for _, name := range sortedKeys(s.Properties) {
+			if pv, ok := m[name]; ok {
+				d.check(s.Properties[name], pv, join(path, name), strict, out)
+			} else if strict && slices.Contains(s.Required, name) {
+				*out = append(*out, Problem{Path: join(path, name), Message: "is required"})
+			}
+		}
+		for _, k := range sortedKeys(m) {
+			switch {
+			case s.AdditionalProperties != nil:
+				d.check(s.AdditionalProperties, m[k], join(path, k), strict, out)
+			case strict && s.Properties != nil && s.Properties[k] == nil:
+				*out = append(*out, Problem{Path: join(path, k), Message: "is not in the spec"})
+			}
+		}
+	case "array":
+		a, ok := v.([]any)
+		if !ok {
+			add("must be an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range a {
+				d.check(s.Items, item, fmt.Sprintf("%s[%d]", path, i), strict, out)
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			add("must be a string")
+		}
+	case "integer":
+		if n, ok := v.(json.Number); !ok {
+			add("must be an integer")
+		} else if _, err := n.Int64(); err != nil {
+			add("must be an integer")
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			add("must be a number")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			add("must be a boolean")
+		}
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+		add("must be one of %v", s.Enum)
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
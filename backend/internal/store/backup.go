@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrBackupUnsupported is returned by Backup on PostgreSQL, which has
+// pg_dump for this.
+var ErrBackupUnsupported = errors.New("online backup is only built in for SQLite; use pg_dump for PostgreSQL")
+
+// Backup writes a consistent copy of the SQLite database to path with
+// VACUUM INTO while the server keeps running. path must not exist.
+func (s *Store) Backup(ctx context.Context, path string) error {
+	if s.dialect != sqlite {
+		return ErrBackupUnsupported
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	_, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, path)
+	return err
+}
+
+// RestoreSQLite replaces the database at dbPath with snapshot, a file
+// written by Backup, and returns where the previous database was kept.
+// The server must be stopped. Snapshots from older versions are migrated
+// when the server next starts; ones from newer versions are refused.
+func RestoreSQLite(snapshot, dbPath string) (saved string, err error) {
+	if err := checkSnapshot(snapshot); err != nil {
+		return "", fmt.Errorf("%s: %w", snapshot, err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		saved = dbPath + ".before-restore"
+		if _, err := os.Stat(saved); err == nil {
+			return "", fmt.Errorf("%s already exists; move it away first", saved)
+		}
+		if err := releaseWAL(dbPath); err != nil {
+			return "", err
+		}
+		if err := os.Rename(dbPath, saved); err != nil {
+			return "", err
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return saved, err
+		}
+	}
+
+	tmp := dbPath + ".restoring"
+	if err := copyFile(snapshot, tmp); err != nil {
+		os.Remove(tmp)
+		return saved, err
+	}
+	return saved, os.Rename(tmp, dbPath)
+}
+
+// releaseWAL makes sure nothing else has dbPath open, then folds the WAL
+// into the file, so the saved copy is complete and no stale WAL is left
+// to be replayed into the restored database.
+func releaseWAL(dbPath string) error {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// a running server keeps a lock on a WAL database even when idle
+	if _, err := conn.ExecContext(ctx, `PRAGMA locking_mode=EXCLUSIVE`); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN EXCLUSIVE`); err != nil {
+		return fmt.Errorf("%s is in use; stop the server first: %w", dbPath, err)
+	}
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+// checkSnapshot opens snapshot read-only and checks it is intact and not
+// from a newer schema.
+func checkSnapshot(snapshot string) error {
+	if _, err := os.Stat(snapshot); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", "file:"+snapshot+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var res string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&res); err != nil {
+		return err
+	}
+	if res != "ok" {
+		return fmt.Errorf("integrity check: %s", res)
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version),0) FROM schema_version`).Scan(&version); err != nil {
+		return fmt.Errorf("not a status-probe-lite database: %w", err)
+	}
+	if version > SchemaVersion() {
+		return fmt.Errorf("schema version %d is newer than this binary's %d", version, SchemaVersion())
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// stmtCache keeps one prepared statement per query for the life of the
+// Store. Queries are constants or built from a few fixed fragments, so it
+// stays small.
+type stmtCache struct {
+	mu sync.Mutex
+	m  map[string]*sql.Stmt
+}
+
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, q string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if st, ok := c.m[q]; ok {
+		return st, nil
+	}
+	st, err := db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	c.m[q] = st
+	return st, nil
+}
+
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for q, st := range c.m {
+		_ = st.Close()
+		delete(c.m, q)
+	}
+}
+
+// stmt returns the prepared statement for q, bound to the batch's
+// transaction if there is one.
+func (s *Store) stmt(ctx context.Context, q string) (*sql.Stmt, error) {
+	st, err := s.stmts.get(ctx, s.db, s.dialect.rebind(q))
+	if err != nil {
+		return nil, err
+	}
+	if s.tx != nil {
+		return s.tx.StmtContext(ctx, st), nil
+	}
+	return st, nil
+}
+
+// WithBatch runs fn in one transaction, committing if it returns nil. The
+// Batch sees its own uncommitted writes.
+func (s *Store) WithBatch(ctx context.Context, fn func(Batch) error) error {
+	return s.inTx(ctx, func(s *Store) error { return fn(s) })
+}
+
+// WithTx runs fn in one transaction, committing if it returns nil.
+func (s *Store) WithTx(ctx context.Context, fn func(Storage) error) error {
+	return s.inTx(ctx, func(s *Store) error { return fn(s) })
+}
+
+// Savepoint runs fn inside a savepoint of the batch's transaction, rolled
+// back to if fn fails. Outside a transaction fn's statements commit on
+// their own, so there is nothing to undo.
+func (s *Store) Savepoint(ctx context.Context, fn func(Batch) error) error {
+	if s.tx == nil {
+		return fn(s)
+	}
+	if _, err := s.tx.ExecContext(ctx, `SAVEPOINT batch_row`); err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		// PostgreSQL refuses every statement after a failed one until this
+		if _, rerr := s.tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_row`); rerr != nil {
+			return errors.Join(err, rerr)
+		}
+		_, _ = s.tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_row`)
+		return err
+	}
+	_, err := s.tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_row`)
+	return err
+}
+
+// inTx runs fn with a Store bound to one transaction, committed if fn
+// returns nil. Inside a transaction already, fn just joins it.
+func (s *Store) inTx(ctx context.Context, fn func(*Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&Store{db: s.db, dialect: s.dialect, stmts: s.stmts, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,752 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// conformanceCase is one behaviour the handlers rely on, checked through
+// the Storage interface only.
+type conformanceCase struct {
+	name string
+	run  func(ctx context.Context, s Storage) error
+}
+
+// TestConformance runs every case against a new SQLite file and, when
+// TEST_POSTGRES_DSN is set, a PostgreSQL database. That database must be
+// empty and is left with the test data, so point it at a scratch database.
+func TestConformance(t *testing.T) {
+	backends := []struct{ driver, dsn string }{
+		{"sqlite", filepath.Join(t.TempDir(), "status.db")},
+		{"postgres", os.Getenv("TEST_POSTGRES_DSN")},
+	}
+	for _, b := range backends {
+		t.Run(b.driver, func(t *testing.T) {
+			if b.dsn == "" {
+				t.Skip("TEST_POSTGRES_DSN is not set")
+			}
+			st, err := OpenDriver(b.driver, b.dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+			ctx := context.Background()
+			// the cases create users and projects and don't clean up
+			if n, err := st.CountUsers(ctx); err != nil {
+				t.Fatal(err)
+			} else if n > 0 {
+				t.Fatal("conformance checks need an empty database")
+			}
+			for _, c := range conformanceCases {
+				t.Run(c.name, func(t *testing.T) {
+					if err := c.run(ctx, st); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// expect returns an error built from format unless ok.
+func expect(ok bool, format string, args ...any) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf(format, args...)
+}
+
+// firstErr returns the first non-nil error.
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scratchProject(ctx context.Context, s Storage, name string) (int64, error) {
+	return s.CreateProject(ctx, fmt.Sprintf("conformance-%s-%d", name, time.Now().UnixNano()))
+}
+
+// near compares times at the millisecond, the precision every backend keeps.
+func near(a, b time.Time) bool { return a.Sub(b).Abs() < time.Millisecond }
+
+var conformanceCases = []conformanceCase{
+	{"default project exists", func(ctx context.Context, s Storage) error {
+		p, err := s.GetProject(ctx, DefaultProjectID)
+		if err != nil {
+			return err
+		}
+		if err := expect(p != nil && p.Name == "default", "default project = %+v", p); err != nil {
+			return err
+		}
+		id, err := scratchProject(ctx, s, "ids")
+		return firstErr(err, expect(id > DefaultProjectID, "new project got id %d", id))
+	}},
+
+	{"targets round-trip every field", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "targets")
+		if err != nil {
+			return err
+		}
+		fresh := true
+		in := TargetRow{
+			ProjectID: pid, Name: "Checkout API", Type: TargetMultistep, URL: "https://example.com", TimeoutMs: 2500,
+			Key: "checkout", Group: "shop", Description: "d", Tags: []string{"prod", "eu"},
+			AgentSelector: map[string]string{"region": "eu"}, IntervalSec: 30, FreshConnection: &fresh,
+			GRPCService: "svc", GRPCTLS: true, GRPCTLSSkipVerify: true,
+			Method: "POST", Headers: map[string]string{"X-A": "1"}, Body: "{}",
+			Auth:       &Auth{Type: "bearer", Token: "${secret:t}"},
+			Assertions: &Assertions{StatusCodes: []string{"2xx"}, JSONPath: []JSONPathAssert{{Path: "$.ok", Equals: json.RawMessage(`true`)}}},
+			Steps:      []Step{{Name: "login", URL: "https://example.com/login", Extract: []Extract{{Var: "tok", JSONPath: "$.token"}}}},
+		}
+		id, err := s.InsertTarget(ctx, in)
+		if err != nil {
+			return err
+		}
+		got, err := s.GetTarget(ctx, pid, id)
+		if err != nil {
+			return err
+		}
+		if got == nil {
+			return errors.New("inserted target not found")
+		}
+		in.ID, in.CreatedAt = id, got.CreatedAt
+		a, _ := json.Marshal(in)
+		b, _ := json.Marshal(got)
+		if err := expect(string(a) == string(b), "round-trip:\n want %s\n got  %s", a, b); err != nil {
+			return err
+		}
+
+		other, err := s.GetTarget(ctx, DefaultProjectID, id)
+		if err := firstErr(err, expect(other == nil, "target visible from another project")); err != nil {
+			return err
+		}
+		byName, err := s.FindTargetByName(ctx, pid, "checkout api")
+		if err := firstErr(err, expect(byName != nil && byName.ID == id, "FindTargetByName is not case-insensitive")); err != nil {
+			return err
+		}
+		missing, err := s.FindTargetByName(ctx, pid, "nope")
+		if err := firstErr(err, expect(missing == nil, "FindTargetByName found a missing name")); err != nil {
+			return err
+		}
+
+		got.Name, got.Tags, got.FreshConnection, got.Assertions = "Renamed", nil, nil, nil
+		if err := s.UpdateTarget(ctx, *got); err != nil {
+			return err
+		}
+		upd, err := s.GetTarget(ctx, pid, id)
+		if err != nil {
+			return err
+		}
+		if err := expect(upd.Name == "Renamed" && upd.Tags == nil && upd.FreshConnection == nil && upd.Assertions == nil,
+			"update didn't clear fields: %+v", upd); err != nil {
+			return err
+		}
+		list, err := s.ListTargets(ctx, pid)
+		if err := firstErr(err, expect(len(list) == 1 && list[0].ID == id, "ListTargets = %d rows", len(list))); err != nil {
+			return err
+		}
+		// keys are unique within a project only
+		_, dupErr := s.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "dup", URL: "https://x", Key: "checkout"})
+		if err := expect(IsUniqueViolation(dupErr), "duplicate target key: %v", dupErr); err != nil {
+			return err
+		}
+		if _, err := s.InsertTarget(ctx, TargetRow{ProjectID: DefaultProjectID, Name: "dup", URL: "https://x", Key: "checkout"}); err != nil {
+			return fmt.Errorf("same key in another project: %w", err)
+		}
+		if err := s.DeleteTarget(ctx, pid, id); err != nil {
+			return err
+		}
+		gone, err := s.GetTarget(ctx, pid, id)
+		return firstErr(err, expect(gone == nil, "deleted target still found"))
+	}},
+
+	{"pause and resume", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "pause")
+		if err != nil {
+			return err
+		}
+		id, err := s.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "p", URL: "https://x", TimeoutMs: 1000})
+		if err != nil {
+			return err
+		}
+		t0 := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+		ok, err := s.PauseTarget(ctx, pid, id, t0)
+		if err := firstErr(err, expect(ok, "first pause reported false")); err != nil {
+			return err
+		}
+		ok, err = s.PauseTarget(ctx, pid, id, t0)
+		if err := firstErr(err, expect(!ok, "second pause reported true")); err != nil {
+			return err
+		}
+		t, err := s.GetTarget(ctx, pid, id)
+		if err := firstErr(err, expect(t != nil && t.Paused, "target not paused")); err != nil {
+			return err
+		}
+		ok, err = s.ResumeTarget(ctx, pid, id, t0.Add(10*time.Minute))
+		if err := firstErr(err, expect(ok, "resume reported false")); err != nil {
+			return err
+		}
+		ps, err := s.ListPausesOverlapping(ctx, pid, id, t0.Add(-time.Minute), t0.Add(time.Hour))
+		if err != nil {
+			return err
+		}
+		return expect(len(ps) == 1 && ps[0].EndedAt.Valid && near(ps[0].StartedAt, t0) && near(ps[0].EndedAt.Time, t0.Add(10*time.Minute)),
+			"pauses = %+v", ps)
+	}},
+
+	{"checks and aggregates", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "checks")
+		if err != nil {
+			return err
+		}
+		tid, err := s.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "c", URL: "https://x", TimeoutMs: 1000})
+		if err != nil {
+			return err
+		}
+		base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+		ms := func(n int) *int { return &n }
+		if _, _, ok, err := s.LastCheck(ctx, pid, tid); err != nil || ok {
+			return firstErr(err, errors.New("LastCheck found a check before any were stored"))
+		}
+		total, okN, err := s.CountChecksAgg(ctx, pid, tid, base, base.Add(time.Hour))
+		if err := firstErr(err, expect(total == 0 && okN == 0, "empty window counted %d/%d", okN, total)); err != nil {
+			return err
+		}
+		for i, c := range []struct {
+			ok      bool
+			latency int
+			reason  string
+			tm      *Timings
+		}{
+			{true, 100, "", &Timings{DNSMs: ms(10), TTFBMs: ms(40)}},
+			{true, 300, "", &Timings{DNSMs: ms(30)}},
+			{false, 0, "timeout", nil},
+			{false, 0, "timeout", nil},
+			{false, 0, "dns_not_found", nil},
+		} {
+			status := 200
+			if !c.ok {
+				status = 0
+			}
+			if err := s.InsertCheck(ctx, pid, tid, 7, base.Add(time.Duration(i)*time.Minute), status, c.ok, c.latency, c.reason, c.tm); err != nil {
+				return err
+			}
+		}
+		from, to := base, base.Add(time.Hour)
+		total, okN, err = s.CountChecksAgg(ctx, pid, tid, from, to)
+		if err := firstErr(err, expect(total == 5 && okN == 2, "counted %d/%d, want 2/5", okN, total)); err != nil {
+			return err
+		}
+		avg, err := s.AvgLatencyOK(ctx, pid, tid, from, to)
+		if err := firstErr(err, expect(avg.Valid && avg.Float64 == 200, "avg latency = %+v", avg)); err != nil {
+			return err
+		}
+		ph, err := s.AvgPhasesOK(ctx, pid, tid, from, to)
+		if err := firstErr(err, expect(ph.DNS.Valid && ph.DNS.Float64 == 20 && ph.TTFB.Float64 == 40 && !ph.TLS.Valid,
+			"phase averages = %+v", ph)); err != nil {
+			return err
+		}
+		rs, err := s.FailuresByReason(ctx, pid, tid, from, to)
+		if err := firstErr(err, expect(len(rs) == 2 && rs[0].Reason == "timeout" && rs[0].Count == 2 && rs[0].Category == CategoryTimeout,
+			"failures by reason = %+v", rs)); err != nil {
+			return err
+		}
+		// the window is half-open
+		total, _, err = s.CountChecksAgg(ctx, pid, tid, base.Add(time.Minute), base.Add(4*time.Minute))
+		if err := firstErr(err, expect(total == 3, "[1m,4m) counted %d, want 3", total)); err != nil {
+			return err
+		}
+		recent, err := s.GetRecentChecks(ctx, pid, tid, 2)
+		if err := firstErr(err, expect(len(recent) == 2 && !recent[0].OK && recent[0].Error == "dns_not_found" && recent[1].Error == "timeout",
+			"recent checks = %+v", recent)); err != nil {
+			return err
+		}
+		ts, agent, ok, err := s.LastCheck(ctx, pid, tid)
+		return firstErr(err, expect(ok && near(ts, base.Add(4*time.Minute)) && agent.Valid && agent.Int64 == 7,
+			"LastCheck = %v %v %v", ts, agent, ok))
+	}},
+
+	{"outages", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "outages")
+		if err != nil {
+			return err
+		}
+		tid, err := s.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "o", URL: "https://x", TimeoutMs: 1000})
+		if err != nil {
+			return err
+		}
+		base := time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Second)
+		if o, err := s.GetOpenOutage(ctx, pid, tid); err != nil || o != nil {
+			return firstErr(err, errors.New("open outage before any was opened"))
+		}
+		if err := s.OpenOutage(ctx, pid, tid, base, "timeout"); err != nil {
+			return err
+		}
+		o, err := s.GetOpenOutage(ctx, pid, tid)
+		if err := firstErr(err, expect(o != nil && o.Reason == "timeout" && near(o.StartedAt, base), "open outage = %+v", o)); err != nil {
+			return err
+		}
+		if err := s.CloseOutage(ctx, o.ID, base.Add(time.Hour)); err != nil {
+			return err
+		}
+		if err := s.OpenOutage(ctx, pid, tid, base.Add(2*time.Hour), "non_2xx"); err != nil {
+			return err
+		}
+		all, err := s.ListOutages(ctx, pid, OutageFilter{})
+		if err := firstErr(err, expect(len(all) == 2 && all[0].Reason == "non_2xx" && !all[0].EndedAt.Valid, "ListOutages = %+v", all)); err != nil {
+			return err
+		}
+		open, err := s.ListOutages(ctx, pid, OutageFilter{TargetID: tid, OpenOnly: true})
+		if err := firstErr(err, expect(len(open) == 1, "open outages = %d", len(open))); err != nil {
+			return err
+		}
+		win, err := s.ListOutages(ctx, pid, OutageFilter{From: base.Add(30 * time.Minute), To: base.Add(90 * time.Minute)})
+		if err := firstErr(err, expect(len(win) == 1 && win[0].Reason == "timeout", "window = %+v", win)); err != nil {
+			return err
+		}
+		limited, err := s.ListOutages(ctx, pid, OutageFilter{Limit: 1})
+		if err := firstErr(err, expect(len(limited) == 1, "limit 1 returned %d", len(limited))); err != nil {
+			return err
+		}
+		ov, err := s.ListOutagesOverlapping(ctx, pid, tid, base.Add(90*time.Minute), base.Add(3*time.Hour))
+		return firstErr(err, expect(len(ov) == 1 && ov[0].Reason == "non_2xx", "overlapping = %+v", ov))
+	}},
+
+	{"agents and keys", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "agents")
+		if err != nil {
+			return err
+		}
+		id, err := s.CreateAgent(ctx, pid, "edge-1", "key-one-0123456789")
+		if err != nil {
+			return err
+		}
+		a, err := s.FindAgentByKey(ctx, "key-one-0123456789")
+		if err := firstErr(err, expect(a != nil && a.ID == id && a.KeyPrefix == KeyPrefix("key-one-0123456789"), "find by key = %+v", a)); err != nil {
+			return err
+		}
+		if a, err := s.FindAgentByKey(ctx, "key-one-wrong"); err != nil || a != nil {
+			return firstErr(err, errors.New("wrong key with the same prefix matched"))
+		}
+		if err := s.UpdateAgent(ctx, pid, id, "edge-eu", map[string]string{"region": "eu"}); err != nil {
+			return err
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		if err := s.RecordHeartbeat(ctx, id, Heartbeat{Version: "1.2", Hostname: "h", UptimeSec: 99, TargetCount: 3, SpoolDepth: 1}, now); err != nil {
+			return err
+		}
+		a, err = s.GetAgent(ctx, pid, id)
+		if err := firstErr(err, expect(a != nil && a.Name == "edge-eu" && a.Labels["region"] == "eu" && a.Version == "1.2" &&
+			a.UptimeSec == 99 && a.LastSeenAt.Valid && near(a.LastSeenAt.Time, now), "agent = %+v", a)); err != nil {
+			return err
+		}
+		if err := s.TouchAgent(ctx, id, now.Add(time.Minute)); err != nil {
+			return err
+		}
+
+		// the old key keeps working until the overlap ends
+		if err := s.RotateAgentKey(ctx, pid, id, "key-two-0123456789", now.Add(time.Hour)); err != nil {
+			return err
+		}
+		for _, k := range []string{"key-one-0123456789", "key-two-0123456789"} {
+			if a, err := s.FindAgentByKey(ctx, k); err != nil || a == nil {
+				return firstErr(err, fmt.Errorf("%s rejected during the overlap", k))
+			}
+		}
+		if err := s.RotateAgentKey(ctx, pid, id, "key-three-0123456789", now.Add(-time.Second)); err != nil {
+			return err
+		}
+		if a, err := s.FindAgentByKey(ctx, "key-two-0123456789"); err != nil || a != nil {
+			return firstErr(err, errors.New("rotated key without overlap still accepted"))
+		}
+		if err := s.RevokeAgent(ctx, pid, id, now); err != nil {
+			return err
+		}
+		if a, err := s.FindAgentByKey(ctx, "key-three-0123456789"); err != nil || a != nil {
+			return firstErr(err, errors.New("revoked agent's key accepted"))
+		}
+		list, err := s.ListAgents(ctx, pid)
+		if err := firstErr(err, expect(len(list) == 1 && list[0].RevokedAt.Valid, "ListAgents = %+v", list)); err != nil {
+			return err
+		}
+		all, err := s.ListAllAgents(ctx)
+		if err := firstErr(err, expect(len(all) >= 1, "ListAllAgents is empty")); err != nil {
+			return err
+		}
+
+		if err := s.OpenAgentOutage(ctx, id, now); err != nil {
+			return err
+		}
+		ao, err := s.GetOpenAgentOutage(ctx, id)
+		if err := firstErr(err, expect(ao != nil && near(ao.StartedAt, now), "agent outage = %+v", ao)); err != nil {
+			return err
+		}
+		if err := s.CloseAgentOutage(ctx, ao.ID, now.Add(time.Minute)); err != nil {
+			return err
+		}
+		ao, err = s.GetOpenAgentOutage(ctx, id)
+		if err := firstErr(err, expect(ao == nil, "closed agent outage still open")); err != nil {
+			return err
+		}
+		aos, err := s.ListAgentOutagesOverlapping(ctx, id, now.Add(30*time.Second), now.Add(time.Hour))
+		if err := firstErr(err, expect(len(aos) == 1 && aos[0].EndedAt.Valid, "overlapping agent outages = %+v", aos)); err != nil {
+			return err
+		}
+		aos, err = s.ListAgentOutagesOverlapping(ctx, id, now.Add(2*time.Minute), now.Add(time.Hour))
+		return firstErr(err, expect(len(aos) == 0, "agent outages after the window = %+v", aos))
+	}},
+
+	{"users, tokens and membership", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "users")
+		if err != nil {
+			return err
+		}
+		uid, err := s.CreateUser(ctx, "casey", "correct horse", RoleEditor)
+		if err != nil {
+			return err
+		}
+		if _, err := s.CreateUser(ctx, "casey", "x", RoleViewer); !IsUniqueViolation(err) {
+			return fmt.Errorf("duplicate username: %v", err)
+		}
+		u, err := s.AuthenticateUser(ctx, "casey", "correct horse")
+		if err := firstErr(err, expect(u != nil && u.ID == uid && u.Role == RoleEditor, "authenticate = %+v", u)); err != nil {
+			return err
+		}
+		if u, err := s.AuthenticateUser(ctx, "casey", "wrong"); err != nil || u != nil {
+			return firstErr(err, errors.New("wrong password accepted"))
+		}
+
+		if err := s.AddProjectMember(ctx, pid, uid); err != nil {
+			return err
+		}
+		if err := s.AddProjectMember(ctx, pid, uid); err != nil {
+			return fmt.Errorf("adding a member twice: %w", err)
+		}
+		members, err := s.ListProjectMembers(ctx, pid)
+		if err := firstErr(err, expect(len(members) == 1 && members[0].ID == uid, "members = %+v", members)); err != nil {
+			return err
+		}
+		ps, err := s.ListUserProjects(ctx, uid)
+		if err := firstErr(err, expect(len(ps) == 1 && ps[0].ID == pid, "user projects = %+v", ps)); err != nil {
+			return err
+		}
+
+		exp := time.Now().UTC().Add(time.Hour)
+		tid, err := s.CreateToken(ctx, uid, pid, "ci", "tok-abcdefghijklmnop", &exp)
+		if err != nil {
+			return err
+		}
+		u, tok, err := s.FindUserByToken(ctx, "tok-abcdefghijklmnop")
+		if err := firstErr(err, expect(u != nil && tok != nil && tok.ID == tid && tok.ProjectID == pid && tok.ExpiresAt.Valid,
+			"find by token = %+v %+v", u, tok)); err != nil {
+			return err
+		}
+		past := time.Now().UTC().Add(-time.Minute)
+		if _, err := s.CreateToken(ctx, uid, pid, "old", "old-abcdefghijklmnop", &past); err != nil {
+			return err
+		}
+		if u, _, err := s.FindUserByToken(ctx, "old-abcdefghijklmnop"); err != nil || u != nil {
+			return firstErr(err, errors.New("expired token accepted"))
+		}
+		// tokens stop working when their user leaves the project
+		if err := s.RemoveProjectMember(ctx, pid, uid); err != nil {
+			return err
+		}
+		if u, _, err := s.FindUserByToken(ctx, "tok-abcdefghijklmnop"); err != nil || u != nil {
+			return firstErr(err, errors.New("token of a removed member accepted"))
+		}
+		if err := s.AddProjectMember(ctx, pid, uid); err != nil {
+			return err
+		}
+		if err := s.SetTokenProject(ctx, tid, DefaultProjectID); err != nil {
+			return err
+		}
+		if u, _, err := s.FindUserByToken(ctx, "tok-abcdefghijklmnop"); err != nil || u != nil {
+			return firstErr(err, errors.New("token moved to a project its user can't use was accepted"))
+		}
+
+		if err := s.UpdateUser(ctx, uid, RoleViewer, true, ""); err != nil {
+			return err
+		}
+		u, err = s.GetUser(ctx, uid)
+		if err := firstErr(err, expect(u != nil && u.Role == RoleViewer && u.DisabledAt.Valid, "disabled user = %+v", u)); err != nil {
+			return err
+		}
+		toks, err := s.ListTokens(ctx, uid)
+		if err := firstErr(err, expect(len(toks) == 0, "disabling kept %d tokens", len(toks))); err != nil {
+			return err
+		}
+		if err := s.UpdateUser(ctx, uid, RoleViewer, false, ""); err != nil {
+			return err
+		}
+		// a new password ends sessions but keeps API tokens
+		if _, err := s.CreateSession(ctx, uid, pid, "ses-abcdefghijklmnop", time.Now().Add(time.Hour)); err != nil {
+			return err
+		}
+		apiID, err := s.CreateToken(ctx, uid, pid, "ci", "api-abcdefghijklmnop", nil)
+		if err != nil {
+			return err
+		}
+		if err := s.UpdateUser(ctx, uid, RoleEditor, false, "battery staple"); err != nil {
+			return err
+		}
+		if u, err := s.AuthenticateUser(ctx, "casey", "battery staple"); err != nil || u == nil || u.Role != RoleEditor {
+			return firstErr(err, fmt.Errorf("re-enabled user with new password = %+v", u))
+		}
+		if u, _, err := s.FindUserByToken(ctx, "ses-abcdefghijklmnop"); err != nil || u != nil {
+			return firstErr(err, errors.New("session survived a password change"))
+		}
+		if u, _, err := s.FindUserByToken(ctx, "api-abcdefghijklmnop"); err != nil || u == nil {
+			return firstErr(err, errors.New("API token revoked by a password change"))
+		}
+		if _, err := s.DeleteToken(ctx, uid, apiID); err != nil {
+			return err
+		}
+
+		tid, err = s.CreateToken(ctx, uid, pid, "forever", "inf-abcdefghijklmnop", nil)
+		if err != nil {
+			return err
+		}
+		if ok, err := s.DeleteToken(ctx, uid+1000, tid); err != nil || ok {
+			return firstErr(err, errors.New("deleted another user's token"))
+		}
+		if ok, err := s.DeleteToken(ctx, uid, tid); err != nil || !ok {
+			return firstErr(err, errors.New("DeleteToken reported false"))
+		}
+		if err := s.DeleteUser(ctx, uid); err != nil {
+			return err
+		}
+		u, err = s.GetUser(ctx, uid)
+		if err := firstErr(err, expect(u == nil, "deleted user still found")); err != nil {
+			return err
+		}
+		n, err := s.CountUsers(ctx)
+		if err := firstErr(err, expect(n == 0, "CountUsers = %d", n)); err != nil {
+			return err
+		}
+		users, err := s.ListUsers(ctx)
+		return firstErr(err, expect(len(users) == 0, "ListUsers = %d", len(users)))
+	}},
+
+	{"projects", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "projects")
+		if err != nil {
+			return err
+		}
+		if err := s.RenameProject(ctx, pid, "conformance renamed"); err != nil {
+			return err
+		}
+		p, err := s.GetProject(ctx, pid)
+		if err := firstErr(err, expect(p != nil && p.Name == "conformance renamed", "renamed project = %+v", p)); err != nil {
+			return err
+		}
+		if _, err := s.CreateProject(ctx, "conformance renamed"); !IsUniqueViolation(err) {
+			return fmt.Errorf("duplicate project name: %v", err)
+		}
+		tid, err := s.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "t", URL: "https://x", TimeoutMs: 1000})
+		if err != nil {
+			return err
+		}
+		inUse, err := s.ProjectInUse(ctx, pid)
+		if err := firstErr(err, expect(inUse, "project with a target not in use")); err != nil {
+			return err
+		}
+		if err := s.DeleteTarget(ctx, pid, tid); err != nil {
+			return err
+		}
+		if inUse, err := s.ProjectInUse(ctx, pid); err != nil || inUse {
+			return firstErr(err, errors.New("empty project in use"))
+		}
+		// config objects go with the project
+		if err := s.InsertGroup(ctx, GroupRow{ProjectID: pid, Key: "g", Name: "G"}); err != nil {
+			return err
+		}
+		if err := s.InsertAlertRoute(ctx, AlertRouteRow{ProjectID: pid, Key: "r", Name: "R", WebhookURL: "https://hooks/1"}); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if err := s.InsertMaintenanceWindow(ctx, MaintenanceWindowRow{ProjectID: pid, Key: "w", Name: "W", StartsAt: now, EndsAt: now.Add(time.Hour)}); err != nil {
+			return err
+		}
+		if err := s.DeleteProject(ctx, pid); err != nil {
+			return err
+		}
+		p, err = s.GetProject(ctx, pid)
+		if err := firstErr(err, expect(p == nil, "deleted project still found")); err != nil {
+			return err
+		}
+		gs, err := s.ListGroups(ctx, pid)
+		if err := firstErr(err, expect(len(gs) == 0, "deleted project's groups = %+v", gs)); err != nil {
+			return err
+		}
+		rs, err := s.ListAlertRoutes(ctx, pid)
+		if err := firstErr(err, expect(len(rs) == 0, "deleted project's alert routes = %+v", rs)); err != nil {
+			return err
+		}
+		ws, err := s.ListMaintenanceWindows(ctx, pid)
+		if err := firstErr(err, expect(len(ws) == 0, "deleted project's windows = %+v", ws)); err != nil {
+			return err
+		}
+		all, err := s.ListProjects(ctx)
+		return firstErr(err, expect(len(all) > 0 && all[0].ID == DefaultProjectID, "ListProjects = %+v", all))
+	}},
+
+	{"groups, alert routes and maintenance windows", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "config")
+		if err != nil {
+			return err
+		}
+		if err := s.InsertGroup(ctx, GroupRow{ProjectID: pid, Key: "shop", Name: "Shop"}); err != nil {
+			return err
+		}
+		if err := s.InsertGroup(ctx, GroupRow{ProjectID: pid, Key: "shop", Name: "Again"}); !IsUniqueViolation(err) {
+			return fmt.Errorf("duplicate group key: %v", err)
+		}
+		if err := s.UpdateGroup(ctx, GroupRow{ProjectID: pid, Key: "shop", Name: "Shop", Description: "d"}); err != nil {
+			return err
+		}
+		tid, err := s.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "t", URL: "https://x", TimeoutMs: 1000, Group: "shop"})
+		if err != nil {
+			return err
+		}
+		gs, err := s.ListGroups(ctx, pid)
+		if err := firstErr(err, expect(len(gs) == 1 && gs[0].Description == "d", "groups = %+v", gs)); err != nil {
+			return err
+		}
+		if err := s.DeleteGroup(ctx, pid, "shop"); err != nil {
+			return err
+		}
+		ok, err := s.GroupExists(ctx, pid, "shop")
+		if err := firstErr(err, expect(!ok, "deleted group exists")); err != nil {
+			return err
+		}
+		t, err := s.GetTarget(ctx, pid, tid)
+		if err := firstErr(err, expect(t != nil && t.Group == "", "target kept deleted group %q", t.Group)); err != nil {
+			return err
+		}
+
+		sel := Selector{Tags: []string{"prod"}}
+		if err := s.InsertAlertRoute(ctx, AlertRouteRow{ProjectID: pid, Key: "ops", Name: "Ops", WebhookURL: "https://hooks/1", Match: sel}); err != nil {
+			return err
+		}
+		if err := s.UpdateAlertRoute(ctx, AlertRouteRow{ProjectID: pid, Key: "ops", Name: "Ops", WebhookURL: "https://hooks/2"}); err != nil {
+			return err
+		}
+		rs, err := s.ListAlertRoutes(ctx, pid)
+		if err := firstErr(err, expect(len(rs) == 1 && rs[0].WebhookURL == "https://hooks/2" && rs[0].Match.Empty(), "routes = %+v", rs)); err != nil {
+			return err
+		}
+		if err := s.DeleteAlertRoute(ctx, pid, "ops"); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		w := MaintenanceWindowRow{ProjectID: pid, Key: "deploy", Name: "Deploy", StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour), Match: sel}
+		if err := s.InsertMaintenanceWindow(ctx, w); err != nil {
+			return err
+		}
+		active, err := s.ActiveMaintenanceWindows(ctx, pid, now)
+		if err := firstErr(err, expect(len(active) == 1 && active[0].Match.Tags[0] == "prod" && active[0].StartsAt.Equal(w.StartsAt),
+			"active windows = %+v", active)); err != nil {
+			return err
+		}
+		w.StartsAt, w.EndsAt = now.Add(time.Hour), now.Add(2*time.Hour)
+		if err := s.UpdateMaintenanceWindow(ctx, w); err != nil {
+			return err
+		}
+		if active, err := s.ActiveMaintenanceWindows(ctx, pid, now); err != nil || len(active) != 0 {
+			return firstErr(err, errors.New("future window is active"))
+		}
+		ws, err := s.ListMaintenanceWindows(ctx, pid)
+		if err := firstErr(err, expect(len(ws) == 1, "windows = %d", len(ws))); err != nil {
+			return err
+		}
+		if err := s.DeleteMaintenanceWindow(ctx, pid, "deploy"); err != nil {
+			return err
+		}
+		ws, err = s.ListMaintenanceWindows(ctx, pid)
+		return firstErr(err, expect(len(ws) == 0, "deleted window still listed"))
+	}},
+
+	{"transactions commit all or nothing", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "tx")
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		failed := errors.New("stop")
+		err = s.WithTx(ctx, func(tx Storage) error {
+			if err := tx.InsertGroup(ctx, GroupRow{ProjectID: pid, Key: "g", Name: "G"}); err != nil {
+				return err
+			}
+			id, err := tx.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "t", URL: "https://x", TimeoutMs: 1000})
+			if err != nil {
+				return err
+			}
+			// methods with their own transaction join this one
+			if _, err := tx.PauseTarget(ctx, pid, id, now); err != nil {
+				return err
+			}
+			return failed
+		})
+		if err := expect(errors.Is(err, failed), "WithTx = %v, want the callback's error", err); err != nil {
+			return err
+		}
+		gs, err := s.ListGroups(ctx, pid)
+		if err := firstErr(err, expect(len(gs) == 0, "rolled back groups = %+v", gs)); err != nil {
+			return err
+		}
+		ts, err := s.ListTargets(ctx, pid)
+		if err := firstErr(err, expect(len(ts) == 0, "rolled back targets = %+v", ts)); err != nil {
+			return err
+		}
+
+		if err := s.WithTx(ctx, func(tx Storage) error {
+			return tx.InsertGroup(ctx, GroupRow{ProjectID: pid, Key: "g", Name: "G"})
+		}); err != nil {
+			return err
+		}
+		gs, err = s.ListGroups(ctx, pid)
+		return firstErr(err, expect(len(gs) == 1, "committed groups = %+v", gs))
+	}},
+
+	{"check logs", func(ctx context.Context, s Storage) error {
+		pid, err := scratchProject(ctx, s, "logs")
+		if err != nil {
+			return err
+		}
+		tid, err := s.InsertTarget(ctx, TargetRow{ProjectID: pid, Name: "l", URL: "https://x", TimeoutMs: 1000})
+		if err != nil {
+			return err
+		}
+		base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+		cid := int64(42)
+		for i := 0; i < 5; i++ {
+			var c *int64
+			if i == 0 {
+				c = &cid
+			}
+			if err := s.InsertCheckLog(ctx, pid, tid, c, base.Add(time.Duration(i)*time.Second), "info", fmt.Sprintf("line %d", i)); err != nil {
+				return err
+			}
+		}
+		logs, err := s.ListLogs(ctx, pid, tid, 2, nil)
+		if err := firstErr(err, expect(len(logs) == 2 && logs[0].Line == "line 4", "newest logs = %+v", logs)); err != nil {
+			return err
+		}
+		before := base.Add(2 * time.Second)
+		logs, err = s.ListLogs(ctx, pid, tid, 10, &before)
+		return firstErr(err, expect(len(logs) == 2 && logs[1].Line == "line 0" && logs[1].CheckID.Valid && logs[1].CheckID.Int64 == 42,
+			"logs before = %+v", logs))
+	}},
+}
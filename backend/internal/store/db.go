@@ -3,109 +3,203 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/niranjini-kathiravan/status-probe-lite/backend/pkg/client"
 	_ "modernc.org/sqlite"
 )
 
-type Store struct{ DB *sql.DB }
+// Store implements Storage on SQLite or PostgreSQL. Queries are written
+// once, with ? placeholders, in SQL both databases accept; the dialect
+// only changes placeholders and the schema.
+type Store struct {
+	db      *sql.DB
+	dialect dialect
+	stmts   *stmtCache
+	// set on the Store handed to a WithBatch callback
+	tx *sql.Tx
+}
+
+// Open opens the SQLite database at path, creating it if needed, and
+// applies any pending migrations.
+func Open(path string) (*Store, error) { return OpenDriver("sqlite", path) }
 
-func Open(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path)
+// OpenDriver opens the database named by driver, "sqlite" or "postgres",
+// and applies any pending migrations; dsn is a file path or a PostgreSQL
+// connection string.
+func OpenDriver(driver, dsn string) (*Store, error) {
+	s, err := Connect(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
-	s := &Store{DB: db}
-	if err := s.migrate(); err != nil {
-		_ = db.Close()
+	if _, err := s.Migrate(context.Background()); err != nil {
+		_ = s.Close()
 		return nil, err
 	}
 	return s, nil
 }
-func (s *Store) Close() error { return s.DB.Close() }
-
-func (s *Store) migrate() error {
-	stmts := []string{
-		`CREATE TABLE IF NOT EXISTS targets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			url TEXT NOT NULL,
-			timeout_ms INTEGER DEFAULT 4000,
-			created_at TIMESTAMP NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS checks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			target_id INTEGER NOT NULL,
-			ts TIMESTAMP NOT NULL,
-			status_code INTEGER NOT NULL,
-			ok INTEGER NOT NULL,
-			latency_ms INTEGER NOT NULL,
-			error TEXT NOT NULL DEFAULT ''
-		);`,
-		`CREATE TABLE IF NOT EXISTS outages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			target_id INTEGER NOT NULL,
-			started_at TIMESTAMP NOT NULL,
-			ended_at TIMESTAMP,
-			reason TEXT NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS agents (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			api_key TEXT NOT NULL UNIQUE,
-			created_at TIMESTAMP NOT NULL
-		);`,
-		// NEW: logs table
-		`CREATE TABLE IF NOT EXISTS logs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			target_id INTEGER NOT NULL,
-			check_id INTEGER,
-			ts TIMESTAMP NOT NULL,
-			level TEXT NOT NULL,
-			line TEXT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_logs_target_ts ON logs(target_id, ts DESC);`,
-	}
-	for _, q := range stmts {
-		if _, err := s.DB.Exec(q); err != nil {
-			return err
+
+// Connect opens the database like OpenDriver but leaves the schema alone,
+// for callers that check or apply migrations themselves.
+func Connect(driver, dsn string) (*Store, error) {
+	var d dialect
+	switch driver {
+	case "sqlite", "":
+		driver, d = "sqlite", sqlite
+	case "postgres":
+		d = postgres
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+	if d == sqlite && !strings.Contains(dsn, "?") {
+		dsn += sqliteParams
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	// prepared statements live on a connection; keep enough of them open
+	// that concurrent requests don't keep reconnecting and re-preparing
+	db.SetMaxIdleConns(16)
+	return &Store{db: db, dialect: d, stmts: &stmtCache{m: map[string]*sql.Stmt{}}}, nil
+}
+
+// sqliteParams is added to SQLite paths without parameters of their own.
+// WAL lets the dashboard read while agents write; writers wait up to 5s
+// for each other instead of failing with SQLITE_BUSY, and take the write
+// lock when their transaction starts, since a deferred transaction that
+// reads first can't wait for it later.
+const sqliteParams = "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+
+func (s *Store) Close() error {
+	s.stmts.close()
+	return s.db.Close()
+}
+
+// exec, query and queryRow run q as a prepared statement, inside the
+// batch's transaction if there is one.
+func (s *Store) exec(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	st, err := s.stmt(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return st.ExecContext(ctx, args...)
+}
+
+func (s *Store) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	st, err := s.stmt(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return st.QueryContext(ctx, args...)
+}
+
+// queryRow falls back to an unprepared query when preparing fails, so the
+// error surfaces from Scan like any other.
+func (s *Store) queryRow(ctx context.Context, q string, args ...any) *sql.Row {
+	st, err := s.stmt(ctx, q)
+	if err != nil {
+		if s.tx != nil {
+			return s.tx.QueryRowContext(ctx, s.dialect.rebind(q), args...)
 		}
+		return s.db.QueryRowContext(ctx, s.dialect.rebind(q), args...)
 	}
-	return nil
+	return st.QueryRowContext(ctx, args...)
+}
+
+// insert runs an INSERT and returns the new row's id. Both databases
+// support RETURNING; database/sql's LastInsertId only works on SQLite.
+func (s *Store) insert(ctx context.Context, q string, args ...any) (int64, error) {
+	var id int64
+	err := s.queryRow(ctx, q+` RETURNING id`, args...).Scan(&id)
+	return id, err
 }
 
 // targets
 
-type TargetRow struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	URL       string    `json:"url"`
-	TimeoutMs int       `json:"timeout_ms"`
-	CreatedAt time.Time `json:"created_at"`
+// Target definitions are the API's wire types, shared with pkg/client so
+// the handlers and SDK users decode the same structs.
+const (
+	TargetHTTP      = client.TargetHTTP
+	TargetGRPC      = client.TargetGRPC
+	TargetMultistep = client.TargetMultistep
+)
+
+type (
+	TargetRow       = client.Target
+	Step            = client.Step
+	Extract         = client.Extract
+	Auth            = client.Auth
+	Assertions      = client.Assertions
+	JSONPathAssert  = client.JSONPathAssert
+	HeaderAssertion = client.HeaderAssertion
+)
+
+const targetCols = `id,project_id,name,type,url,timeout_ms,created_at,grpc_service,grpc_tls,grpc_tls_skip_verify,assertions,
+	method,headers,body,auth,steps,interval_sec,fresh_connection,description,tags,paused,key,group_key,agent_selector`
+
+func (s *Store) InsertTarget(ctx context.Context, t TargetRow) (int64, error) {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now().UTC()
+	}
+	if t.Type == "" {
+		t.Type = TargetHTTP
+	}
+	return s.insert(ctx,
+		`INSERT INTO targets(project_id,name,type,url,timeout_ms,created_at,grpc_service,grpc_tls,grpc_tls_skip_verify,assertions,
+		   method,headers,body,auth,steps,interval_sec,fresh_connection,description,tags,paused,key,group_key,agent_selector)
+		 VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		t.ProjectID, t.Name, t.Type, t.URL, t.TimeoutMs, t.CreatedAt, t.GRPCService, btoi(t.GRPCTLS), btoi(t.GRPCTLSSkipVerify),
+		jsonText(t.Assertions), t.Method, jsonText(t.Headers), t.Body, jsonText(t.Auth), jsonText(t.Steps), t.IntervalSec, nullBtoi(t.FreshConnection),
+		t.Description, jsonText(t.Tags), btoi(t.Paused), t.Key, t.Group, jsonText(t.AgentSelector))
 }
 
-func (s *Store) InsertTarget(ctx context.Context, name, url string, timeoutMs int) (int64, error) {
-	now := time.Now().UTC()
-	res, err := s.DB.ExecContext(ctx,
-		`INSERT INTO targets(name,url,timeout_ms,created_at) VALUES(?,?,?,?)`,
-		name, url, timeoutMs, now)
+// UpdateTarget replaces the definition of t.ID within t.ProjectID. ID,
+// project, creation time and paused state are left alone.
+func (s *Store) UpdateTarget(ctx context.Context, t TargetRow) error {
+	_, err := s.exec(ctx,
+		`UPDATE targets SET name=?,type=?,url=?,timeout_ms=?,grpc_service=?,grpc_tls=?,grpc_tls_skip_verify=?,assertions=?,
+		   method=?,headers=?,body=?,auth=?,steps=?,interval_sec=?,fresh_connection=?,description=?,tags=?,
+		   key=?,group_key=?,agent_selector=?
+		 WHERE id=? AND project_id=?`,
+		t.Name, t.Type, t.URL, t.TimeoutMs, t.GRPCService, btoi(t.GRPCTLS), btoi(t.GRPCTLSSkipVerify),
+		jsonText(t.Assertions), t.Method, jsonText(t.Headers), t.Body, jsonText(t.Auth), jsonText(t.Steps), t.IntervalSec, nullBtoi(t.FreshConnection),
+		t.Description, jsonText(t.Tags), t.Key, t.Group, jsonText(t.AgentSelector), t.ID, t.ProjectID)
+	return err
+}
+
+// FindTargetByName matches case-insensitively; nil if there is none.
+func (s *Store) FindTargetByName(ctx context.Context, projectID int64, name string) (*TargetRow, error) {
+	t, err := scanTarget(s.queryRow(ctx,
+		`SELECT `+targetCols+` FROM targets WHERE project_id=? AND LOWER(name)=LOWER(?) LIMIT 1`, projectID, name))
 	if err != nil {
-		return 0, err
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
 	}
-	return res.LastInsertId()
+	return &t, nil
 }
 
-func (s *Store) ListTargets(ctx context.Context) ([]TargetRow, error) {
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT id,name,url,timeout_ms,created_at FROM targets ORDER BY id ASC`)
+func (s *Store) ListTargets(ctx context.Context, projectID int64) ([]TargetRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT `+targetCols+` FROM targets WHERE project_id=? ORDER BY id ASC`, projectID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var out []TargetRow
 	for rows.Next() {
-		var t TargetRow
-		if err := rows.Scan(&t.ID, &t.Name, &t.URL, &t.TimeoutMs, &t.CreatedAt); err != nil {
+		t, err := scanTarget(rows)
+		if err != nil {
 			return nil, err
 		}
 		out = append(out, t)
@@ -113,12 +207,144 @@ func (s *Store) ListTargets(ctx context.Context) ([]TargetRow, error) {
 	return out, rows.Err()
 }
 
-func (s *Store) DeleteTarget(ctx context.Context, id int64) error {
-	_, _ = s.DB.ExecContext(ctx, `DELETE FROM checks  WHERE target_id=?`, id)
-	_, _ = s.DB.ExecContext(ctx, `DELETE FROM outages WHERE target_id=?`, id)
-	_, _ = s.DB.ExecContext(ctx, `DELETE FROM logs    WHERE target_id=?`, id)
-	_, err := s.DB.ExecContext(ctx, `DELETE FROM targets WHERE id=?`, id)
-	return err
+func scanTarget(sc interface{ Scan(...any) error }) (TargetRow, error) {
+	var t TargetRow
+	var tlsInt, skipInt int
+	var assertions, headers, auth, steps, tags, selector string
+	var fresh sql.NullBool
+	var pausedInt int
+	if err := sc.Scan(&t.ID, &t.ProjectID, &t.Name, &t.Type, &t.URL, &t.TimeoutMs, &t.CreatedAt,
+		&t.GRPCService, &tlsInt, &skipInt, &assertions,
+		&t.Method, &headers, &t.Body, &auth, &steps, &t.IntervalSec, &fresh,
+		&t.Description, &tags, &pausedInt, &t.Key, &t.Group, &selector); err != nil {
+		return t, err
+	}
+	t.GRPCTLS = tlsInt == 1
+	t.GRPCTLSSkipVerify = skipInt == 1
+	t.Paused = pausedInt == 1
+	if fresh.Valid {
+		t.FreshConnection = &fresh.Bool
+	}
+	if err := fromJSONText(assertions, &t.Assertions); err != nil {
+		return t, err
+	}
+	if err := fromJSONText(headers, &t.Headers); err != nil {
+		return t, err
+	}
+	if err := fromJSONText(auth, &t.Auth); err != nil {
+		return t, err
+	}
+	if err := fromJSONText(steps, &t.Steps); err != nil {
+		return t, err
+	}
+	if err := fromJSONText(tags, &t.Tags); err != nil {
+		return t, err
+	}
+	if err := fromJSONText(selector, &t.AgentSelector); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// GetTarget returns nil if the target doesn't exist in the project.
+func (s *Store) GetTarget(ctx context.Context, projectID, id int64) (*TargetRow, error) {
+	t, err := scanTarget(s.queryRow(ctx,
+		`SELECT `+targetCols+` FROM targets WHERE id=? AND project_id=?`, id, projectID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteTarget removes a target with its checks, outages, logs and pauses,
+// all or nothing.
+func (s *Store) DeleteTarget(ctx context.Context, projectID, id int64) error {
+	return s.inTx(ctx, func(s *Store) error {
+		for _, q := range []string{
+			`DELETE FROM checks  WHERE target_id=? AND project_id=?`,
+			`DELETE FROM outages WHERE target_id=? AND project_id=?`,
+			`DELETE FROM logs    WHERE target_id=? AND project_id=?`,
+			`DELETE FROM target_pauses WHERE target_id=? AND project_id=?`,
+		} {
+			if _, err := s.exec(ctx, q, id, projectID); err != nil {
+				return err
+			}
+		}
+		_, err := s.exec(ctx, `DELETE FROM targets WHERE id=? AND project_id=?`, id, projectID)
+		return err
+	})
+}
+
+// target pauses
+
+type PauseRow struct {
+	ID        int64
+	TargetID  int64
+	StartedAt time.Time
+	EndedAt   sql.NullTime
+}
+
+// PauseTarget marks the target paused and starts a pause period. It
+// reports false if the target was already paused or doesn't exist.
+func (s *Store) PauseTarget(ctx context.Context, projectID, id int64, at time.Time) (bool, error) {
+	return s.setPaused(ctx, projectID, id, true,
+		`INSERT INTO target_pauses(project_id,target_id,started_at) VALUES(?,?,?)`, projectID, id, at)
+}
+
+// ResumeTarget clears the paused flag and ends the open pause period. It
+// reports false if the target wasn't paused or doesn't exist.
+func (s *Store) ResumeTarget(ctx context.Context, projectID, id int64, at time.Time) (bool, error) {
+	return s.setPaused(ctx, projectID, id, false,
+		`UPDATE target_pauses SET ended_at=? WHERE target_id=? AND project_id=? AND ended_at IS NULL`, at, id, projectID)
+}
+
+// setPaused flips the paused flag and, if it changed, runs the pause
+// period query in the same transaction.
+func (s *Store) setPaused(ctx context.Context, projectID, id int64, paused bool, q string, args ...any) (bool, error) {
+	from, to := 0, 1
+	if !paused {
+		from, to = 1, 0
+	}
+	changed := false
+	err := s.inTx(ctx, func(s *Store) error {
+		res, err := s.exec(ctx, `UPDATE targets SET paused=? WHERE id=? AND project_id=? AND paused=?`, to, id, projectID, from)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		changed = true
+		_, err = s.exec(ctx, q, args...)
+		return err
+	})
+	return changed && err == nil, err
+}
+
+func (s *Store) ListPausesOverlapping(ctx context.Context, projectID, targetID int64, from, to time.Time) ([]PauseRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT id,target_id,started_at,ended_at
+		 FROM target_pauses
+		 WHERE target_id=? AND project_id=?
+		   AND NOT (COALESCE(ended_at, ?) <= ? OR started_at >= ?)
+		 ORDER BY started_at ASC`,
+		targetID, projectID, to, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PauseRow
+	for rows.Next() {
+		var r PauseRow
+		if err := rows.Scan(&r.ID, &r.TargetID, &r.StartedAt, &r.EndedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
 }
 
 // checks
@@ -133,17 +359,40 @@ type CheckRow struct {
 	Error      string
 }
 
-func (s *Store) InsertCheck(ctx context.Context, targetID int64, ts time.Time, status int, ok bool, latencyMs int, reason string) error {
-	_, err := s.DB.ExecContext(ctx,
-		`INSERT INTO checks(target_id,ts,status_code,ok,latency_ms,error) VALUES(?,?,?,?,?,?)`,
-		targetID, ts, status, btoi(ok), latencyMs, reason)
+type Timings = client.Timings
+
+func (s *Store) InsertCheck(ctx context.Context, projectID, targetID, agentID int64, ts time.Time, status int, ok bool, latencyMs int, reason string, tm *Timings) error {
+	if tm == nil {
+		tm = &Timings{}
+	}
+	_, err := s.exec(ctx,
+		`INSERT INTO checks(project_id,target_id,agent_id,ts,status_code,ok,latency_ms,error,dns_ms,connect_ms,tls_ms,ttfb_ms,transfer_ms)
+		 VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		projectID, targetID, agentID, ts, status, btoi(ok), latencyMs, reason,
+		tm.DNSMs, tm.ConnectMs, tm.TLSMs, tm.TTFBMs, tm.TransferMs)
 	return err
 }
 
-func (s *Store) GetRecentChecks(ctx context.Context, targetID int64, limit int) ([]CheckRow, error) {
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT id,target_id,ts,status_code,ok,latency_ms,error
-		 FROM checks WHERE target_id=? ORDER BY ts DESC LIMIT ?`, targetID, limit)
+const lastCheckSQL = `SELECT ts,agent_id FROM checks WHERE target_id=? AND project_id=? ORDER BY ts DESC LIMIT 1`
+
+// LastCheck returns the newest check for a target and the agent that sent
+// it; ok is false when the target has never been checked.
+func (s *Store) LastCheck(ctx context.Context, projectID, targetID int64) (ts time.Time, agentID sql.NullInt64, ok bool, err error) {
+	row := s.queryRow(ctx, lastCheckSQL, targetID, projectID)
+	if err := row.Scan(&ts, &agentID); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, sql.NullInt64{}, false, nil
+		}
+		return time.Time{}, sql.NullInt64{}, false, err
+	}
+	return ts, agentID, true, nil
+}
+
+const recentChecksSQL = `SELECT id,target_id,ts,status_code,ok,latency_ms,error
+	FROM checks WHERE target_id=? AND project_id=? ORDER BY ts DESC LIMIT ?`
+
+func (s *Store) GetRecentChecks(ctx context.Context, projectID, targetID int64, limit int) ([]CheckRow, error) {
+	rows, err := s.query(ctx, recentChecksSQL, targetID, projectID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -171,11 +420,12 @@ type OutageRow struct {
 	Reason    string
 }
 
-func (s *Store) GetOpenOutage(ctx context.Context, targetID int64) (*OutageRow, error) {
-	row := s.DB.QueryRowContext(ctx,
-		`SELECT id,target_id,started_at,ended_at,reason
-		 FROM outages WHERE target_id=? AND ended_at IS NULL
-		 ORDER BY started_at DESC LIMIT 1`, targetID)
+const openOutageSQL = `SELECT id,target_id,started_at,ended_at,reason
+	FROM outages WHERE target_id=? AND project_id=? AND ended_at IS NULL
+	ORDER BY started_at DESC LIMIT 1`
+
+func (s *Store) GetOpenOutage(ctx context.Context, projectID, targetID int64) (*OutageRow, error) {
+	row := s.queryRow(ctx, openOutageSQL, targetID, projectID)
 	var o OutageRow
 	if err := row.Scan(&o.ID, &o.TargetID, &o.StartedAt, &o.EndedAt, &o.Reason); err != nil {
 		if err == sql.ErrNoRows {
@@ -186,15 +436,15 @@ func (s *Store) GetOpenOutage(ctx context.Context, targetID int64) (*OutageRow,
 	return &o, nil
 }
 
-func (s *Store) OpenOutage(ctx context.Context, targetID int64, startedAt time.Time, reason string) error {
-	_, err := s.DB.ExecContext(ctx,
-		`INSERT INTO outages(target_id,started_at,reason) VALUES(?,?,?)`,
-		targetID, startedAt, reason)
+func (s *Store) OpenOutage(ctx context.Context, projectID, targetID int64, startedAt time.Time, reason string) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO outages(project_id,target_id,started_at,reason) VALUES(?,?,?,?)`,
+		projectID, targetID, startedAt, reason)
 	return err
 }
 
 func (s *Store) CloseOutage(ctx context.Context, id int64, endedAt time.Time) error {
-	_, err := s.DB.ExecContext(ctx,
+	_, err := s.exec(ctx,
 		`UPDATE outages SET ended_at=? WHERE id=?`, endedAt, id)
 	return err
 }
@@ -202,25 +452,28 @@ func (s *Store) CloseOutage(ctx context.Context, id int64, endedAt time.Time) er
 // aggregates
 
 type ReasonCount struct {
-	Reason string
-	Count  int64
+	Reason   string
+	Category string
+	Count    int64
 }
 
-func (s *Store) CountChecksAgg(ctx context.Context, targetID int64, from, to time.Time) (total, success int64, err error) {
-	row := s.DB.QueryRowContext(ctx,
-		`SELECT COUNT(*), SUM(CASE WHEN ok=1 THEN 1 ELSE 0 END)
-		 FROM checks WHERE target_id=? AND ts>=? AND ts<?`, targetID, from, to)
+const countChecksSQL = `SELECT COUNT(*), COALESCE(SUM(CASE WHEN ok=1 THEN 1 ELSE 0 END), 0)
+	FROM checks WHERE target_id=? AND project_id=? AND ts>=? AND ts<?`
+
+func (s *Store) CountChecksAgg(ctx context.Context, projectID, targetID int64, from, to time.Time) (total, success int64, err error) {
+	row := s.queryRow(ctx, countChecksSQL, targetID, projectID, from, to)
 	if err := row.Scan(&total, &success); err != nil {
 		return 0, 0, err
 	}
 	return total, success, nil
 }
 
-func (s *Store) AvgLatencyOK(ctx context.Context, targetID int64, from, to time.Time) (sql.NullFloat64, error) {
-	row := s.DB.QueryRowContext(ctx,
-		`SELECT AVG(latency_ms)
-		 FROM checks WHERE target_id=? AND ok=1 AND ts>=? AND ts<?`,
-		targetID, from, to)
+const avgLatencySQL = `SELECT AVG(latency_ms)
+	FROM checks WHERE target_id=? AND project_id=? AND ok=1 AND ts>=? AND ts<?`
+
+func (s *Store) AvgLatencyOK(ctx context.Context, projectID, targetID int64, from, to time.Time) (sql.NullFloat64, error) {
+	row := s.queryRow(ctx, avgLatencySQL,
+		targetID, projectID, from, to)
 	var avg sql.NullFloat64
 	if err := row.Scan(&avg); err != nil {
 		return sql.NullFloat64{}, err
@@ -228,12 +481,32 @@ func (s *Store) AvgLatencyOK(ctx context.Context, targetID int64, from, to time.
 	return avg, nil
 }
 
-func (s *Store) FailuresByReason(ctx context.Context, targetID int64, from, to time.Time) ([]ReasonCount, error) {
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT error, COUNT(*) FROM checks
-		 WHERE target_id=? AND ok=0 AND ts>=? AND ts<? 
-		 GROUP BY error ORDER BY COUNT(*) DESC`,
-		targetID, from, to)
+// PhaseAvg holds per-phase averages over successful checks; a phase with
+// no samples in the window stays invalid.
+type PhaseAvg struct {
+	DNS, Connect, TLS, TTFB, Transfer sql.NullFloat64
+}
+
+const avgPhasesSQL = `SELECT AVG(dns_ms), AVG(connect_ms), AVG(tls_ms), AVG(ttfb_ms), AVG(transfer_ms)
+	FROM checks WHERE target_id=? AND project_id=? AND ok=1 AND ts>=? AND ts<?`
+
+func (s *Store) AvgPhasesOK(ctx context.Context, projectID, targetID int64, from, to time.Time) (PhaseAvg, error) {
+	row := s.queryRow(ctx, avgPhasesSQL,
+		targetID, projectID, from, to)
+	var p PhaseAvg
+	if err := row.Scan(&p.DNS, &p.Connect, &p.TLS, &p.TTFB, &p.Transfer); err != nil {
+		return PhaseAvg{}, err
+	}
+	return p, nil
+}
+
+const failuresByReasonSQL = `SELECT error, COUNT(*) FROM checks
+	WHERE target_id=? AND project_id=? AND ok=0 AND ts>=? AND ts<?
+	GROUP BY error ORDER BY COUNT(*) DESC`
+
+func (s *Store) FailuresByReason(ctx context.Context, projectID, targetID int64, from, to time.Time) ([]ReasonCount, error) {
+	rows, err := s.query(ctx, failuresByReasonSQL,
+		targetID, projectID, from, to)
 	if err != nil {
 		return nil, err
 	}
@@ -244,19 +517,70 @@ func (s *Store) FailuresByReason(ctx context.Context, targetID int64, from, to t
 		if err := rows.Scan(&rc.Reason, &rc.Count); err != nil {
 			return nil, err
 		}
+		rc.Category = ReasonCategory(rc.Reason)
 		out = append(out, rc)
 	}
 	return out, rows.Err()
 }
 
-func (s *Store) ListOutagesOverlapping(ctx context.Context, targetID int64, from, to time.Time) ([]OutageRow, error) {
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT id,target_id,started_at,ended_at,reason
-		 FROM outages
-		 WHERE target_id=?
-		   AND NOT (COALESCE(ended_at, ?) <= ? OR started_at >= ?)
-		 ORDER BY started_at ASC`,
-		targetID, to, from, to)
+const outagesOverlappingSQL = `SELECT id,target_id,started_at,ended_at,reason
+	FROM outages
+	WHERE target_id=? AND project_id=?
+	  AND NOT (COALESCE(ended_at, ?) <= ? OR started_at >= ?)
+	ORDER BY started_at ASC`
+
+func (s *Store) ListOutagesOverlapping(ctx context.Context, projectID, targetID int64, from, to time.Time) ([]OutageRow, error) {
+	rows, err := s.query(ctx, outagesOverlappingSQL,
+		targetID, projectID, to, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []OutageRow
+	for rows.Next() {
+		var r OutageRow
+		if err := rows.Scan(&r.ID, &r.TargetID, &r.StartedAt, &r.EndedAt, &r.Reason); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// OutageFilter narrows ListOutages. Zero values don't filter; From/To keep
+// outages that overlap the window.
+type OutageFilter struct {
+	TargetID int64
+	OpenOnly bool
+	From, To time.Time
+	Limit    int
+}
+
+// ListOutages returns a project's outages, newest first.
+func (s *Store) ListOutages(ctx context.Context, projectID int64, f OutageFilter) ([]OutageRow, error) {
+	q := `SELECT id,target_id,started_at,ended_at,reason FROM outages WHERE project_id=?`
+	args := []any{projectID}
+	if f.TargetID > 0 {
+		q += ` AND target_id=?`
+		args = append(args, f.TargetID)
+	}
+	if f.OpenOnly {
+		q += ` AND ended_at IS NULL`
+	}
+	if !f.From.IsZero() {
+		q += ` AND (ended_at IS NULL OR ended_at > ?)`
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		q += ` AND started_at < ?`
+		args = append(args, f.To)
+	}
+	q += ` ORDER BY started_at DESC`
+	if f.Limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+	rows, err := s.query(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -276,27 +600,83 @@ func (s *Store) ListOutagesOverlapping(ctx context.Context, targetID int64, from
 
 type AgentRow struct {
 	ID        int64
+	ProjectID int64
 	Name      string
-	APIKey    string
+	KeyPrefix string // first characters of the key; the key itself is only stored hashed
 	CreatedAt time.Time
+	Labels    map[string]string
+	RevokedAt sql.NullTime
+
+	// after a key rotation the previous key keeps working until
+	// PrevKeyExpiresAt so the agent can be redeployed without a gap
+	PrevKeyPrefix    string
+	PrevKeyExpiresAt sql.NullTime
+
+	// from heartbeats; LastSeenAt is also touched by ingest
+	LastSeenAt  sql.NullTime
+	Version     string
+	Hostname    string
+	UptimeSec   int64
+	TargetCount int
+	SpoolDepth  int
+}
+
+const agentCols = `id,project_id,name,key_prefix,created_at,labels,revoked_at,prev_key_prefix,prev_key_expires_at,
+	last_seen_at,version,hostname,uptime_sec,target_count,spool_depth`
+
+func scanAgent(sc interface{ Scan(...any) error }, extra ...any) (AgentRow, error) {
+	var a AgentRow
+	var labels string
+	dest := append([]any{&a.ID, &a.ProjectID, &a.Name, &a.KeyPrefix, &a.CreatedAt, &labels, &a.RevokedAt, &a.PrevKeyPrefix, &a.PrevKeyExpiresAt,
+		&a.LastSeenAt, &a.Version, &a.Hostname, &a.UptimeSec, &a.TargetCount, &a.SpoolDepth}, extra...)
+	if err := sc.Scan(dest...); err != nil {
+		return a, err
+	}
+	return a, fromJSONText(labels, &a.Labels)
+}
+
+func (s *Store) CreateAgent(ctx context.Context, projectID int64, name, apiKey string) (int64, error) {
+	now := time.Now().UTC()
+	return s.insert(ctx,
+		`INSERT INTO agents(project_id,name,key_hash,key_prefix,created_at) VALUES(?,?,?,?,?)`,
+		projectID, name, hashKey(apiKey), KeyPrefix(apiKey), now)
 }
 
-func (s *Store) CreateAgent(ctx context.Context, name, apiKey string) (int64, error) {
+// FindAgentByKey returns the non-revoked agent whose current key, or
+// previous key while its overlap period lasts, matches key. Rows are found
+// by prefix and the key is then checked against the stored hash.
+func (s *Store) FindAgentByKey(ctx context.Context, key string) (*AgentRow, error) {
+	prefix := KeyPrefix(key)
 	now := time.Now().UTC()
-	res, err := s.DB.ExecContext(ctx,
-		`INSERT INTO agents(name,api_key,created_at) VALUES(?,?,?)`,
-		name, apiKey, now)
+	rows, err := s.query(ctx,
+		`SELECT `+agentCols+`,key_hash,prev_key_hash FROM agents
+		 WHERE revoked_at IS NULL
+		   AND (key_prefix=? OR (prev_key_prefix=? AND prev_key_expires_at>?))`,
+		prefix, prefix, now)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash, prevHash string
+		a, err := scanAgent(rows, &hash, &prevHash)
+		if err != nil {
+			return nil, err
+		}
+		cur := a.KeyPrefix == prefix && keyMatches(key, hash)
+		prev := a.PrevKeyPrefix == prefix && a.PrevKeyExpiresAt.Valid &&
+			a.PrevKeyExpiresAt.Time.After(now) && keyMatches(key, prevHash)
+		if cur || prev {
+			return &a, nil
+		}
 	}
-	return res.LastInsertId()
+	return nil, rows.Err()
 }
 
-func (s *Store) FindAgentByKey(ctx context.Context, key string) (*AgentRow, error) {
-	row := s.DB.QueryRowContext(ctx,
-		`SELECT id,name,api_key,created_at FROM agents WHERE api_key=?`, key)
-	var a AgentRow
-	if err := row.Scan(&a.ID, &a.Name, &a.APIKey, &a.CreatedAt); err != nil {
+func (s *Store) GetAgent(ctx context.Context, projectID, id int64) (*AgentRow, error) {
+	row := s.queryRow(ctx, `SELECT `+agentCols+` FROM agents WHERE id=? AND project_id=?`, id, projectID)
+	a, err := scanAgent(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -305,6 +685,751 @@ func (s *Store) FindAgentByKey(ctx context.Context, key string) (*AgentRow, erro
 	return &a, nil
 }
 
+func (s *Store) UpdateAgent(ctx context.Context, projectID, id int64, name string, labels map[string]string) error {
+	_, err := s.exec(ctx,
+		`UPDATE agents SET name=?,labels=? WHERE id=? AND project_id=?`, name, jsonText(labels), id, projectID)
+	return err
+}
+
+// RevokeAgent disables both of the agent's keys. The row and its checks are
+// kept for history.
+func (s *Store) RevokeAgent(ctx context.Context, projectID, id int64, at time.Time) error {
+	_, err := s.exec(ctx,
+		`UPDATE agents SET revoked_at=?,prev_key_hash='',prev_key_prefix='',prev_key_expires_at=NULL
+		 WHERE id=? AND project_id=? AND revoked_at IS NULL`, at, id, projectID)
+	return err
+}
+
+// RotateAgentKey makes newKey the agent's key. The old key stays valid
+// until overlapUntil; pass a time in the past to drop it immediately.
+func (s *Store) RotateAgentKey(ctx context.Context, projectID, id int64, newKey string, overlapUntil time.Time) error {
+	_, err := s.exec(ctx,
+		`UPDATE agents SET prev_key_hash=key_hash,prev_key_prefix=key_prefix,prev_key_expires_at=?,
+		   key_hash=?,key_prefix=?
+		 WHERE id=? AND project_id=? AND revoked_at IS NULL`, overlapUntil, hashKey(newKey), KeyPrefix(newKey), id, projectID)
+	return err
+}
+
+func (s *Store) ListAgents(ctx context.Context, projectID int64) ([]AgentRow, error) {
+	return s.queryAgents(ctx, `SELECT `+agentCols+` FROM agents WHERE project_id=? ORDER BY id ASC`, projectID)
+}
+
+// ListAllAgents spans every project; it is for server-side housekeeping
+// such as offline detection, not for API responses.
+func (s *Store) ListAllAgents(ctx context.Context) ([]AgentRow, error) {
+	return s.queryAgents(ctx, `SELECT `+agentCols+` FROM agents ORDER BY id ASC`)
+}
+
+func (s *Store) queryAgents(ctx context.Context, q string, args ...any) ([]AgentRow, error) {
+	rows, err := s.query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AgentRow
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+type Heartbeat = client.Heartbeat
+
+func (s *Store) RecordHeartbeat(ctx context.Context, agentID int64, hb Heartbeat, at time.Time) error {
+	_, err := s.exec(ctx,
+		`UPDATE agents SET last_seen_at=?,version=?,hostname=?,uptime_sec=?,target_count=?,spool_depth=?
+		 WHERE id=?`,
+		at, hb.Version, hb.Hostname, hb.UptimeSec, hb.TargetCount, hb.SpoolDepth, agentID)
+	return err
+}
+
+func (s *Store) TouchAgent(ctx context.Context, agentID int64, at time.Time) error {
+	_, err := s.exec(ctx, `UPDATE agents SET last_seen_at=? WHERE id=?`, at, agentID)
+	return err
+}
+
+// agent outages
+
+type AgentOutageRow struct {
+	ID        int64
+	AgentID   int64
+	StartedAt time.Time
+	EndedAt   sql.NullTime
+}
+
+func (s *Store) GetOpenAgentOutage(ctx context.Context, agentID int64) (*AgentOutageRow, error) {
+	row := s.queryRow(ctx,
+		`SELECT id,agent_id,started_at,ended_at FROM agent_outages
+		 WHERE agent_id=? AND ended_at IS NULL ORDER BY started_at DESC LIMIT 1`, agentID)
+	var o AgentOutageRow
+	if err := row.Scan(&o.ID, &o.AgentID, &o.StartedAt, &o.EndedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *Store) OpenAgentOutage(ctx context.Context, agentID int64, startedAt time.Time) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO agent_outages(agent_id,started_at) VALUES(?,?)`, agentID, startedAt)
+	return err
+}
+
+func (s *Store) CloseAgentOutage(ctx context.Context, id int64, endedAt time.Time) error {
+	_, err := s.exec(ctx, `UPDATE agent_outages SET ended_at=? WHERE id=?`, endedAt, id)
+	return err
+}
+
+func (s *Store) ListAgentOutagesOverlapping(ctx context.Context, agentID int64, from, to time.Time) ([]AgentOutageRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT id,agent_id,started_at,ended_at
+		 FROM agent_outages
+		 WHERE agent_id=?
+		   AND NOT (COALESCE(ended_at, ?) <= ? OR started_at >= ?)
+		 ORDER BY started_at ASC`,
+		agentID, to, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AgentOutageRow
+	for rows.Next() {
+		var o AgentOutageRow
+		if err := rows.Scan(&o.ID, &o.AgentID, &o.StartedAt, &o.EndedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// users
+
+// user roles, from least to most privileged
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+type UserRow struct {
+	ID         int64
+	Username   string
+	Role       string
+	CreatedAt  time.Time
+	DisabledAt sql.NullTime
+}
+
+const userCols = `id,username,role,created_at,disabled_at`
+
+func scanUser(sc interface{ Scan(...any) error }, extra ...any) (UserRow, error) {
+	var u UserRow
+	err := sc.Scan(append([]any{&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.DisabledAt}, extra...)...)
+	return u, err
+}
+
+func (s *Store) CountUsers(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.queryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&n)
+	return n, err
+}
+
+func (s *Store) CreateUser(ctx context.Context, username, password, role string) (int64, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	return s.insert(ctx,
+		`INSERT INTO users(username,password_hash,role,created_at) VALUES(?,?,?,?)`,
+		username, hash, role, time.Now().UTC())
+}
+
+func (s *Store) GetUser(ctx context.Context, id int64) (*UserRow, error) {
+	u, err := scanUser(s.queryRow(ctx, `SELECT `+userCols+` FROM users WHERE id=?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]UserRow, error) {
+	rows, err := s.query(ctx, `SELECT `+userCols+` FROM users ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserRow
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// AuthenticateUser returns the enabled user with these credentials, or nil.
+func (s *Store) AuthenticateUser(ctx context.Context, username, password string) (*UserRow, error) {
+	var hash string
+	u, err := scanUser(s.queryRow(ctx,
+		`SELECT `+userCols+`,password_hash FROM users WHERE username=?`, username), &hash)
+	if err == sql.ErrNoRows {
+		// same cost as a real check so timing doesn't reveal valid usernames
+		passwordMatches(password, dummyPasswordHash)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !passwordMatches(password, hash) || u.DisabledAt.Valid {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+// UpdateUser sets the role, enabled state and, unless password is empty,
+// the password, all in one transaction. Disabling ends every session and
+// revokes every token; a new password ends the user's sessions, so a leaked
+// session doesn't outlive the password it was opened with, but keeps API
+// tokens.
+func (s *Store) UpdateUser(ctx context.Context, id int64, role string, disabled bool, password string) error {
+	var hash string
+	if password != "" {
+		var err error
+		if hash, err = hashPassword(password); err != nil {
+			return err
+		}
+	}
+	return s.inTx(ctx, func(s *Store) error {
+		if disabled {
+			if _, err := s.exec(ctx, `UPDATE users SET role=?,disabled_at=COALESCE(disabled_at,?) WHERE id=?`, role, time.Now().UTC(), id); err != nil {
+				return err
+			}
+		} else if _, err := s.exec(ctx, `UPDATE users SET role=?,disabled_at=NULL WHERE id=?`, role, id); err != nil {
+			return err
+		}
+		if hash != "" {
+			if _, err := s.exec(ctx, `UPDATE users SET password_hash=? WHERE id=?`, hash, id); err != nil {
+				return err
+			}
+		}
+		switch {
+		case disabled:
+			_, err := s.exec(ctx, `DELETE FROM api_tokens WHERE user_id=?`, id)
+			return err
+		case hash != "":
+			_, err := s.exec(ctx, `DELETE FROM api_tokens WHERE user_id=? AND session=1`, id)
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id int64) error {
+	return s.inTx(ctx, func(s *Store) error {
+		for _, q := range []string{
+			`DELETE FROM api_tokens WHERE user_id=?`,
+			`DELETE FROM project_members WHERE user_id=?`,
+			`DELETE FROM users WHERE id=?`,
+		} {
+			if _, err := s.exec(ctx, q, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// api tokens
+
+type TokenRow struct {
+	ID        int64
+	UserID    int64
+	ProjectID int64 // every request made with the token is scoped to this project
+	Name      string
+	Prefix    string
+	CreatedAt time.Time
+	ExpiresAt sql.NullTime
+	Session   bool // from a login rather than /api/tokens
+}
+
+const tokenCols = `id,user_id,project_id,name,token_prefix,created_at,expires_at,session`
+
+// CreateToken stores a hashed bearer token for userID scoped to projectID.
+// A nil expiresAt means the token lives until deleted.
+func (s *Store) CreateToken(ctx context.Context, userID, projectID int64, name, token string, expiresAt *time.Time) (int64, error) {
+	var exp any
+	if expiresAt != nil {
+		exp = *expiresAt
+	}
+	return s.insert(ctx,
+		`INSERT INTO api_tokens(user_id,project_id,name,token_prefix,token_hash,created_at,expires_at) VALUES(?,?,?,?,?,?,?)`,
+		userID, projectID, name, KeyPrefix(token), hashKey(token), time.Now().UTC(), exp)
+}
+
+// CreateSession stores the token of a login. Sessions are tokens that end
+// when their user's password changes and may switch project.
+func (s *Store) CreateSession(ctx context.Context, userID, projectID int64, token string, expiresAt time.Time) (int64, error) {
+	return s.insert(ctx,
+		`INSERT INTO api_tokens(user_id,project_id,name,token_prefix,token_hash,created_at,expires_at,session) VALUES(?,?,?,?,?,?,?,1)`,
+		userID, projectID, "session", KeyPrefix(token), hashKey(token), time.Now().UTC(), expiresAt)
+}
+
+// FindUserByToken returns the enabled user owning an unexpired token, and
+// the token, or nil. Tokens stop working once their user loses access to
+// the token's project.
+func (s *Store) FindUserByToken(ctx context.Context, token string) (*UserRow, *TokenRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT u.id,u.username,u.role,u.created_at,u.disabled_at,
+		        t.id,t.user_id,t.project_id,t.name,t.token_prefix,t.created_at,t.expires_at,t.session,t.token_hash
+		 FROM api_tokens t JOIN users u ON u.id=t.user_id
+		 WHERE t.token_prefix=? AND u.disabled_at IS NULL
+		   AND (t.expires_at IS NULL OR t.expires_at>?)
+		   AND (u.role=? OR EXISTS (SELECT 1 FROM project_members m
+		                            WHERE m.user_id=u.id AND m.project_id=t.project_id))`,
+		KeyPrefix(token), time.Now().UTC(), RoleAdmin)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t TokenRow
+		var hash string
+		var session int
+		u, err := scanUser(rows, &t.ID, &t.UserID, &t.ProjectID, &t.Name, &t.Prefix, &t.CreatedAt, &t.ExpiresAt, &session, &hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		if keyMatches(token, hash) {
+			t.Session = session != 0
+			return &u, &t, nil
+		}
+	}
+	return nil, nil, rows.Err()
+}
+
+// SetTokenProject re-scopes a token, e.g. when the dashboard switches project.
+func (s *Store) SetTokenProject(ctx context.Context, tokenID, projectID int64) error {
+	_, err := s.exec(ctx, `UPDATE api_tokens SET project_id=? WHERE id=?`, projectID, tokenID)
+	return err
+}
+
+func (s *Store) ListTokens(ctx context.Context, userID int64) ([]TokenRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT `+tokenCols+` FROM api_tokens WHERE user_id=? ORDER BY id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TokenRow
+	for rows.Next() {
+		var t TokenRow
+		var session int
+		if err := rows.Scan(&t.ID, &t.UserID, &t.ProjectID, &t.Name, &t.Prefix, &t.CreatedAt, &t.ExpiresAt, &session); err != nil {
+			return nil, err
+		}
+		t.Session = session != 0
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// DeleteToken removes one of userID's tokens; it reports false if there
+// was no such token.
+func (s *Store) DeleteToken(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := s.exec(ctx, `DELETE FROM api_tokens WHERE id=? AND user_id=?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// projects
+
+// DefaultProjectID holds everything created before projects existed.
+const DefaultProjectID = 1
+
+type ProjectRow struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+}
+
+func (s *Store) CreateProject(ctx context.Context, name string) (int64, error) {
+	return s.insert(ctx,
+		`INSERT INTO projects(name,created_at) VALUES(?,?)`, name, time.Now().UTC())
+}
+
+func (s *Store) GetProject(ctx context.Context, id int64) (*ProjectRow, error) {
+	var p ProjectRow
+	err := s.queryRow(ctx, `SELECT id,name,created_at FROM projects WHERE id=?`, id).
+		Scan(&p.ID, &p.Name, &p.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *Store) ListProjects(ctx context.Context) ([]ProjectRow, error) {
+	return s.queryProjects(ctx, `SELECT id,name,created_at FROM projects ORDER BY id ASC`)
+}
+
+// ListUserProjects returns the projects userID is a member of.
+func (s *Store) ListUserProjects(ctx context.Context, userID int64) ([]ProjectRow, error) {
+	return s.queryProjects(ctx,
+		`SELECT p.id,p.name,p.created_at FROM projects p
+		 JOIN project_members m ON m.project_id=p.id
+		 WHERE m.user_id=? ORDER BY p.id ASC`, userID)
+}
+
+func (s *Store) queryProjects(ctx context.Context, q string, args ...any) ([]ProjectRow, error) {
+	rows, err := s.query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ProjectRow
+	for rows.Next() {
+		var p ProjectRow
+		if err := rows.Scan(&p.ID, &p.Name, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) RenameProject(ctx context.Context, id int64, name string) error {
+	_, err := s.exec(ctx, `UPDATE projects SET name=? WHERE id=?`, name, id)
+	return err
+}
+
+// ProjectInUse reports whether any targets or agents still belong to id.
+func (s *Store) ProjectInUse(ctx context.Context, id int64) (bool, error) {
+	var n int64
+	err := s.queryRow(ctx,
+		`SELECT (SELECT COUNT(*) FROM targets WHERE project_id=?) + (SELECT COUNT(*) FROM agents WHERE project_id=?)`,
+		id, id).Scan(&n)
+	return n > 0, err
+}
+
+// DeleteProject removes a project without targets or agents, together with
+// its memberships, tokens, groups, alert routes and maintenance windows,
+// all or nothing.
+func (s *Store) DeleteProject(ctx context.Context, id int64) error {
+	return s.inTx(ctx, func(s *Store) error {
+		for _, q := range []string{
+			`DELETE FROM api_tokens WHERE project_id=?`,
+			`DELETE FROM project_members WHERE project_id=?`,
+			`DELETE FROM target_groups WHERE project_id=?`,
+			`DELETE FROM alert_routes WHERE project_id=?`,
+			`DELETE FROM maintenance_windows WHERE project_id=?`,
+			`DELETE FROM projects WHERE id=?`,
+		} {
+			if _, err := s.exec(ctx, q, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) AddProjectMember(ctx context.Context, projectID, userID int64) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO project_members(project_id,user_id) VALUES(?,?) ON CONFLICT DO NOTHING`, projectID, userID)
+	return err
+}
+
+func (s *Store) RemoveProjectMember(ctx context.Context, projectID, userID int64) error {
+	_, err := s.exec(ctx,
+		`DELETE FROM project_members WHERE project_id=? AND user_id=?`, projectID, userID)
+	return err
+}
+
+func (s *Store) ListProjectMembers(ctx context.Context, projectID int64) ([]UserRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT u.id,u.username,u.role,u.created_at,u.disabled_at FROM users u
+		 JOIN project_members m ON m.user_id=u.id
+		 WHERE m.project_id=? ORDER BY u.id ASC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserRow
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// Selector picks targets by key, group or tag for alert routes and
+// maintenance windows. A target matches if any listed value applies to it;
+// an empty selector matches every target.
+type Selector struct {
+	Targets []string `json:"targets,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+func (sel Selector) Empty() bool {
+	return len(sel.Targets) == 0 && len(sel.Groups) == 0 && len(sel.Tags) == 0
+}
+
+func (sel Selector) Matches(t TargetRow) bool {
+	if sel.Empty() {
+		return true
+	}
+	for _, k := range sel.Targets {
+		if t.Key != "" && k == t.Key {
+			return true
+		}
+	}
+	for _, g := range sel.Groups {
+		if t.Group != "" && g == t.Group {
+			return true
+		}
+	}
+	for _, tag := range sel.Tags {
+		for _, have := range t.Tags {
+			if strings.EqualFold(tag, have) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// target groups
+
+type GroupRow struct {
+	ID          int64  `json:"-"`
+	ProjectID   int64  `json:"-"`
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+func (s *Store) ListGroups(ctx context.Context, projectID int64) ([]GroupRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT id,project_id,key,name,description FROM target_groups WHERE project_id=? ORDER BY key ASC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []GroupRow
+	for rows.Next() {
+		var g GroupRow
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Key, &g.Name, &g.Description); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// GroupExists reports whether key names a group in the project.
+func (s *Store) GroupExists(ctx context.Context, projectID int64, key string) (bool, error) {
+	var n int
+	err := s.queryRow(ctx,
+		`SELECT COUNT(*) FROM target_groups WHERE project_id=? AND key=?`, projectID, key).Scan(&n)
+	return n > 0, err
+}
+
+func (s *Store) InsertGroup(ctx context.Context, g GroupRow) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO target_groups(project_id,key,name,description) VALUES(?,?,?,?)`,
+		g.ProjectID, g.Key, g.Name, g.Description)
+	return err
+}
+
+func (s *Store) UpdateGroup(ctx context.Context, g GroupRow) error {
+	_, err := s.exec(ctx,
+		`UPDATE target_groups SET name=?,description=? WHERE project_id=? AND key=?`,
+		g.Name, g.Description, g.ProjectID, g.Key)
+	return err
+}
+
+// DeleteGroup also takes its remaining targets out of the group.
+func (s *Store) DeleteGroup(ctx context.Context, projectID int64, key string) error {
+	if _, err := s.exec(ctx,
+		`UPDATE targets SET group_key='' WHERE project_id=? AND group_key=?`, projectID, key); err != nil {
+		return err
+	}
+	_, err := s.exec(ctx, `DELETE FROM target_groups WHERE project_id=? AND key=?`, projectID, key)
+	return err
+}
+
+// alert routes
+
+// AlertRouteRow sends outage events for matching targets to a webhook.
+type AlertRouteRow struct {
+	ID         int64    `json:"-"`
+	ProjectID  int64    `json:"-"`
+	Key        string   `json:"key"`
+	Name       string   `json:"name"`
+	WebhookURL string   `json:"webhook_url"`
+	Match      Selector `json:"match"`
+}
+
+func (s *Store) ListAlertRoutes(ctx context.Context, projectID int64) ([]AlertRouteRow, error) {
+	rows, err := s.query(ctx,
+		`SELECT id,project_id,key,name,webhook_url,match FROM alert_routes WHERE project_id=? ORDER BY key ASC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AlertRouteRow
+	for rows.Next() {
+		var r AlertRouteRow
+		var match string
+		if err := rows.Scan(&r.ID, &r.ProjectID, &r.Key, &r.Name, &r.WebhookURL, &match); err != nil {
+			return nil, err
+		}
+		if err := fromJSONText(match, &r.Match); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) InsertAlertRoute(ctx context.Context, r AlertRouteRow) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO alert_routes(project_id,key,name,webhook_url,match) VALUES(?,?,?,?,?)`,
+		r.ProjectID, r.Key, r.Name, r.WebhookURL, selectorText(r.Match))
+	return err
+}
+
+func (s *Store) UpdateAlertRoute(ctx context.Context, r AlertRouteRow) error {
+	_, err := s.exec(ctx,
+		`UPDATE alert_routes SET name=?,webhook_url=?,match=? WHERE project_id=? AND key=?`,
+		r.Name, r.WebhookURL, selectorText(r.Match), r.ProjectID, r.Key)
+	return err
+}
+
+func (s *Store) DeleteAlertRoute(ctx context.Context, projectID int64, key string) error {
+	_, err := s.exec(ctx, `DELETE FROM alert_routes WHERE project_id=? AND key=?`, projectID, key)
+	return err
+}
+
+// maintenance windows
+
+// MaintenanceWindowRow silences alerts for matching targets between
+// StartsAt and EndsAt. Checks and outages are still recorded.
+type MaintenanceWindowRow struct {
+	ID        int64     `json:"-"`
+	ProjectID int64     `json:"-"`
+	Key       string    `json:"key"`
+	Name      string    `json:"name"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Match     Selector  `json:"match"`
+}
+
+func (s *Store) ListMaintenanceWindows(ctx context.Context, projectID int64) ([]MaintenanceWindowRow, error) {
+	return s.queryMaintenance(ctx,
+		`SELECT id,project_id,key,name,starts_at,ends_at,match FROM maintenance_windows WHERE project_id=? ORDER BY key ASC`, projectID)
+}
+
+// ActiveMaintenanceWindows returns the windows open at t.
+func (s *Store) ActiveMaintenanceWindows(ctx context.Context, projectID int64, t time.Time) ([]MaintenanceWindowRow, error) {
+	return s.queryMaintenance(ctx,
+		`SELECT id,project_id,key,name,starts_at,ends_at,match FROM maintenance_windows
+		 WHERE project_id=? AND starts_at<=? AND ends_at>?`, projectID, t, t)
+}
+
+func (s *Store) queryMaintenance(ctx context.Context, q string, args ...any) ([]MaintenanceWindowRow, error) {
+	rows, err := s.query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []MaintenanceWindowRow
+	for rows.Next() {
+		var w MaintenanceWindowRow
+		var match string
+		if err := rows.Scan(&w.ID, &w.ProjectID, &w.Key, &w.Name, &w.StartsAt, &w.EndsAt, &match); err != nil {
+			return nil, err
+		}
+		if err := fromJSONText(match, &w.Match); err != nil {
+			return nil, err
+		}
+		w.StartsAt, w.EndsAt = w.StartsAt.UTC(), w.EndsAt.UTC()
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) InsertMaintenanceWindow(ctx context.Context, w MaintenanceWindowRow) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO maintenance_windows(project_id,key,name,starts_at,ends_at,match) VALUES(?,?,?,?,?,?)`,
+		w.ProjectID, w.Key, w.Name, w.StartsAt.UTC(), w.EndsAt.UTC(), selectorText(w.Match))
+	return err
+}
+
+func (s *Store) UpdateMaintenanceWindow(ctx context.Context, w MaintenanceWindowRow) error {
+	_, err := s.exec(ctx,
+		`UPDATE maintenance_windows SET name=?,starts_at=?,ends_at=?,match=? WHERE project_id=? AND key=?`,
+		w.Name, w.StartsAt.UTC(), w.EndsAt.UTC(), selectorText(w.Match), w.ProjectID, w.Key)
+	return err
+}
+
+func (s *Store) DeleteMaintenanceWindow(ctx context.Context, projectID int64, key string) error {
+	_, err := s.exec(ctx, `DELETE FROM maintenance_windows WHERE project_id=? AND key=?`, projectID, key)
+	return err
+}
+
+func selectorText(sel Selector) string {
+	if sel.Empty() {
+		return ""
+	}
+	return jsonText(&sel)
+}
+
+// jsonText encodes optional structured columns; nil/empty is stored as an empty string.
+func jsonText(v any) string {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return ""
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return ""
+		}
+	case reflect.Slice, reflect.Map:
+		if rv.Len() == 0 {
+			return ""
+		}
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func fromJSONText(s string, v any) error {
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
 func btoi(b bool) int {
 	if b {
 		return 1
@@ -312,6 +1437,14 @@ func btoi(b bool) int {
 	return 0
 }
 
+// nullBtoi stores an optional bool as NULL, 0 or 1.
+func nullBtoi(b *bool) any {
+	if b == nil {
+		return nil
+	}
+	return btoi(*b)
+}
+
 // -------- LOGS (NEW) --------
 
 type LogRow struct {
@@ -323,35 +1456,35 @@ type LogRow struct {
 	Line     string
 }
 
-func (s *Store) InsertCheckLog(ctx context.Context, targetID int64, checkID *int64, ts time.Time, level, line string) error {
+func (s *Store) InsertCheckLog(ctx context.Context, projectID, targetID int64, checkID *int64, ts time.Time, level, line string) error {
 	var cid interface{}
 	if checkID != nil {
 		cid = *checkID
 	} else {
 		cid = nil
 	}
-	_, err := s.DB.ExecContext(ctx,
-		`INSERT INTO logs(target_id,check_id,ts,level,line) VALUES(?,?,?,?,?)`,
-		targetID, cid, ts, level, line)
+	_, err := s.exec(ctx,
+		`INSERT INTO logs(project_id,target_id,check_id,ts,level,line) VALUES(?,?,?,?,?,?)`,
+		projectID, targetID, cid, ts, level, line)
 	return err
 }
 
-func (s *Store) ListLogs(ctx context.Context, targetID int64, limit int, before *time.Time) ([]LogRow, error) {
+func (s *Store) ListLogs(ctx context.Context, projectID, targetID int64, limit int, before *time.Time) ([]LogRow, error) {
 	if limit <= 0 || limit > 1000 {
 		limit = 200
 	}
 	var rows *sql.Rows
 	var err error
 	if before != nil {
-		rows, err = s.DB.QueryContext(ctx,
+		rows, err = s.query(ctx,
 			`SELECT id,target_id,check_id,ts,level,line
-			 FROM logs WHERE target_id=? AND ts<? 
-			 ORDER BY ts DESC LIMIT ?`, targetID, *before, limit)
+			 FROM logs WHERE target_id=? AND project_id=? AND ts<? 
+			 ORDER BY ts DESC LIMIT ?`, targetID, projectID, *before, limit)
 	} else {
-		rows, err = s.DB.QueryContext(ctx,
+		rows, err = s.query(ctx,
 			`SELECT id,target_id,check_id,ts,level,line
-			 FROM logs WHERE target_id=? 
-			 ORDER BY ts DESC LIMIT ?`, targetID, limit)
+			 FROM logs WHERE target_id=? AND project_id=? 
+			 ORDER BY ts DESC LIMIT ?`, targetID, projectID, limit)
 	}
 	if err != nil {
 		return nil, err
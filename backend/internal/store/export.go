@@ -0,0 +1,513 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Exports are a header followed by one record per row: projects, targets,
+// pauses, agents, checks, outages and logs, in that order and each in id
+// order, and an end record counting them, so a cut-off file is rejected.
+// Ids are the exporting server's; Import maps them to new ones.
+const (
+	ExportFormat  = "status-probe-lite"
+	ExportVersion = 1
+)
+
+// ExportRecord is one line of an NDJSON export, or one element of a JSON
+// one.
+type ExportRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type exportHeader struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+type exportEnd struct {
+	Records int `json:"records"`
+}
+
+type exportProject struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportPause struct {
+	TargetID  int64      `json:"target_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// exportAgent leaves out the key hashes; imported agents get a key nobody
+// knows and must have it rotated before they can report.
+type exportAgent struct {
+	ID        int64             `json:"id"`
+	ProjectID int64             `json:"project_id"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	RevokedAt *time.Time        `json:"revoked_at,omitempty"`
+}
+
+type exportCheck struct {
+	TargetID   int64     `json:"target_id"`
+	AgentID    *int64    `json:"agent_id,omitempty"`
+	TS         time.Time `json:"ts"`
+	StatusCode int       `json:"status_code"`
+	OK         bool      `json:"ok"`
+	LatencyMs  int       `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+	Timings    *Timings  `json:"timings,omitempty"`
+}
+
+type exportOutage struct {
+	TargetID  int64      `json:"target_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Reason    string     `json:"reason"`
+}
+
+type exportLog struct {
+	TargetID int64     `json:"target_id"`
+	TS       time.Time `json:"ts"`
+	Level    string    `json:"level"`
+	Line     string    `json:"line"`
+}
+
+// ErrImportNotEmpty is returned by Import when the server already has
+// targets or agents, whose ids the imported history could be confused with.
+var ErrImportNotEmpty = errors.New("import needs a server without targets or agents")
+
+// ImportResult counts the records Import stored, by type.
+type ImportResult map[string]int
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	u := t.Time.UTC()
+	return &u
+}
+
+// Export writes every project's targets, pause history, agents, checks,
+// outages and logs to w, as NDJSON or, with asArray, as one JSON array.
+// It reads from a single snapshot, so ingest can go on meanwhile. Users,
+// tokens and declarative config are not included.
+func (s *Store) Export(ctx context.Context, w io.Writer, asArray bool) error {
+	opts := &sql.TxOptions{ReadOnly: true}
+	if s.dialect == postgres {
+		opts.Isolation = sql.LevelRepeatableRead
+	}
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	r := &Store{db: s.db, dialect: s.dialect, stmts: s.stmts, tx: tx}
+
+	bw := bufio.NewWriter(w)
+	n := 0
+	emit := func(typ string, v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(ExportRecord{Type: typ, Data: data})
+		if err != nil {
+			return err
+		}
+		sep := "\n"
+		switch {
+		case asArray && n == 0:
+			sep = "[\n"
+		case asArray:
+			sep = ",\n"
+		case n == 0:
+			sep = ""
+		}
+		n++
+		if _, err := bw.WriteString(sep); err != nil {
+			return err
+		}
+		_, err = bw.Write(b)
+		return err
+	}
+
+	if err := emit("header", exportHeader{ExportFormat, ExportVersion, SchemaVersion(), time.Now().UTC()}); err != nil {
+		return err
+	}
+	err = r.each(ctx, `SELECT id,name,created_at FROM projects ORDER BY id`, func(rows *sql.Rows) error {
+		var p exportProject
+		if err := rows.Scan(&p.ID, &p.Name, &p.CreatedAt); err != nil {
+			return err
+		}
+		return emit("project", p)
+	})
+	if err != nil {
+		return err
+	}
+	err = r.each(ctx, `SELECT `+targetCols+` FROM targets ORDER BY id`, func(rows *sql.Rows) error {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return err
+		}
+		return emit("target", t)
+	})
+	if err != nil {
+		return err
+	}
+	err = r.each(ctx, `SELECT target_id,started_at,ended_at FROM target_pauses ORDER BY id`, func(rows *sql.Rows) error {
+		var p exportPause
+		var ended sql.NullTime
+		if err := rows.Scan(&p.TargetID, &p.StartedAt, &ended); err != nil {
+			return err
+		}
+		p.EndedAt = nullTime(ended)
+		return emit("pause", p)
+	})
+	if err != nil {
+		return err
+	}
+	err = r.each(ctx, `SELECT id,project_id,name,labels,created_at,revoked_at FROM agents ORDER BY id`, func(rows *sql.Rows) error {
+		var a exportAgent
+		var labels string
+		var revoked sql.NullTime
+		if err := rows.Scan(&a.ID, &a.ProjectID, &a.Name, &labels, &a.CreatedAt, &revoked); err != nil {
+			return err
+		}
+		a.RevokedAt = nullTime(revoked)
+		if err := fromJSONText(labels, &a.Labels); err != nil {
+			return err
+		}
+		return emit("agent", a)
+	})
+	if err != nil {
+		return err
+	}
+	err = r.each(ctx, `SELECT target_id,agent_id,ts,status_code,ok,latency_ms,error,dns_ms,connect_ms,tls_ms,ttfb_ms,transfer_ms
+		 FROM checks ORDER BY id`, func(rows *sql.Rows) error {
+		var c exportCheck
+		var agent sql.NullInt64
+		var ok int
+		var tm Timings
+		if err := rows.Scan(&c.TargetID, &agent, &c.TS, &c.StatusCode, &ok, &c.LatencyMs, &c.Error,
+			&tm.DNSMs, &tm.ConnectMs, &tm.TLSMs, &tm.TTFBMs, &tm.TransferMs); err != nil {
+			return err
+		}
+		c.OK = ok == 1
+		if agent.Valid && agent.Int64 > 0 {
+			c.AgentID = &agent.Int64
+		}
+		if tm != (Timings{}) {
+			c.Timings = &tm
+		}
+		return emit("check", c)
+	})
+	if err != nil {
+		return err
+	}
+	err = r.each(ctx, `SELECT target_id,started_at,ended_at,reason FROM outages ORDER BY id`, func(rows *sql.Rows) error {
+		var o exportOutage
+		var ended sql.NullTime
+		if err := rows.Scan(&o.TargetID, &o.StartedAt, &ended, &o.Reason); err != nil {
+			return err
+		}
+		o.EndedAt = nullTime(ended)
+		return emit("outage", o)
+	})
+	if err != nil {
+		return err
+	}
+	err = r.each(ctx, `SELECT target_id,ts,level,line FROM logs ORDER BY id`, func(rows *sql.Rows) error {
+		var l exportLog
+		if err := rows.Scan(&l.TargetID, &l.TS, &l.Level, &l.Line); err != nil {
+			return err
+		}
+		return emit("log", l)
+	})
+	if err != nil {
+		return err
+	}
+	if err := emit("end", exportEnd{Records: n - 1}); err != nil {
+		return err
+	}
+	end := "\n"
+	if asArray {
+		end = "\n]\n"
+	}
+	if _, err := bw.WriteString(end); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// each runs q and calls fn for every row.
+func (s *Store) each(ctx context.Context, q string, fn func(*sql.Rows) error) error {
+	rows, err := s.query(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import reads an export, NDJSON or a JSON array, into a server that has
+// no targets or agents yet, in one transaction. Projects are matched by
+// name and created when missing; everything else gets new ids.
+func (s *Store) Import(ctx context.Context, r io.Reader) (ImportResult, error) {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	// a JSON export is an array of the same records
+	asArray := false
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("empty import: %w", err)
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			asArray = b[0] == '['
+			break
+		}
+		_, _ = br.ReadByte()
+	}
+	if asArray {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	next := func(rec *ExportRecord) error {
+		if asArray && !dec.More() {
+			return io.EOF
+		}
+		return dec.Decode(rec)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	w := &Store{db: s.db, dialect: s.dialect, stmts: s.stmts, tx: tx}
+
+	var targets, agents int
+	if err := w.queryRow(ctx, `SELECT (SELECT COUNT(*) FROM targets), (SELECT COUNT(*) FROM agents)`).Scan(&targets, &agents); err != nil {
+		return nil, err
+	}
+	if targets > 0 || agents > 0 {
+		return nil, ErrImportNotEmpty
+	}
+
+	var (
+		projects   = map[int64]int64{}
+		targetIDs  = map[int64]TargetRow{}
+		agentIDs   = map[int64]int64{}
+		res        = ImportResult{}
+		sawHeader  bool
+		sawEnd     bool
+		records    int
+		recordType string
+	)
+	target := func(id int64) (TargetRow, error) {
+		t, ok := targetIDs[id]
+		if !ok {
+			return t, fmt.Errorf("%s for unknown target %d", recordType, id)
+		}
+		return t, nil
+	}
+
+	for line := 1; ; line++ {
+		var rec ExportRecord
+		if err := next(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+		recordType = rec.Type
+		if !sawHeader && rec.Type != "header" {
+			return nil, errors.New("not an export: the first record must be the header")
+		}
+		if sawEnd {
+			return nil, fmt.Errorf("record %d: after the end record", line)
+		}
+		err := func() error {
+			switch rec.Type {
+			case "header":
+				var h exportHeader
+				if err := json.Unmarshal(rec.Data, &h); err != nil {
+					return err
+				}
+				if h.Format != ExportFormat || h.Version != ExportVersion {
+					return fmt.Errorf("unsupported export %s version %d", h.Format, h.Version)
+				}
+				sawHeader = true
+				return nil
+
+			case "end":
+				var e exportEnd
+				if err := json.Unmarshal(rec.Data, &e); err != nil {
+					return err
+				}
+				if e.Records != records {
+					return fmt.Errorf("end record counts %d records, read %d", e.Records, records)
+				}
+				sawEnd = true
+				return nil
+
+			case "project":
+				var p exportProject
+				if err := json.Unmarshal(rec.Data, &p); err != nil {
+					return err
+				}
+				var id int64
+				err := w.queryRow(ctx, `SELECT id FROM projects WHERE name=?`, p.Name).Scan(&id)
+				if err == sql.ErrNoRows {
+					id, err = w.insert(ctx, `INSERT INTO projects(name,created_at) VALUES(?,?)`, p.Name, p.CreatedAt)
+				}
+				if err != nil {
+					return err
+				}
+				projects[p.ID] = id
+				return nil
+
+			case "target":
+				var t TargetRow
+				if err := json.Unmarshal(rec.Data, &t); err != nil {
+					return err
+				}
+				pid, ok := projects[t.ProjectID]
+				if !ok {
+					return fmt.Errorf("target %d in unknown project %d", t.ID, t.ProjectID)
+				}
+				old := t.ID
+				t.ProjectID = pid
+				id, err := w.InsertTarget(ctx, t)
+				if err != nil {
+					return err
+				}
+				t.ID = id
+				targetIDs[old] = t
+				return nil
+
+			case "pause":
+				var p exportPause
+				if err := json.Unmarshal(rec.Data, &p); err != nil {
+					return err
+				}
+				t, err := target(p.TargetID)
+				if err != nil {
+					return err
+				}
+				_, err = w.exec(ctx, `INSERT INTO target_pauses(project_id,target_id,started_at,ended_at) VALUES(?,?,?,?)`,
+					t.ProjectID, t.ID, p.StartedAt, p.EndedAt)
+				return err
+
+			case "agent":
+				var a exportAgent
+				if err := json.Unmarshal(rec.Data, &a); err != nil {
+					return err
+				}
+				pid, ok := projects[a.ProjectID]
+				if !ok {
+					return fmt.Errorf("agent %d in unknown project %d", a.ID, a.ProjectID)
+				}
+				key := make([]byte, 32)
+				_, _ = rand.Read(key)
+				id, err := w.insert(ctx,
+					`INSERT INTO agents(project_id,name,key_hash,key_prefix,created_at,labels,revoked_at) VALUES(?,?,?,?,?,?,?)`,
+					pid, a.Name, hashKey(hex.EncodeToString(key)), "", a.CreatedAt, jsonText(a.Labels), a.RevokedAt)
+				if err != nil {
+					return err
+				}
+				agentIDs[a.ID] = id
+				return nil
+
+			case "check":
+				var c exportCheck
+				if err := json.Unmarshal(rec.Data, &c); err != nil {
+					return err
+				}
+				t, err := target(c.TargetID)
+				if err != nil {
+					return err
+				}
+				var agent any
+				if c.AgentID != nil {
+					if id, ok := agentIDs[*c.AgentID]; ok {
+						agent = id
+					}
+				}
+				tm := c.Timings
+				if tm == nil {
+					tm = &Timings{}
+				}
+				_, err = w.exec(ctx,
+					`INSERT INTO checks(project_id,target_id,agent_id,ts,status_code,ok,latency_ms,error,dns_ms,connect_ms,tls_ms,ttfb_ms,transfer_ms)
+					 VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+					t.ProjectID, t.ID, agent, c.TS, c.StatusCode, btoi(c.OK), c.LatencyMs, c.Error,
+					tm.DNSMs, tm.ConnectMs, tm.TLSMs, tm.TTFBMs, tm.TransferMs)
+				return err
+
+			case "outage":
+				var o exportOutage
+				if err := json.Unmarshal(rec.Data, &o); err != nil {
+					return err
+				}
+				t, err := target(o.TargetID)
+				if err != nil {
+					return err
+				}
+				_, err = w.exec(ctx, `INSERT INTO outages(project_id,target_id,started_at,ended_at,reason) VALUES(?,?,?,?,?)`,
+					t.ProjectID, t.ID, o.StartedAt, o.EndedAt, o.Reason)
+				return err
+
+			case "log":
+				var l exportLog
+				if err := json.Unmarshal(rec.Data, &l); err != nil {
+					return err
+				}
+				t, err := target(l.TargetID)
+				if err != nil {
+					return err
+				}
+				return w.InsertCheckLog(ctx, t.ProjectID, t.ID, nil, l.TS, l.Level, l.Line)
+			}
+			return fmt.Errorf("unknown record type %q", rec.Type)
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+		if rec.Type != "header" && rec.Type != "end" {
+			res[rec.Type]++
+			records++
+		}
+	}
+	if !sawHeader {
+		return nil, errors.New("not an export: no header")
+	}
+	if !sawEnd {
+		return nil, errors.New("the export is incomplete: no end record")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
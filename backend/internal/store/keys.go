@@ -0,0 +1,76 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Agent keys and user API tokens are stored as a salted SHA-256 plus a
+// short prefix used to find candidate rows. They are 256-bit random values,
+// so a fast hash is enough; the salt keeps equal keys from producing equal
+// hashes.
+//
+// Stored form: sha256$<salt hex>$<hash hex>
+
+const (
+	keyHashScheme = "sha256"
+	KeyPrefixLen  = 8
+)
+
+// KeyPrefix is the non-secret part of a key kept in clear for lookup and
+// for telling keys apart in listings. Short legacy keys only give up half
+// their characters, so the prefix never is the key.
+func KeyPrefix(key string) string {
+	return key[:min(KeyPrefixLen, len(key)/2)]
+}
+
+func hashKey(key string) string {
+	salt := make([]byte, 16)
+	_, _ = rand.Read(salt)
+	return keyHashScheme + "$" + hex.EncodeToString(salt) + "$" + hex.EncodeToString(saltedSum(salt, key))
+}
+
+func saltedSum(salt []byte, key string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(key))
+	return h.Sum(nil)
+}
+
+func isHashedKey(stored string) bool { return strings.HasPrefix(stored, keyHashScheme+"$") }
+
+// keyMatches reports whether key hashes to stored, in constant time.
+func keyMatches(key, stored string) bool {
+	parts := strings.Split(stored, "$")
+	if len(parts) != 3 || parts[0] != keyHashScheme {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(saltedSum(salt, key), want) == 1
+}
+
+// Passwords are chosen by people, so they get a slow hash instead.
+
+func hashPassword(pw string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	return string(b), err
+}
+
+func passwordMatches(pw, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw)) == nil
+}
+
+// dummyPasswordHash is compared against when a username doesn't exist.
+var dummyPasswordHash, _ = hashPassword("not-a-real-password")
@@ -0,0 +1,491 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A migration moves the schema from version-1 to version. Migrations are
+// append-only: once released, a migration is never edited, and later
+// changes get a new number.
+type migration struct {
+	version int
+	name    string
+	// up runs inside the migration's transaction
+	sqlite, postgres func(ctx context.Context, tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "baseline", sqliteBaseline, postgresBaseline},
+	{2, "check_and_outage_indexes", hotIndexes, hotIndexes},
+}
+
+// MigrationState is one known migration, or an applied one this binary
+// doesn't know about. AppliedAt is nil while it is pending.
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+// SchemaVersion is the newest migration this binary knows.
+func SchemaVersion() int { return migrations[len(migrations)-1].version }
+
+func (s *Store) schemaVersionTable(ctx context.Context) error {
+	ts := "TIMESTAMP"
+	if s.dialect == postgres {
+		ts = "TIMESTAMPTZ"
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at `+ts+` NOT NULL
+	)`)
+	return err
+}
+
+// MigrationStatus lists every known migration with when it was applied,
+// followed by any applied migrations from a newer binary. It doesn't
+// change the database.
+func (s *Store) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	q := `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='schema_version'`
+	if s.dialect == postgres {
+		q = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema=current_schema() AND table_name='schema_version'`
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, q).Scan(&n); err != nil {
+		return nil, err
+	}
+	applied := map[int]MigrationState{}
+	if n > 0 {
+		rows, err := s.db.QueryContext(ctx, `SELECT version,name,applied_at FROM schema_version ORDER BY version`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var m MigrationState
+			var at time.Time
+			if err := rows.Scan(&m.Version, &m.Name, &at); err != nil {
+				return nil, err
+			}
+			at = at.UTC()
+			m.AppliedAt = &at
+			applied[m.Version] = m
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationState{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			st.AppliedAt = a.AppliedAt
+			delete(applied, m.version)
+		}
+		out = append(out, st)
+	}
+	unknown := make([]MigrationState, 0, len(applied))
+	for _, a := range applied {
+		a.Unknown = true
+		unknown = append(unknown, a)
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(out, unknown...), nil
+}
+
+// Pending counts migrations that haven't been applied yet.
+func Pending(states []MigrationState) int {
+	n := 0
+	for _, m := range states {
+		if m.AppliedAt == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Migrate applies pending migrations in order, each in its own
+// transaction together with its schema_version row, and returns the ones
+// it applied. It refuses to touch a database migrated by a newer binary.
+func (s *Store) Migrate(ctx context.Context) ([]MigrationState, error) {
+	if err := s.schemaVersionTable(ctx); err != nil {
+		return nil, err
+	}
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version),0) FROM schema_version`).Scan(&current); err != nil {
+		return nil, err
+	}
+	if current > SchemaVersion() {
+		return nil, fmt.Errorf("database schema is at version %d, newer than this binary's %d", current, SchemaVersion())
+	}
+	var done []MigrationState
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		at, err := s.apply(ctx, m)
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if at != nil {
+			done = append(done, MigrationState{Version: m.version, Name: m.name, AppliedAt: at})
+		}
+	}
+	return done, nil
+}
+
+// migrationLock serializes servers migrating the same PostgreSQL database.
+const migrationLock = 7264031
+
+// apply runs one migration unless another process got there first, and
+// returns when it was applied.
+func (s *Store) apply(ctx context.Context, m migration) (*time.Time, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	up := m.sqlite
+	if s.dialect == postgres {
+		up = m.postgres
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+			return nil, err
+		}
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM schema_version WHERE version=?`), m.version).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, nil
+	}
+	if err := up(ctx, tx); err != nil {
+		return nil, err
+	}
+	at := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO schema_version(version,name,applied_at) VALUES(?,?,?)`),
+		m.version, m.name, at); err != nil {
+		return nil, err
+	}
+	return &at, tx.Commit()
+}
+
+// hotIndexes is migration 2: per-target lookups of checks by time and of
+// open outages scanned the whole table. TestQueryPlans verifies SQLite
+// uses them.
+func hotIndexes(ctx context.Context, tx *sql.Tx) error {
+	for _, q := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_checks_target_ts ON checks(target_id, ts)`,
+		`CREATE INDEX IF NOT EXISTS idx_outages_target_ended ON outages(target_id, ended_at)`,
+	} {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteBaseline is migration 1 on SQLite. Databases from before
+// versioned migrations have no schema_version table and may stop anywhere
+// in the schema's history, so every step checks before it changes
+// anything; on a new database it simply creates the current schema.
+func sqliteBaseline(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS targets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			timeout_ms INTEGER DEFAULT 4000,
+			created_at TIMESTAMP NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS checks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target_id INTEGER NOT NULL,
+			ts TIMESTAMP NOT NULL,
+			status_code INTEGER NOT NULL,
+			ok INTEGER NOT NULL,
+			latency_ms INTEGER NOT NULL,
+			error TEXT NOT NULL DEFAULT ''
+		);`,
+		`CREATE TABLE IF NOT EXISTS outages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target_id INTEGER NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP,
+			reason TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS agents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP NOT NULL
+		);`,
+		// NEW: logs table
+		`CREATE TABLE IF NOT EXISTS logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			target_id INTEGER NOT NULL,
+			check_id INTEGER,
+			ts TIMESTAMP NOT NULL,
+			level TEXT NOT NULL,
+			line TEXT NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_target_ts ON logs(target_id, ts DESC);`,
+		// periods where an agent stopped heartbeating
+		`CREATE TABLE IF NOT EXISTS agent_outages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			agent_id INTEGER NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			disabled_at TIMESTAMP
+		);`,
+		// bearer tokens: login sessions and long-lived tokens for scripts
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			token_prefix TEXT NOT NULL,
+			token_hash TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP,
+			session INTEGER NOT NULL DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_api_tokens_prefix ON api_tokens(token_prefix);`,
+		// tenants; every target, agent, check, outage and log belongs to one
+		`CREATE TABLE IF NOT EXISTS projects (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS project_members (
+			project_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			PRIMARY KEY (project_id, user_id)
+		);`,
+		// periods a target was paused; excluded from availability
+		`CREATE TABLE IF NOT EXISTS target_pauses (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_id INTEGER NOT NULL,
+			target_id INTEGER NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP
+		);`,
+		// declarative config: everything below is addressed by a stable,
+		// user-chosen key that is unique within its project
+		`CREATE TABLE IF NOT EXISTS target_groups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			UNIQUE (project_id, key)
+		);`,
+		`CREATE TABLE IF NOT EXISTS alert_routes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			webhook_url TEXT NOT NULL,
+			match TEXT NOT NULL DEFAULT '',
+			UNIQUE (project_id, key)
+		);`,
+		`CREATE TABLE IF NOT EXISTS maintenance_windows (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			starts_at TIMESTAMP NOT NULL,
+			ends_at TIMESTAMP NOT NULL,
+			match TEXT NOT NULL DEFAULT '',
+			UNIQUE (project_id, key)
+		);`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+
+	// agent keys used to be stored in clear in api_key/prev_api_key
+	renames := []struct{ table, from, to string }{
+		{"agents", "api_key", "key_hash"},
+		{"agents", "prev_api_key", "prev_key_hash"},
+	}
+	for _, rn := range renames {
+		if err := renameColumn(ctx, tx, rn.table, rn.from, rn.to); err != nil {
+			return err
+		}
+	}
+
+	// data from before projects existed goes to the default project, and
+	// existing users keep access to it
+	hadProjects, err := hasColumn(ctx, tx, "api_tokens", "project_id")
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO projects(id,name,created_at) VALUES(?,?,?)`,
+		DefaultProjectID, "default", time.Now().UTC()); err != nil {
+		return err
+	}
+
+	// columns added after the initial schema; CREATE TABLE IF NOT EXISTS
+	// won't touch tables that already exist.
+	cols := []struct{ table, name, def string }{
+		{"targets", "type", `TEXT NOT NULL DEFAULT 'http'`},
+		{"targets", "grpc_service", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "grpc_tls", `INTEGER NOT NULL DEFAULT 0`},
+		{"targets", "grpc_tls_skip_verify", `INTEGER NOT NULL DEFAULT 0`},
+		{"targets", "assertions", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "method", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "headers", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "body", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "auth", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "steps", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "interval_sec", `INTEGER NOT NULL DEFAULT 0`},
+		{"targets", "fresh_connection", `INTEGER`},
+		{"checks", "dns_ms", `INTEGER`},
+		{"checks", "connect_ms", `INTEGER`},
+		{"checks", "tls_ms", `INTEGER`},
+		{"checks", "ttfb_ms", `INTEGER`},
+		{"checks", "transfer_ms", `INTEGER`},
+		{"checks", "agent_id", `INTEGER`},
+		{"agents", "last_seen_at", `TIMESTAMP`},
+		{"agents", "version", `TEXT NOT NULL DEFAULT ''`},
+		{"agents", "hostname", `TEXT NOT NULL DEFAULT ''`},
+		{"agents", "uptime_sec", `INTEGER NOT NULL DEFAULT 0`},
+		{"agents", "target_count", `INTEGER NOT NULL DEFAULT 0`},
+		{"agents", "spool_depth", `INTEGER NOT NULL DEFAULT 0`},
+		{"agents", "labels", `TEXT NOT NULL DEFAULT ''`},
+		{"agents", "revoked_at", `TIMESTAMP`},
+		{"agents", "prev_key_hash", `TEXT NOT NULL DEFAULT ''`},
+		{"agents", "key_prefix", `TEXT NOT NULL DEFAULT ''`},
+		{"agents", "prev_key_prefix", `TEXT NOT NULL DEFAULT ''`},
+		{"agents", "prev_key_expires_at", `TIMESTAMP`},
+		{"targets", "project_id", `INTEGER NOT NULL DEFAULT 1`},
+		{"agents", "project_id", `INTEGER NOT NULL DEFAULT 1`},
+		{"checks", "project_id", `INTEGER NOT NULL DEFAULT 1`},
+		{"outages", "project_id", `INTEGER NOT NULL DEFAULT 1`},
+		{"logs", "project_id", `INTEGER NOT NULL DEFAULT 1`},
+		{"api_tokens", "project_id", `INTEGER NOT NULL DEFAULT 1`},
+		{"api_tokens", "session", `INTEGER NOT NULL DEFAULT 0`},
+		{"targets", "description", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "tags", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "paused", `INTEGER NOT NULL DEFAULT 0`},
+		{"targets", "key", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "group_key", `TEXT NOT NULL DEFAULT ''`},
+		{"targets", "agent_selector", `TEXT NOT NULL DEFAULT ''`},
+	}
+	for _, col := range cols {
+		if err := ensureColumn(ctx, tx, col.table, col.name, col.def); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_agents_key_prefix ON agents(key_prefix)`); err != nil {
+		return err
+	}
+	// targets created through the API have no key
+	if _, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_targets_key ON targets(project_id, key) WHERE key != ''`); err != nil {
+		return err
+	}
+	if !hadProjects {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO project_members(project_id,user_id) SELECT ?,id FROM users`,
+			DefaultProjectID); err != nil {
+			return err
+		}
+	}
+	return hashPlaintextKeys(ctx, tx)
+}
+
+func hasColumn(ctx context.Context, tx *sql.Tx, table, name string) (bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return false, err
+		}
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func ensureColumn(ctx context.Context, tx *sql.Tx, table, name, def string) error {
+	ok, err := hasColumn(ctx, tx, table, name)
+	if err != nil || ok {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+name+` `+def)
+	return err
+}
+
+func renameColumn(ctx context.Context, tx *sql.Tx, table, from, to string) error {
+	ok, err := hasColumn(ctx, tx, table, from)
+	if err != nil || !ok {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `ALTER TABLE `+table+` RENAME COLUMN `+from+` TO `+to)
+	return err
+}
+
+// hashPlaintextKeys replaces keys left in clear by older versions with
+// their salted hash and prefix. Agents keep using the same keys.
+func hashPlaintextKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id,key_hash,prev_key_hash FROM agents`)
+	if err != nil {
+		return err
+	}
+	type legacy struct {
+		id        int64
+		key, prev string
+	}
+	var todo []legacy
+	for rows.Next() {
+		var l legacy
+		if err := rows.Scan(&l.id, &l.key, &l.prev); err != nil {
+			rows.Close()
+			return err
+		}
+		if !isHashedKey(l.key) || (l.prev != "" && !isHashedKey(l.prev)) {
+			todo = append(todo, l)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range todo {
+		if !isHashedKey(l.key) {
+			if _, err := tx.ExecContext(ctx, `UPDATE agents SET key_hash=?,key_prefix=? WHERE id=?`,
+				hashKey(l.key), KeyPrefix(l.key), l.id); err != nil {
+				return err
+			}
+		}
+		if l.prev != "" && !isHashedKey(l.prev) {
+			if _, err := tx.ExecContext(ctx, `UPDATE agents SET prev_key_hash=?,prev_key_prefix=? WHERE id=?`,
+				hashKey(l.prev), KeyPrefix(l.prev), l.id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
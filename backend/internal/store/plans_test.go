@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hotQueries run for every ingested check or every metrics request, so a
+// full table scan in any of them grows with the history kept.
+var hotQueries = []struct{ name, sql, index string }{
+	{"LastCheck", lastCheckSQL, "idx_checks_target_ts"},
+	{"GetRecentChecks", recentChecksSQL, "idx_checks_target_ts"},
+	{"CountChecksAgg", countChecksSQL, "idx_checks_target_ts"},
+	{"AvgLatencyOK", avgLatencySQL, "idx_checks_target_ts"},
+	{"AvgPhasesOK", avgPhasesSQL, "idx_checks_target_ts"},
+	{"FailuresByReason", failuresByReasonSQL, "idx_checks_target_ts"},
+	{"GetOpenOutage", openOutageSQL, "idx_outages_target_ended"},
+	{"ListOutagesOverlapping", outagesOverlappingSQL, "idx_outages_target_ended"},
+}
+
+// TestQueryPlans fails when SQLite doesn't plan a hot query as a search of
+// its index. Only SQLite is checked: PostgreSQL's planner picks sequential
+// scans for small tables, so its plans say little about production.
+func TestQueryPlans(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "status.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, hq := range hotQueries {
+		t.Run(hq.name, func(t *testing.T) {
+			steps, err := queryPlan(context.Background(), s, hq.sql)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, detail := range steps {
+				if strings.HasPrefix(detail, "SEARCH ") && strings.Contains(detail, " INDEX "+hq.index+" ") {
+					return
+				}
+			}
+			t.Errorf("doesn't search %s: %s", hq.index, strings.Join(steps, "; "))
+		})
+	}
+}
+
+// queryPlan returns the detail of each step of EXPLAIN QUERY PLAN.
+func queryPlan(ctx context.Context, s *Store, query string) ([]string, error) {
+	// the plan doesn't depend on the values
+	args := make([]any, strings.Count(query, "?"))
+	for i := range args {
+		args[i] = 1
+	}
+	rows, err := s.db.QueryContext(ctx, `EXPLAIN QUERY PLAN `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var steps []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, err
+		}
+		steps = append(steps, detail)
+	}
+	return steps, rows.Err()
+}
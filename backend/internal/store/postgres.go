@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// dialect is the database a Store talks to.
+type dialect int
+
+const (
+	sqlite dialect = iota
+	postgres
+)
+
+// rebind turns ? placeholders into $1, $2, ... for PostgreSQL. Queries
+// here never contain a ? inside a string literal.
+func (d dialect) rebind(q string) string {
+	if d != postgres || !strings.Contains(q, "?") {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// IsUniqueViolation reports whether err comes from inserting or updating
+// a row that clashes with a UNIQUE constraint, in either database.
+func IsUniqueViolation(err error) bool {
+	var pe *pq.Error
+	if errors.As(err, &pe) {
+		return pe.Code == "23505"
+	}
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// postgresBaseline is migration 1 on PostgreSQL: the schema sqliteBaseline
+// builds up, created in one go since there are no older PostgreSQL
+// databases to upgrade. Booleans stay integers so both databases share
+// the queries.
+func postgresBaseline(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS projects (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS targets (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL DEFAULT 1,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'http',
+			url TEXT NOT NULL,
+			timeout_ms INTEGER NOT NULL DEFAULT 4000,
+			created_at TIMESTAMPTZ NOT NULL,
+			grpc_service TEXT NOT NULL DEFAULT '',
+			grpc_tls INTEGER NOT NULL DEFAULT 0,
+			grpc_tls_skip_verify INTEGER NOT NULL DEFAULT 0,
+			assertions TEXT NOT NULL DEFAULT '',
+			method TEXT NOT NULL DEFAULT '',
+			headers TEXT NOT NULL DEFAULT '',
+			body TEXT NOT NULL DEFAULT '',
+			auth TEXT NOT NULL DEFAULT '',
+			steps TEXT NOT NULL DEFAULT '',
+			interval_sec INTEGER NOT NULL DEFAULT 0,
+			fresh_connection INTEGER,
+			description TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '',
+			paused INTEGER NOT NULL DEFAULT 0,
+			key TEXT NOT NULL DEFAULT '',
+			group_key TEXT NOT NULL DEFAULT '',
+			agent_selector TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_targets_key ON targets(project_id, key) WHERE key != ''`,
+		`CREATE TABLE IF NOT EXISTS checks (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL DEFAULT 1,
+			target_id BIGINT NOT NULL,
+			agent_id BIGINT,
+			ts TIMESTAMPTZ NOT NULL,
+			status_code INTEGER NOT NULL,
+			ok INTEGER NOT NULL,
+			latency_ms INTEGER NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			dns_ms INTEGER,
+			connect_ms INTEGER,
+			tls_ms INTEGER,
+			ttfb_ms INTEGER,
+			transfer_ms INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS outages (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL DEFAULT 1,
+			target_id BIGINT NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			ended_at TIMESTAMPTZ,
+			reason TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS agents (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL DEFAULT 1,
+			name TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			key_prefix TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL,
+			labels TEXT NOT NULL DEFAULT '',
+			revoked_at TIMESTAMPTZ,
+			prev_key_hash TEXT NOT NULL DEFAULT '',
+			prev_key_prefix TEXT NOT NULL DEFAULT '',
+			prev_key_expires_at TIMESTAMPTZ,
+			last_seen_at TIMESTAMPTZ,
+			version TEXT NOT NULL DEFAULT '',
+			hostname TEXT NOT NULL DEFAULT '',
+			uptime_sec BIGINT NOT NULL DEFAULT 0,
+			target_count INTEGER NOT NULL DEFAULT 0,
+			spool_depth INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_agents_key_prefix ON agents(key_prefix)`,
+		`CREATE TABLE IF NOT EXISTS logs (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL DEFAULT 1,
+			target_id BIGINT NOT NULL,
+			check_id BIGINT,
+			ts TIMESTAMPTZ NOT NULL,
+			level TEXT NOT NULL,
+			line TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_target_ts ON logs(target_id, ts DESC)`,
+		`CREATE TABLE IF NOT EXISTS agent_outages (
+			id BIGSERIAL PRIMARY KEY,
+			agent_id BIGINT NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			ended_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS users (
+			id BIGSERIAL PRIMARY KEY,
+			username TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			disabled_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			project_id BIGINT NOT NULL DEFAULT 1,
+			name TEXT NOT NULL,
+			token_prefix TEXT NOT NULL,
+			token_hash TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			expires_at TIMESTAMPTZ,
+			session INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_tokens_prefix ON api_tokens(token_prefix)`,
+		`CREATE TABLE IF NOT EXISTS project_members (
+			project_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			PRIMARY KEY (project_id, user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS target_pauses (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL,
+			target_id BIGINT NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			ended_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS target_groups (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL,
+			key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			UNIQUE (project_id, key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS alert_routes (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL,
+			key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			webhook_url TEXT NOT NULL,
+			match TEXT NOT NULL DEFAULT '',
+			UNIQUE (project_id, key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS maintenance_windows (
+			id BIGSERIAL PRIMARY KEY,
+			project_id BIGINT NOT NULL,
+			key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			starts_at TIMESTAMPTZ NOT NULL,
+			ends_at TIMESTAMPTZ NOT NULL,
+			match TEXT NOT NULL DEFAULT '',
+			UNIQUE (project_id, key)
+		)`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	// the default project has a fixed id, so move the sequence past it
+	if _, err := tx.ExecContext(ctx, `INSERT INTO projects(id,name,created_at) VALUES($1,$2,$3) ON CONFLICT DO NOTHING`,
+		DefaultProjectID, "default", time.Now().UTC()); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `SELECT setval(pg_get_serial_sequence('projects','id'), (SELECT MAX(id) FROM projects))`)
+	return err
+}
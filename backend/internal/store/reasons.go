@@ -0,0 +1,54 @@
+package store
+
+import "strings"
+
+// failure reason categories
+const (
+	CategoryTimeout   = "timeout"
+	CategoryDNS       = "dns"
+	CategoryNetwork   = "network"
+	CategoryTLS       = "tls"
+	CategoryHTTP      = "http"
+	CategoryAssertion = "assertion"
+	CategoryGRPC      = "grpc"
+	CategoryConfig    = "config"
+	CategoryOther     = "other"
+)
+
+// reasonCategories lists the reasons agents report (cmd/agent/classify.go).
+var reasonCategories = map[string]string{
+	"timeout":               CategoryTimeout,
+	"canceled":              CategoryOther,
+	"dns_not_found":         CategoryDNS,
+	"dns_timeout":           CategoryDNS,
+	"dns_error":             CategoryDNS,
+	"connection_refused":    CategoryNetwork,
+	"connection_reset":      CategoryNetwork,
+	"connection_closed":     CategoryNetwork,
+	"network_unreachable":   CategoryNetwork,
+	"host_unreachable":      CategoryNetwork,
+	"network_error":         CategoryNetwork,
+	"tls_expired":           CategoryTLS,
+	"tls_hostname_mismatch": CategoryTLS,
+	"tls_unknown_authority": CategoryTLS,
+	"tls_invalid_cert":      CategoryTLS,
+	"tls_error":             CategoryTLS,
+	"too_many_redirects":    CategoryHTTP,
+	"non_2xx":               CategoryHTTP,
+	"assertion_failed":      CategoryAssertion,
+	"extract_failed":        CategoryAssertion,
+	"config_error":          CategoryConfig,
+	"unknown_error":         CategoryOther,
+}
+
+// ReasonCategory groups a failure reason; unrecognised reasons (e.g. from
+// newer agents) fall back to "other" but are still stored verbatim.
+func ReasonCategory(reason string) string {
+	if c, ok := reasonCategories[reason]; ok {
+		return c
+	}
+	if strings.HasPrefix(reason, "grpc_") {
+		return CategoryGRPC
+	}
+	return CategoryOther
+}
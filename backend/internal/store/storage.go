@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"time"
+)
+
+// Storage is everything the server keeps. Store implements it for SQLite
+// and PostgreSQL; TestConformance checks an implementation behaves the way
+// the handlers expect.
+//
+// Lookups of a single row return nil, not an error, when there is none.
+// Methods taking a projectID only see rows of that project.
+type Storage interface {
+	Close() error
+	WithBatch(ctx context.Context, fn func(Batch) error) error
+	// WithTx runs fn with a Storage whose writes all commit together when
+	// fn returns nil, or not at all.
+	WithTx(ctx context.Context, fn func(Storage) error) error
+
+	// targets
+	InsertTarget(ctx context.Context, t TargetRow) (int64, error)
+	UpdateTarget(ctx context.Context, t TargetRow) error
+	FindTargetByName(ctx context.Context, projectID int64, name string) (*TargetRow, error)
+	ListTargets(ctx context.Context, projectID int64) ([]TargetRow, error)
+	GetTarget(ctx context.Context, projectID, id int64) (*TargetRow, error)
+	DeleteTarget(ctx context.Context, projectID, id int64) error
+	PauseTarget(ctx context.Context, projectID, id int64, at time.Time) (bool, error)
+	ResumeTarget(ctx context.Context, projectID, id int64, at time.Time) (bool, error)
+	ListPausesOverlapping(ctx context.Context, projectID, targetID int64, from, to time.Time) ([]PauseRow, error)
+
+	// checks and metrics
+	InsertCheck(ctx context.Context, projectID, targetID, agentID int64, ts time.Time, status int, ok bool, latencyMs int, reason string, tm *Timings) error
+	LastCheck(ctx context.Context, projectID, targetID int64) (ts time.Time, agentID sql.NullInt64, ok bool, err error)
+	GetRecentChecks(ctx context.Context, projectID, targetID int64, limit int) ([]CheckRow, error)
+	CountChecksAgg(ctx context.Context, projectID, targetID int64, from, to time.Time) (total, success int64, err error)
+	AvgLatencyOK(ctx context.Context, projectID, targetID int64, from, to time.Time) (sql.NullFloat64, error)
+	AvgPhasesOK(ctx context.Context, projectID, targetID int64, from, to time.Time) (PhaseAvg, error)
+	FailuresByReason(ctx context.Context, projectID, targetID int64, from, to time.Time) ([]ReasonCount, error)
+
+	// outages
+	GetOpenOutage(ctx context.Context, projectID, targetID int64) (*OutageRow, error)
+	OpenOutage(ctx context.Context, projectID, targetID int64, startedAt time.Time, reason string) error
+	CloseOutage(ctx context.Context, id int64, endedAt time.Time) error
+	ListOutagesOverlapping(ctx context.Context, projectID, targetID int64, from, to time.Time) ([]OutageRow, error)
+	ListOutages(ctx context.Context, projectID int64, f OutageFilter) ([]OutageRow, error)
+
+	// agents
+	CreateAgent(ctx context.Context, projectID int64, name, apiKey string) (int64, error)
+	FindAgentByKey(ctx context.Context, key string) (*AgentRow, error)
+	GetAgent(ctx context.Context, projectID, id int64) (*AgentRow, error)
+	UpdateAgent(ctx context.Context, projectID, id int64, name string, labels map[string]string) error
+	RevokeAgent(ctx context.Context, projectID, id int64, at time.Time) error
+	RotateAgentKey(ctx context.Context, projectID, id int64, newKey string, overlapUntil time.Time) error
+	ListAgents(ctx context.Context, projectID int64) ([]AgentRow, error)
+	ListAllAgents(ctx context.Context) ([]AgentRow, error)
+	RecordHeartbeat(ctx context.Context, agentID int64, hb Heartbeat, at time.Time) error
+	TouchAgent(ctx context.Context, agentID int64, at time.Time) error
+	GetOpenAgentOutage(ctx context.Context, agentID int64) (*AgentOutageRow, error)
+	OpenAgentOutage(ctx context.Context, agentID int64, startedAt time.Time) error
+	CloseAgentOutage(ctx context.Context, id int64, endedAt time.Time) error
+	ListAgentOutagesOverlapping(ctx context.Context, agentID int64, from, to time.Time) ([]AgentOutageRow, error)
+
+	// users and tokens
+	CountUsers(ctx context.Context) (int64, error)
+	CreateUser(ctx context.Context, username, password, role string) (int64, error)
+	GetUser(ctx context.Context, id int64) (*UserRow, error)
+	ListUsers(ctx context.Context) ([]UserRow, error)
+	AuthenticateUser(ctx context.Context, username, password string) (*UserRow, error)
+	UpdateUser(ctx context.Context, id int64, role string, disabled bool, password string) error
+	DeleteUser(ctx context.Context, id int64) error
+	CreateToken(ctx context.Context, userID, projectID int64, name, token string, expiresAt *time.Time) (int64, error)
+	CreateSession(ctx context.Context, userID, projectID int64, token string, expiresAt time.Time) (int64, error)
+	FindUserByToken(ctx context.Context, token string) (*UserRow, *TokenRow, error)
+	SetTokenProject(ctx context.Context, tokenID, projectID int64) error
+	ListTokens(ctx context.Context, userID int64) ([]TokenRow, error)
+	DeleteToken(ctx context.Context, userID, id int64) (bool, error)
+
+	// projects
+	CreateProject(ctx context.Context, name string) (int64, error)
+	GetProject(ctx context.Context, id int64) (*ProjectRow, error)
+	ListProjects(ctx context.Context) ([]ProjectRow, error)
+	ListUserProjects(ctx context.Context, userID int64) ([]ProjectRow, error)
+	RenameProject(ctx context.Context, id int64, name string) error
+	ProjectInUse(ctx context.Context, id int64) (bool, error)
+	DeleteProject(ctx context.Context, id int64) error
+	AddProjectMember(ctx context.Context, projectID, userID int64) error
+	RemoveProjectMember(ctx context.Context, projectID, userID int64) error
+	ListProjectMembers(ctx context.Context, projectID int64) ([]UserRow, error)
+
+	// declarative config
+	ListGroups(ctx context.Context, projectID int64) ([]GroupRow, error)
+	GroupExists(ctx context.Context, projectID int64, key string) (bool, error)
+	InsertGroup(ctx context.Context, g GroupRow) error
+	UpdateGroup(ctx context.Context, g GroupRow) error
+	DeleteGroup(ctx context.Context, projectID int64, key string) error
+	ListAlertRoutes(ctx context.Context, projectID int64) ([]AlertRouteRow, error)
+	InsertAlertRoute(ctx context.Context, r AlertRouteRow) error
+	UpdateAlertRoute(ctx context.Context, r AlertRouteRow) error
+	DeleteAlertRoute(ctx context.Context, projectID int64, key string) error
+	ListMaintenanceWindows(ctx context.Context, projectID int64) ([]MaintenanceWindowRow, error)
+	ActiveMaintenanceWindows(ctx context.Context, projectID int64, t time.Time) ([]MaintenanceWindowRow, error)
+	InsertMaintenanceWindow(ctx context.Context, w MaintenanceWindowRow) error
+	UpdateMaintenanceWindow(ctx context.Context, w MaintenanceWindowRow) error
+	DeleteMaintenanceWindow(ctx context.Context, projectID int64, key string) error
+
+	// backup and export
+	Backup(ctx context.Context, path string) error
+	Export(ctx context.Context, w io.Writer, asArray bool) error
+	Import(ctx context.Context, r io.Reader) (ImportResult, error)
+
+	// logs
+	InsertCheckLog(ctx context.Context, projectID, targetID int64, checkID *int64, ts time.Time, level, line string) error
+	ListLogs(ctx context.Context, projectID, targetID int64, limit int, before *time.Time) ([]LogRow, error)
+}
+
+var _ Storage = (*Store)(nil)
+
+// Batch is the part of Storage an ingest batch uses, run inside one
+// transaction by WithBatch. Reads that don't need the transaction belong
+// before it: on SQLite it holds the write lock from the start.
+type Batch interface {
+	// Savepoint runs fn so that when it fails only fn's writes are undone
+	// and the batch can go on.
+	Savepoint(ctx context.Context, fn func(Batch) error) error
+	InsertCheck(ctx context.Context, projectID, targetID, agentID int64, ts time.Time, status int, ok bool, latencyMs int, reason string, tm *Timings) error
+	InsertCheckLog(ctx context.Context, projectID, targetID int64, checkID *int64, ts time.Time, level, line string) error
+	GetRecentChecks(ctx context.Context, projectID, targetID int64, limit int) ([]CheckRow, error)
+	GetOpenOutage(ctx context.Context, projectID, targetID int64) (*OutageRow, error)
+	OpenOutage(ctx context.Context, projectID, targetID int64, startedAt time.Time, reason string) error
+	CloseOutage(ctx context.Context, id int64, endedAt time.Time) error
+}
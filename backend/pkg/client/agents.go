@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListAgents returns the project's agents, revoked ones included.
+func (c *Client) ListAgents(ctx context.Context) ([]Agent, error) {
+	var out []Agent
+	return out, c.do(ctx, http.MethodGet, "/api/agents", nil, &out)
+}
+
+func (c *Client) GetAgent(ctx context.Context, id int64) (*Agent, error) {
+	var a Agent
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/agents/%d", id), nil, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RegisterAgent creates an agent and returns its key, which is not shown
+// again.
+func (c *Client) RegisterAgent(ctx context.Context, name string) (*AgentKey, error) {
+	var out AgentKey
+	if err := c.do(ctx, http.MethodPost, "/api/agents/register", map[string]string{"name": name}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeAgent stops both of the agent's keys from working. The agent and
+// its checks are kept.
+func (c *Client) RevokeAgent(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/agents/%d", id), nil, nil)
+}
+
+// RotateAgentKey issues a new key. The old one keeps working for
+// overlapSec seconds; a negative overlapSec uses the server's default.
+func (c *Client) RotateAgentKey(ctx context.Context, id int64, overlapSec int) (*AgentKey, error) {
+	var body any
+	if overlapSec >= 0 {
+		body = map[string]int{"overlap_sec": overlapSec}
+	}
+	var out AgentKey
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/agents/%d/rotate-key", id), body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,226 @@
+// Package client is a Go SDK for the status-probe-lite API. The request and
+// response types are the ones the server's handlers use, so they can't drift
+// apart.
+//
+//	c := client.New("http://localhost:8080", client.WithToken(token))
+//	targets, err := c.ListTargets(ctx, "prod")
+//
+// Custom agents push results with an IngestClient:
+//
+//	ic := client.NewIngestClient("http://localhost:8080", apiKey)
+//	_, err := ic.Push(ctx, []client.Check{...})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the management API with a user API token or session token.
+// It is safe for concurrent use.
+type Client struct {
+	base    string
+	hc      *http.Client
+	headers http.Header
+	retry   Retry
+}
+
+// Retry controls how failed requests are retried. Requests are retried on
+// 429 and 503, and GET, PUT and DELETE also on 502, 504 and network
+// errors. POST and PATCH are retried on network errors only when the
+// connection could not be made, so the server never sees them twice.
+type Retry struct {
+	Max     int           // retries after the first attempt; 0 disables
+	Base    time.Duration // first backoff, doubled each retry, with jitter
+	MaxWait time.Duration // cap on one backoff, and on Retry-After
+}
+
+// DefaultRetry is used unless WithRetry is given.
+var DefaultRetry = Retry{Max: 3, Base: 250 * time.Millisecond, MaxWait: 10 * time.Second}
+
+type Option func(*Client)
+
+// WithToken authenticates with a bearer token (POST /api/tokens).
+func WithToken(token string) Option {
+	return func(c *Client) { c.headers.Set("Authorization", "Bearer "+token) }
+}
+
+// WithHTTPClient replaces the default client, which has a 30s timeout.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.hc = hc }
+}
+
+func WithRetry(r Retry) Option {
+	return func(c *Client) { c.retry = r }
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		base:    strings.TrimRight(baseURL, "/"),
+		hc:      &http.Client{Timeout: 30 * time.Second},
+		headers: http.Header{},
+		retry:   DefaultRetry,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// APIError is a non-2xx response. Fields is set when the request failed
+// validation.
+type APIError struct {
+	StatusCode int
+	Message    string       `json:"error"`
+	Fields     []FieldError `json:"fields,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 from the server.
+func IsNotFound(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode == http.StatusNotFound
+}
+
+// do sends in as JSON and decodes the response into out; either may be nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	resp, err := c.send(ctx, method, path, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send makes the request with retries. On success the caller closes the
+// body; non-2xx responses other than 304 come back as *APIError.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.base+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c.headers {
+			req.Header[k] = v
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		resp, err := c.hc.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !retryErr(method, err) || attempt >= c.retry.Max {
+				return nil, err
+			}
+		case resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified:
+			return resp, nil
+		default:
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			resp.Body.Close()
+			if !retryStatus(method, resp.StatusCode) || attempt >= c.retry.Max {
+				return nil, apiError(resp.StatusCode, b)
+			}
+			wait = retryAfter(resp.Header.Get("Retry-After"))
+		}
+
+		if wait <= 0 {
+			wait = c.retry.Base << attempt
+			wait += rand.N(wait/2 + 1)
+		}
+		if c.retry.MaxWait > 0 && wait > c.retry.MaxWait {
+			wait = c.retry.MaxWait
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryErr(method string, err error) bool {
+	if idempotent(method) {
+		return true
+	}
+	// nothing reached the server if the connection was never made
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+func retryStatus(method string, code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func apiError(code int, b []byte) *APIError {
+	e := &APIError{StatusCode: code}
+	if json.Unmarshal(b, e) != nil || e.Message == "" {
+		e.Message = strings.TrimSpace(string(b))
+		if e.Message == "" {
+			e.Message = http.StatusText(code)
+		}
+	}
+	return e
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PlanConfig returns the changes applying a config file (YAML or JSON)
+// would make, without making them.
+func (c *Client) PlanConfig(ctx context.Context, file []byte) (*PlanResult, error) {
+	return c.sendConfig(ctx, "/api/config/plan", file)
+}
+
+// ApplyConfig makes the project match a config file. The server plans
+// again, so changes made since PlanConfig are taken into account.
+func (c *Client) ApplyConfig(ctx context.Context, file []byte) (*PlanResult, error) {
+	return c.sendConfig(ctx, "/api/config/apply", file)
+}
+
+func (c *Client) sendConfig(ctx context.Context, path string, file []byte) (*PlanResult, error) {
+	// YAML is a superset of JSON, so the file is sent as is
+	resp, err := c.send(ctx, http.MethodPost, path, file, http.Header{"Content-Type": {"application/yaml"}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var p PlanResult
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("POST %s: decode response: %w", path, err)
+	}
+	return &p, nil
+}
+
+// ExportConfig returns the project's current config as a file, in YAML
+// or, with asYAML false, JSON.
+func (c *Client) ExportConfig(ctx context.Context, asYAML bool) ([]byte, error) {
+	path := "/api/config/export"
+	if asYAML {
+		path += "?format=yaml"
+	}
+	resp, err := c.send(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
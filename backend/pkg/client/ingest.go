@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// IngestClient is the agent side of the API, authenticated with an agent
+// key (POST /api/agents/register). Custom agents use it to pull their
+// targets and push results.
+type IngestClient struct{ c *Client }
+
+func NewIngestClient(baseURL, apiKey string, opts ...Option) *IngestClient {
+	c := New(baseURL, opts...)
+	c.headers.Set("X-Api-Key", apiKey)
+	return &IngestClient{c: c}
+}
+
+// Push sends a batch of results. A push that fails after reaching the
+// server is not retried, since the server may have stored it; keep the
+// checks and send them again later if losing them matters more than the
+// odd duplicate. A 400 means the server will never accept the batch.
+func (ic *IngestClient) Push(ctx context.Context, checks []Check) (*IngestResponse, error) {
+	var out IngestResponse
+	if err := ic.c.do(ctx, http.MethodPost, "/api/ingest/checks", IngestRequest{Checks: checks}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Heartbeat reports the agent as alive. Heartbeats and pushes both keep
+// the server from opening an agent outage.
+func (ic *IngestClient) Heartbeat(ctx context.Context, hb Heartbeat) error {
+	return ic.c.do(ctx, http.MethodPost, "/api/agents/heartbeat", hb, nil)
+}
+
+// Targets returns the active targets for this agent. Pass the ETag from
+// the previous call: while nothing has changed, changed is false and
+// targets is nil.
+func (ic *IngestClient) Targets(ctx context.Context, etag string) (targets []Target, newETag string, changed bool, err error) {
+	var h http.Header
+	if etag != "" {
+		h = http.Header{"If-None-Match": {etag}}
+	}
+	resp, err := ic.c.send(ctx, http.MethodGet, "/api/agents/targets", nil, h)
+	if err != nil {
+		return nil, etag, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, false, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		return nil, etag, false, fmt.Errorf("decode targets: %w", err)
+	}
+	return targets, resp.Header.Get("ETag"), true, nil
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Logs returns up to limit of a target's most recent log lines, newest
+// first. limit <= 0 uses the server's default of 200.
+func (c *Client) Logs(ctx context.Context, targetID int64, limit int) ([]CheckLog, error) {
+	q := url.Values{"target_id": {strconv.FormatInt(targetID, 10)}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out []CheckLog
+	return out, c.do(ctx, http.MethodGet, "/api/logs?"+q.Encode(), nil, &out)
+}
+
+// StreamLogs calls fn with each new log line of a target until the stream
+// ends or ctx is done. It returns io.EOF when the server closed the
+// stream; callers that want to keep following reconnect.
+func (c *Client) StreamLogs(ctx context.Context, targetID int64, fn func(CheckLog)) error {
+	// the stream stays open, so the client's timeout can't apply; the
+	// server pings every 25s
+	sc := *c
+	sc.hc = &http.Client{Transport: c.hc.Transport}
+	sc.retry = Retry{}
+	path := "/api/logs/stream?target_id=" + strconv.FormatInt(targetID, 10)
+	resp, err := sc.send(ctx, http.MethodGet, path, nil, http.Header{"Accept": {"text/event-stream"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	event, data := "", ""
+	s := bufio.NewScanner(resp.Body)
+	s.Buffer(make([]byte, 64<<10), 1<<20)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case line == "":
+			if event == "log" {
+				var l CheckLog
+				if json.Unmarshal([]byte(data), &l) == nil {
+					fn(l)
+				}
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
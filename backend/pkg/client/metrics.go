@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Metrics returns availability, latency and outages of a target between
+// from and to. Both zero means the last hour; with only one set, the window
+// is the hour before to or the hour after from.
+func (c *Client) Metrics(ctx context.Context, targetID int64, from, to time.Time) (*Metrics, error) {
+	q := url.Values{"target_id": {strconv.FormatInt(targetID, 10)}}
+	switch {
+	case from.IsZero() && !to.IsZero():
+		from = to.Add(-time.Hour)
+	case to.IsZero() && !from.IsZero():
+		to = from.Add(time.Hour)
+	}
+	if !from.IsZero() {
+		q.Set("from", from.UTC().Format(time.RFC3339))
+		q.Set("to", to.UTC().Format(time.RFC3339))
+	}
+	var m Metrics
+	if err := c.do(ctx, http.MethodGet, "/api/metrics?"+q.Encode(), nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// OutageQuery filters ListOutages. Zero values don't filter.
+type OutageQuery struct {
+	TargetID int64
+	OpenOnly bool
+	From, To time.Time // outages overlapping this window
+	Limit    int       // server default 100, max 1000
+}
+
+// ListOutages returns the project's outages, newest first.
+func (c *Client) ListOutages(ctx context.Context, f OutageQuery) ([]Outage, error) {
+	q := url.Values{}
+	if f.TargetID > 0 {
+		q.Set("target_id", strconv.FormatInt(f.TargetID, 10))
+	}
+	if f.OpenOnly {
+		q.Set("open", "true")
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.UTC().Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.UTC().Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	path := "/api/outages"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var out []Outage
+	return out, c.do(ctx, http.MethodGet, path, nil, &out)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ListTargets returns the project's targets; a non-empty tag keeps only
+// targets with that tag.
+func (c *Client) ListTargets(ctx context.Context, tag string) ([]Target, error) {
+	path := "/api/targets"
+	if tag != "" {
+		path += "?tag=" + url.QueryEscape(tag)
+	}
+	var out []Target
+	return out, c.do(ctx, http.MethodGet, path, nil, &out)
+}
+
+func (c *Client) GetTarget(ctx context.Context, id int64) (*Target, error) {
+	var t Target
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/targets/%d", id), nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTarget creates t and returns it as stored. ID, Key, Paused and
+// CreatedAt are ignored.
+func (c *Client) CreateTarget(ctx context.Context, t Target) (*Target, error) {
+	var out Target
+	if err := c.do(ctx, http.MethodPost, "/api/targets", t, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateTarget changes the fields in patch, keyed by their JSON names.
+// Fields not in patch are kept and a nil value clears one:
+//
+//	c.UpdateTarget(ctx, id, map[string]any{"timeout_ms": 2000, "description": nil})
+func (c *Client) UpdateTarget(ctx context.Context, id int64, patch map[string]any) (*Target, error) {
+	var out Target
+	if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/api/targets/%d", id), patch, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteTarget deletes a target with its checks, outages and logs.
+func (c *Client) DeleteTarget(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/targets/%d", id), nil, nil)
+}
+
+// PauseTarget stops checks of a target and ends any open outage.
+func (c *Client) PauseTarget(ctx context.Context, id int64) (*Target, error) {
+	var out Target
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/targets/%d/pause", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ResumeTarget(ctx context.Context, id int64) (*Target, error) {
+	var out Target
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/targets/%d/resume", id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
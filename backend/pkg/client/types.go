@@ -0,0 +1,289 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// target types
+const (
+	TargetHTTP      = "http"
+	TargetGRPC      = "grpc"
+	TargetMultistep = "multistep"
+)
+
+type Target struct {
+	ID        int64     `json:"id"`
+	ProjectID int64     `json:"project_id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"` // http|grpc|multistep
+	URL       string    `json:"url"`
+	TimeoutMs int       `json:"timeout_ms"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Key identifies targets managed by declarative config; empty for
+	// targets created through the API.
+	Key         string   `json:"key,omitempty"`
+	Group       string   `json:"group,omitempty"` // group key
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// only agents whose labels include all of these run the target
+	AgentSelector map[string]string `json:"agent_selector,omitempty"`
+	// paused targets are skipped by agents; set via pause/resume only
+	Paused bool `json:"paused"`
+
+	// 0 lets the agent use its POLL_INTERVAL_SEC
+	IntervalSec int `json:"interval_sec,omitempty"`
+	// nil lets the agent use its FRESH_CONNECTIONS default
+	FreshConnection *bool `json:"fresh_connection,omitempty"`
+
+	// grpc only: URL is host:port
+	GRPCService       string `json:"grpc_service,omitempty"`
+	GRPCTLS           bool   `json:"grpc_tls,omitempty"`
+	GRPCTLSSkipVerify bool   `json:"grpc_tls_skip_verify,omitempty"`
+
+	// http only; nil means any 2xx/3xx is OK
+	Assertions *Assertions `json:"assertions,omitempty"`
+
+	// http request; empty method means GET. Header values and body may
+	// contain ${env:NAME} / ${file:PATH} refs that only the agent resolves.
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Auth    *Auth             `json:"auth,omitempty"`
+
+	// multistep only: run in order, reported as one check
+	Steps []Step `json:"steps,omitempty"`
+}
+
+// Step is one request of a multistep target. Extracted values are
+// available to later steps as {{var}} in URL, headers and body.
+type Step struct {
+	Name       string            `json:"name"`
+	Method     string            `json:"method,omitempty"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Auth       *Auth             `json:"auth,omitempty"`
+	TimeoutMs  int               `json:"timeout_ms,omitempty"`
+	Assertions *Assertions       `json:"assertions,omitempty"`
+	Extract    []Extract         `json:"extract,omitempty"`
+	Always     bool              `json:"always,omitempty"` // cleanup steps run even after a failure
+}
+
+// Extract takes exactly one of JSONPath, Header or Regex.
+type Extract struct {
+	Var      string `json:"var"`
+	JSONPath string `json:"json_path,omitempty"`
+	Header   string `json:"header,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+}
+
+// Auth holds secret refs, never secrets: Password and Token must be a
+// single ${env:NAME} or ${file:PATH} ref.
+type Auth struct {
+	Type     string `json:"type"` // basic|bearer
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// Assertions are evaluated by the agent against each HTTP response.
+// Any failure is reported with reason "assertion_failed".
+type Assertions struct {
+	StatusCodes     []string          `json:"status_codes,omitempty"` // "200", "2xx", "200-204"
+	BodyContains    []string          `json:"body_contains,omitempty"`
+	BodyNotContains []string          `json:"body_not_contains,omitempty"`
+	BodyRegex       []string          `json:"body_regex,omitempty"`
+	JSONPath        []JSONPathAssert  `json:"json_path,omitempty"`
+	Headers         []HeaderAssertion `json:"headers,omitempty"`
+	MaxBodyBytes    int64             `json:"max_body_bytes,omitempty"`
+	MaxLatencyMs    int               `json:"max_latency_ms,omitempty"`
+}
+
+type JSONPathAssert struct {
+	Path   string          `json:"path"` // $.data.items[0].status
+	Equals json.RawMessage `json:"equals"`
+}
+
+// HeaderAssertion checks presence when Value is empty, equality otherwise.
+type HeaderAssertion struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// Timings is the HTTP phase breakdown of one check. Phases that did not
+// happen (DNS and connect on a reused connection, TLS over plain HTTP) are
+// nil.
+type Timings struct {
+	DNSMs      *int `json:"dns_ms,omitempty"`
+	ConnectMs  *int `json:"connect_ms,omitempty"`
+	TLSMs      *int `json:"tls_ms,omitempty"`
+	TTFBMs     *int `json:"ttfb_ms,omitempty"`     // request written → first response byte
+	TransferMs *int `json:"transfer_ms,omitempty"` // first byte → body fully read
+}
+
+func (t *Timings) String() string {
+	var parts []string
+	add := func(name string, v *int) {
+		if v != nil {
+			parts = append(parts, fmt.Sprintf("%s %dms", name, *v))
+		}
+	}
+	add("dns", t.DNSMs)
+	add("connect", t.ConnectMs)
+	add("tls", t.TLSMs)
+	add("ttfb", t.TTFBMs)
+	add("transfer", t.TransferMs)
+	return strings.Join(parts, ", ")
+}
+
+// CheckLog is a log line recorded while running a check.
+type CheckLog struct {
+	TS    string `json:"ts"`    // RFC3339
+	Level string `json:"level"` // trace|info|warn|error
+	Line  string `json:"line"`
+}
+
+// Check is one result pushed by an agent.
+type Check struct {
+	TargetID   int64      `json:"target_id"`
+	TS         string     `json:"ts"` // RFC3339
+	StatusCode int        `json:"status_code"`
+	OK         bool       `json:"ok"`
+	LatencyMs  int        `json:"latency_ms"`
+	Error      string     `json:"error"` // failure reason, empty when OK
+	Timings    *Timings   `json:"timings,omitempty"`
+	Logs       []CheckLog `json:"logs,omitempty"`
+}
+
+// IngestRequest is the body of POST /api/ingest/checks.
+type IngestRequest struct {
+	Checks []Check `json:"checks"`
+}
+
+// IngestResponse counts the checks the server kept. Checks for unknown,
+// paused or other projects' targets, with a bad TS, or that failed to
+// store, are dropped.
+type IngestResponse struct {
+	Ingested int `json:"ingested"`
+}
+
+// Heartbeat is the body of POST /api/agents/heartbeat.
+type Heartbeat struct {
+	Version     string `json:"version"`
+	Hostname    string `json:"hostname"`
+	UptimeSec   int64  `json:"uptime_sec"`
+	TargetCount int    `json:"target_count"`
+	SpoolDepth  int    `json:"spool_depth"`
+}
+
+// Metrics is the response of GET /api/metrics.
+type Metrics struct {
+	TargetID int64  `json:"target_id"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	// nil when there were no checks in the window
+	AvailabilityPercentChecks *float64 `json:"availability_percent_checks"`
+	// share of the window, less paused and agent-offline time, outside
+	// outages
+	AvailabilityPercentTime *float64         `json:"availability_percent_time"`
+	TotalChecks             int64            `json:"total_checks"`
+	SuccessfulChecks        int64            `json:"successful_checks"`
+	FailedChecks            int64            `json:"failed_checks"`
+	AverageLatencyMs        int              `json:"average_latency_ms"`
+	AveragePhasesMs         PhaseAverages    `json:"average_phases_ms"`
+	FailuresByReason        map[string]int64 `json:"failures_by_reason"`
+	FailuresByCategory      map[string]int64 `json:"failures_by_category"`
+	Outages                 []MetricsOutage  `json:"outages"`
+	DowntimeMs              int64            `json:"downtime_ms"`
+	Paused                  bool             `json:"paused"`
+	PausedMs                int64            `json:"paused_ms"`
+	LastCheckAt             *string          `json:"last_check_at"`
+	// the agent that last reported the target is offline, so a quiet
+	// target is unknown rather than healthy
+	AgentOffline bool `json:"agent_offline"`
+	// time in the window that agent was offline, left out of
+	// availability_percent_time and downtime_ms as unknown
+	AgentOfflineMs int64 `json:"agent_offline_ms"`
+}
+
+// PhaseAverages are mean phase times over successful checks; nil when no
+// check in the window reported the phase.
+type PhaseAverages struct {
+	DNS      *int `json:"dns"`
+	Connect  *int `json:"connect"`
+	TLS      *int `json:"tls"`
+	TTFB     *int `json:"ttfb"`
+	Transfer *int `json:"transfer"`
+}
+
+// MetricsOutage is an outage clamped to the metrics window.
+type MetricsOutage struct {
+	StartedAt  string  `json:"started_at"`
+	EndedAt    *string `json:"ended_at,omitempty"`
+	DurationMs int64   `json:"duration_ms"`
+	Reason     string  `json:"reason"`
+}
+
+// Outage is an item of GET /api/outages.
+type Outage struct {
+	ID         int64   `json:"id"`
+	TargetID   int64   `json:"target_id"`
+	TargetName string  `json:"target_name"`
+	StartedAt  string  `json:"started_at"`
+	EndedAt    *string `json:"ended_at,omitempty"`
+	DurationMs int64   `json:"duration_ms"` // up to now while open
+	Reason     string  `json:"reason"`
+}
+
+// Agent is an item of GET /api/agents. Keys are never returned here; they
+// are shown once, in an AgentKey.
+type Agent struct {
+	ID               int64             `json:"id"`
+	Name             string            `json:"name"`
+	KeyPrefix        string            `json:"key_prefix"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	CreatedAt        string            `json:"created_at"`
+	Revoked          bool              `json:"revoked"`
+	RevokedAt        string            `json:"revoked_at,omitempty"`
+	PrevKeyExpiresAt string            `json:"previous_key_expires_at,omitempty"`
+	LastSeenAt       string            `json:"last_seen_at,omitempty"`
+	Version          string            `json:"version,omitempty"`
+	Hostname         string            `json:"hostname,omitempty"`
+	UptimeSec        int64             `json:"uptime_sec"`
+	TargetCount      int               `json:"target_count"`
+	SpoolDepth       int               `json:"spool_depth"`
+}
+
+// AgentKey is a new agent key, from register or rotate-key. It is the only
+// time the key is shown.
+type AgentKey struct {
+	AgentID          int64  `json:"agent_id"`
+	APIKey           string `json:"api_key"`
+	PrevKeyExpiresAt string `json:"previous_key_expires_at,omitempty"`
+}
+
+// Change is one difference between a config file and the server.
+type Change struct {
+	Kind   string   `json:"kind"` // group|target|agent|alert_route|maintenance_window
+	Key    string   `json:"key"`
+	Action string   `json:"action"`           // create|update|delete
+	Fields []string `json:"fields,omitempty"` // changed fields, for updates
+}
+
+// PlanResult is the response of POST /api/config/plan and apply. Summary
+// counts changes by action.
+type PlanResult struct {
+	Changes []Change       `json:"changes"`
+	Summary map[string]int `json:"summary"`
+	Applied bool           `json:"applied"`
+}
+
+// FieldError is one validation problem in a rejected request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}